@@ -0,0 +1,189 @@
+// Package assets finds the URLs of the images, models and other files a mod
+// has TTS download, and rewrites them.
+package assets
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Use is a field holding an asset URL.
+type Use struct {
+	// GUID is the object the field belongs to, empty for the mod's own
+	// settings.
+	GUID string `json:"guid,omitempty"`
+	// File is the json file holding the field, if the field was read from
+	// the mod's source rather than a built mod.
+	File string `json:"file,omitempty"`
+	// Field is the path to the field, its keys and array indices joined by
+	// dots, like CustomDeck.1.FaceURL.
+	Field string `json:"field"`
+}
+
+// Inventory is where each asset URL is used.
+type Inventory map[string][]Use
+
+// Add records u as a use of url.
+func (inv Inventory) Add(url string, u Use) {
+	inv[url] = append(inv[url], u)
+}
+
+// Merge adds every use of other to inv.
+func (inv Inventory) Merge(other Inventory) {
+	for url, uses := range other {
+		inv[url] = append(inv[url], uses...)
+	}
+}
+
+// URLs lists the URLs of inv, sorted.
+func (inv Inventory) URLs() []string {
+	urls := make([]string, 0, len(inv))
+	for u := range inv {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// IsURLField reports whether key names a field holding an asset URL, as the
+// fields of custom objects, decks, UI assets, decals and tables all end in URL
+// (or Url, as PDFUrl does).
+func IsURLField(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), "url")
+}
+
+// Walk calls fn with the path and value of every non-empty asset URL field in
+// v, and of its maps and arrays in turn. Paths start with prefix. When fn
+// returns a new URL and true, the field is set to it. Walk reports whether
+// any field changed.
+func Walk(v interface{}, prefix string, fn func(field, url string) (string, bool)) bool {
+	changed := false
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s, ok := t[k].(string); ok {
+				if s == "" || !IsURLField(k) {
+					continue
+				}
+				if to, ok := fn(join(k), s); ok && to != s {
+					t[k] = to
+					changed = true
+				}
+				continue
+			}
+			changed = Walk(t[k], join(k), fn) || changed
+		}
+	case []interface{}:
+		for i, e := range t {
+			changed = Walk(e, join(strconv.Itoa(i)), fn) || changed
+		}
+	case []map[string]interface{}:
+		for i, e := range t {
+			changed = Walk(e, join(strconv.Itoa(i)), fn) || changed
+		}
+	}
+	return changed
+}
+
+// InMod lists the asset URLs of a built mod, or of a single built object: the
+// mod's own, under no GUID, and those of every object, contained object and
+// state, under the object's GUID. Fields are relative to the object holding
+// them.
+func InMod(data map[string]interface{}) Inventory {
+	inv := Inventory{}
+	var walk func(obj map[string]interface{}, guid string)
+	walk = func(obj map[string]interface{}, guid string) {
+		for _, k := range sortedKeys(obj) {
+			switch k {
+			case "ObjectStates", "ContainedObjects", "States":
+				continue
+			}
+			v := obj[k]
+			if s, ok := v.(string); ok {
+				if s != "" && IsURLField(k) {
+					inv.Add(s, Use{GUID: guid, Field: k})
+				}
+				continue
+			}
+			Walk(v, k, func(field, url string) (string, bool) {
+				inv.Add(url, Use{GUID: guid, Field: field})
+				return url, false
+			})
+		}
+		children := append(objects(obj["ObjectStates"]), objects(obj["ContainedObjects"])...)
+		children = append(children, objects(obj["States"])...)
+		for _, c := range children {
+			g, _ := c["GUID"].(string)
+			walk(c, g)
+		}
+	}
+	walk(data, "")
+	return inv
+}
+
+// Mapping rewrites asset URLs by their start: each key is replaced by its
+// value, the longest matching key winning. A whole URL is its own start.
+type Mapping map[string]string
+
+// NewMapping reads a Mapping from a json object of strings.
+func NewMapping(raw map[string]interface{}) (Mapping, error) {
+	m := Mapping{}
+	for from, rawTo := range raw {
+		to, ok := rawTo.(string)
+		if !ok {
+			return nil, fmt.Errorf("mapping of %q should be a string, is %T", from, rawTo)
+		}
+		if from == "" {
+			return nil, fmt.Errorf("mapping of %q: can't map an empty start", to)
+		}
+		m[from] = to
+	}
+	return m, nil
+}
+
+// Rewrite gives the URL url is mapped to, and whether it is mapped.
+func (m Mapping) Rewrite(url string) (string, bool) {
+	best := ""
+	for from := range m {
+		if strings.HasPrefix(url, from) && len(from) > len(best) {
+			best = from
+		}
+	}
+	if best == "" {
+		return url, false
+	}
+	return m[best] + url[len(best):], true
+}
+
+// WriteText writes inv as text, each URL followed by its uses.
+func (inv Inventory) WriteText(w io.Writer) error {
+	for _, url := range inv.URLs() {
+		if _, err := fmt.Fprintln(w, url); err != nil {
+			return err
+		}
+		for _, u := range inv[url] {
+			owner := u.GUID
+			if owner == "" {
+				owner = "mod"
+			}
+			if _, err := fmt.Fprintf(w, "  %-6s  %s  %s\n", owner, u.File, u.Field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
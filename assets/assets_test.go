@@ -0,0 +1,118 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWalk(t *testing.T) {
+	data := map[string]interface{}{
+		"Nickname": "http://not.an/asset",
+		"CustomDeck": map[string]interface{}{
+			"1": map[string]interface{}{
+				"FaceURL": "http://old.host/face.png",
+				"BackURL": "http://other.host/back.png",
+			},
+		},
+		"CustomMesh": map[string]interface{}{
+			"MeshURL":    "http://old.host/mesh.obj",
+			"DiffuseURL": "",
+		},
+		"AttachedDecals": []interface{}{
+			map[string]interface{}{
+				"CustomDecal": map[string]interface{}{"ImageURL": "http://old.host/decal.png"},
+			},
+		},
+		"CustomPDF": map[string]interface{}{"PDFUrl": "http://old.host/rules.pdf"},
+	}
+	type field struct{ Field, URL string }
+	got := []field{}
+	changed := Walk(data, "", func(f, url string) (string, bool) {
+		got = append(got, field{f, url})
+		if strings.HasPrefix(url, "http://old.host/") {
+			return "https://new.host/" + strings.TrimPrefix(url, "http://old.host/"), true
+		}
+		return url, false
+	})
+	want := []field{
+		{"AttachedDecals.0.CustomDecal.ImageURL", "http://old.host/decal.png"},
+		{"CustomDeck.1.BackURL", "http://other.host/back.png"},
+		{"CustomDeck.1.FaceURL", "http://old.host/face.png"},
+		{"CustomMesh.MeshURL", "http://old.host/mesh.obj"},
+		{"CustomPDF.PDFUrl", "http://old.host/rules.pdf"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if !changed {
+		t.Errorf("Walk() = false, want true")
+	}
+	if got := data["CustomDeck"].(map[string]interface{})["1"].(map[string]interface{})["FaceURL"]; got != "https://new.host/face.png" {
+		t.Errorf("FaceURL = %v, want it rewritten", got)
+	}
+	if got := data["CustomDeck"].(map[string]interface{})["1"].(map[string]interface{})["BackURL"]; got != "http://other.host/back.png" {
+		t.Errorf("BackURL = %v, want it unchanged", got)
+	}
+}
+
+func TestMapping(t *testing.T) {
+	m, err := NewMapping(map[string]interface{}{
+		"http://old.host/":                "https://new.host/",
+		"http://old.host/cards/":          "https://cards.host/",
+		"http://old.host/logo.png":        "https://new.host/logo2.png",
+		"http://steamusercontent.com/ugc": "https://steamusercontent-a.akamaihd.net/ugc",
+	})
+	if err != nil {
+		t.Fatalf("NewMapping(): %v", err)
+	}
+	for _, tc := range []struct {
+		url, want string
+		ok        bool
+	}{
+		{"http://old.host/a.png", "https://new.host/a.png", true},
+		{"http://old.host/cards/1.png", "https://cards.host/1.png", true},
+		{"http://old.host/logo.png", "https://new.host/logo2.png", true},
+		{"http://steamusercontent.com/ugc/123/ABC/", "https://steamusercontent-a.akamaihd.net/ugc/123/ABC/", true},
+		{"http://elsewhere/a.png", "http://elsewhere/a.png", false},
+	} {
+		got, ok := m.Rewrite(tc.url)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Rewrite(%s) = %s, %v; want %s, %v", tc.url, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestNewMappingErrors(t *testing.T) {
+	for _, raw := range []map[string]interface{}{
+		{"http://old.host/": 3},
+		{"": "https://new.host/"},
+	} {
+		if _, err := NewMapping(raw); err == nil {
+			t.Errorf("NewMapping(%v) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	inv := Inventory{}
+	inv.Add("http://b/deck.png", Use{GUID: "ABC123", File: "objects/Deck.ABC123.json", Field: "CustomDeck.1.FaceURL"})
+	inv.Merge(Inventory{
+		"http://a/sky.png":  {{File: "config.json", Field: "SkyURL"}},
+		"http://b/deck.png": {{GUID: "DEF456", File: "objects/Deck.ABC123/Card.DEF456.json", Field: "CustomDeck.1.FaceURL"}},
+	})
+	var b strings.Builder
+	if err := inv.WriteText(&b); err != nil {
+		t.Fatalf("WriteText(): %v", err)
+	}
+	want := `http://a/sky.png
+  mod     config.json  SkyURL
+http://b/deck.png
+  ABC123  objects/Deck.ABC123.json  CustomDeck.1.FaceURL
+  DEF456  objects/Deck.ABC123/Card.DEF456.json  CustomDeck.1.FaceURL
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,160 @@
+package assets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheDirs are the directories of TTS's Mods directory each kind of asset
+// is downloaded to, by the name of the field holding its URL. The URL of a
+// CustomUIAssets entry is an image or an asset bundle, depending on its Type.
+var cacheDirs = map[string][]string{
+	"MeshURL":                 {"Models", "Models Raw"},
+	"ColliderURL":             {"Models", "Models Raw"},
+	"AssetbundleURL":          {"Assetbundles"},
+	"AssetbundleSecondaryURL": {"Assetbundles"},
+	"PDFUrl":                  {"PDF"},
+	"CurrentAudioURL":         {"Audio"},
+	"URL":                     {"Images", "Images Raw", "Assetbundles"},
+	"ImageURL":                {"Images", "Images Raw"},
+	"ImageSecondaryURL":       {"Images", "Images Raw"},
+	"FaceURL":                 {"Images", "Images Raw"},
+	"BackURL":                 {"Images", "Images Raw"},
+	"DiffuseURL":              {"Images", "Images Raw"},
+	"NormalURL":               {"Images", "Images Raw"},
+	"SkyURL":                  {"Images", "Images Raw"},
+	"TableURL":                {"Images", "Images Raw"},
+	"LutURL":                  {"Images", "Images Raw"},
+}
+
+// CacheName is the name, without its extension, TTS gives the file it
+// downloads url to: the URL with everything but letters and digits left out.
+func CacheName(url string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, url)
+}
+
+// Mirror is a local copy of TTS's Mods directory, with the Images, Models and
+// other directories TTS downloads assets to.
+type Mirror struct {
+	// files are the files of each directory by CacheName, as extensions vary
+	files map[string]map[string][]mirrorFile
+}
+
+type mirrorFile struct {
+	path string
+	size int64
+}
+
+// OpenMirror reads the file names of the mirror in dir.
+func OpenMirror(dir string) (*Mirror, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	m := &Mirror{files: map[string]map[string][]mirrorFile{}}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range entries {
+		if !sub.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, sub.Name()))
+		if err != nil {
+			return nil, err
+		}
+		byName := map[string][]mirrorFile{}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			fi, err := f.Info()
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+			byName[name] = append(byName[name], mirrorFile{path: filepath.Join(sub.Name(), f.Name()), size: fi.Size()})
+		}
+		m.files[sub.Name()] = byName
+	}
+	return m, nil
+}
+
+// Problem kinds of an asset checked against a mirror.
+const (
+	Missing = "missing"
+	Empty   = "empty"
+)
+
+// Lookup finds the file the mirror holds for url, held in field. It returns
+// the file, relative to the mirror, and an empty problem when it is there,
+// else the problem and where the file was expected.
+func (m *Mirror) Lookup(field, url string) (path, problem string) {
+	key := field
+	if i := strings.LastIndexByte(field, '.'); i >= 0 {
+		key = field[i+1:]
+	}
+	dirs, ok := cacheDirs[key]
+	if !ok {
+		// a field missing from cacheDirs: the file may be anywhere
+		dirs = sortedKeys(m.files)
+	}
+	name := CacheName(url)
+	if len(dirs) == 0 {
+		return name + ".*", Missing
+	}
+	var empty string
+	for _, d := range dirs {
+		for _, f := range m.files[d][name] {
+			if f.size > 0 {
+				return f.path, ""
+			}
+			empty = f.path
+		}
+	}
+	if empty != "" {
+		return empty, Empty
+	}
+	return filepath.Join(dirs[0], name+".*"), Missing
+}
+
+// Result is an asset URL checked against a mirror.
+type Result struct {
+	Use
+	URL     string `json:"url"`
+	Problem string `json:"problem"`
+	// Path is where the file is or was expected, relative to the mirror
+	Path string `json:"path"`
+}
+
+// Verify checks every URL of inv against m, returning the uses whose file is
+// missing or empty, sorted by GUID and field.
+func (m *Mirror) Verify(inv Inventory) []Result {
+	results := []Result{}
+	for _, url := range inv.URLs() {
+		for _, u := range inv[url] {
+			if path, problem := m.Lookup(u.Field, url); problem != "" {
+				results = append(results, Result{Use: u, URL: url, Problem: problem, Path: path})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].GUID != results[j].GUID {
+			return results[i].GUID < results[j].GUID
+		}
+		return results[i].Field < results[j].Field
+	})
+	return results
+}
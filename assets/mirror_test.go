@@ -0,0 +1,98 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCacheName(t *testing.T) {
+	for url, want := range map[string]string{
+		"http://cloud-3.steamusercontent.com/ugc/1234/ABCD/": "httpcloud3steamusercontentcomugc1234ABCD",
+		"https://i.imgur.com/a1B2c3.png?x=1":                 "httpsiimgurcoma1B2c3pngx1",
+	} {
+		if got := CacheName(url); got != want {
+			t.Errorf("CacheName(%s) = %s, want %s", url, got, want)
+		}
+	}
+}
+
+func TestInMod(t *testing.T) {
+	data := map[string]interface{}{
+		"SkyURL":         "http://host/sky.png",
+		"CustomUIAssets": []interface{}{map[string]interface{}{"Name": "logo", "URL": "http://host/logo.png"}},
+		"ObjectStates": []interface{}{
+			map[string]interface{}{
+				"GUID":       "ABC123",
+				"CustomMesh": map[string]interface{}{"MeshURL": "http://host/m.obj"},
+				"ContainedObjects": []interface{}{
+					map[string]interface{}{
+						"GUID":       "DEF456",
+						"CustomDeck": map[string]interface{}{"1": map[string]interface{}{"FaceURL": "http://host/face.png"}},
+					},
+				},
+				"States": map[string]interface{}{
+					"2": map[string]interface{}{
+						"GUID":        "FED654",
+						"CustomImage": map[string]interface{}{"ImageURL": "http://host/face.png"},
+					},
+				},
+			},
+		},
+	}
+	want := Inventory{
+		"http://host/sky.png":  {{Field: "SkyURL"}},
+		"http://host/logo.png": {{Field: "CustomUIAssets.0.URL"}},
+		"http://host/m.obj":    {{GUID: "ABC123", Field: "CustomMesh.MeshURL"}},
+		"http://host/face.png": {
+			{GUID: "DEF456", Field: "CustomDeck.1.FaceURL"},
+			{GUID: "FED654", Field: "CustomImage.ImageURL"},
+		},
+	}
+	if diff := cmp.Diff(want, InMod(data)); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestMirrorVerify(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"Images/httphostfacepng.jpg":  "jpeg",
+		"Images/httphostemptypng.png": "",
+		"Models/httphostmobj.obj":     "v 0 0 0",
+		// a model isn't looked for among images
+		"Images/httphostwrongobj.obj":         "v 0 0 0",
+		"Assetbundles/httphostbundle.unity3d": "bundle",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+			t.Fatalf("MkdirAll(): %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+	}
+	m, err := OpenMirror(dir)
+	if err != nil {
+		t.Fatalf("OpenMirror(): %v", err)
+	}
+	inv := Inventory{
+		"http://host/face.png":  {{GUID: "A", Field: "CustomDeck.1.FaceURL"}},
+		"http://host/empty.png": {{GUID: "B", Field: "CustomImage.ImageURL"}},
+		"http://host/m.obj":     {{GUID: "C", Field: "CustomMesh.MeshURL"}},
+		"http://host/wrong.obj": {{GUID: "D", Field: "CustomMesh.MeshURL"}},
+		"http://host/gone.png":  {{Field: "SkyURL"}},
+		// a field cacheDirs doesn't know is looked for everywhere
+		"http://host/bundle": {{GUID: "E", Field: "CustomThing.SomeURL"}},
+	}
+	want := []Result{
+		{Use: Use{Field: "SkyURL"}, URL: "http://host/gone.png", Problem: Missing, Path: "Images/httphostgonepng.*"},
+		{Use: Use{GUID: "B", Field: "CustomImage.ImageURL"}, URL: "http://host/empty.png", Problem: Empty, Path: "Images/httphostemptypng.png"},
+		{Use: Use{GUID: "D", Field: "CustomMesh.MeshURL"}, URL: "http://host/wrong.obj", Problem: Missing, Path: "Models/httphostwrongobj.*"},
+	}
+	if diff := cmp.Diff(want, m.Verify(inv)); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,159 @@
+package assets
+
+import (
+	"ModCreator/lua"
+	"ModCreator/types"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// UIAsset is an entry of a CustomUIAssets list, which XmlUI and UI calls of
+// Lua refer to by name.
+type UIAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// GUID is the object whose list it is in, empty for Global's.
+	GUID string `json:"guid,omitempty"`
+}
+
+// UnusedUIAssets finds the CustomUIAssets of a built mod, Global's and every
+// object's, whose name isn't an attribute value or text of any XmlUI or a
+// string of any Lua script in it. A name only ever put together by a script
+// isn't seen, so these are worth a look rather than certainly unused.
+func UnusedUIAssets(data map[string]interface{}) []UIAsset {
+	r := &refs{names: map[string]bool{}}
+	r.object(data, "")
+	unused := []UIAsset{}
+	for _, a := range r.assets {
+		if r.names[a.Name] {
+			continue
+		}
+		found := false
+		for _, raw := range r.unread {
+			if strings.Contains(raw, a.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unused = append(unused, a)
+		}
+	}
+	return unused
+}
+
+type refs struct {
+	assets []UIAsset
+	// names are the strings of every script and XmlUI
+	names map[string]bool
+	// unread are the scripts and XmlUI that couldn't be read, searched whole
+	unread []string
+}
+
+// object records the assets of the mod or object in data, and what it refers
+// to, then does the same for the objects in it.
+func (r *refs) object(data map[string]interface{}, guid string) {
+	for _, m := range objects(data["CustomUIAssets"]) {
+		name, _ := m["Name"].(string)
+		url, _ := m["URL"].(string)
+		if name != "" {
+			r.assets = append(r.assets, UIAsset{Name: name, URL: url, GUID: guid})
+		}
+	}
+	if x, ok := data["XmlUI"].(string); ok && x != "" {
+		r.xml(x)
+	}
+	if l, ok := data["LuaScript"].(string); ok && l != "" {
+		r.lua(l)
+	}
+	children := append(objects(data["ObjectStates"]), objects(data["ContainedObjects"])...)
+	children = append(children, objects(data["States"])...)
+	for _, c := range children {
+		g, _ := c["GUID"].(string)
+		r.object(c, g)
+	}
+}
+
+// objects gives the json objects of v, whether it is an array of them or, as
+// States are, a map of them, as read from json or as built.
+func objects(v interface{}) []map[string]interface{} {
+	objs := []map[string]interface{}{}
+	add := func(e interface{}) {
+		switch m := e.(type) {
+		case map[string]interface{}:
+			objs = append(objs, m)
+		case types.J:
+			objs = append(objs, m)
+		}
+	}
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			add(e)
+		}
+	case []map[string]interface{}:
+		objs = append(objs, t...)
+	case types.ObjArray:
+		objs = append(objs, t...)
+	case []types.J:
+		for _, e := range t {
+			add(e)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			add(t[k])
+		}
+	case map[string]types.J:
+		for _, k := range sortedKeys(t) {
+			add(t[k])
+		}
+	}
+	return objs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *refs) xml(src string) {
+	d := xml.NewDecoder(strings.NewReader(src))
+	d.Strict = false
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			r.unread = append(r.unread, src)
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, a := range t.Attr {
+				r.names[strings.TrimSpace(a.Value)] = true
+			}
+		case xml.CharData:
+			r.names[strings.TrimSpace(string(t))] = true
+		}
+	}
+}
+
+func (r *refs) lua(src string) {
+	toks, err := lua.Lex(src)
+	if err != nil {
+		r.unread = append(r.unread, src)
+		return
+	}
+	for _, t := range toks {
+		if t.Kind == lua.String {
+			r.names[t.Value] = true
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnusedUIAssets(t *testing.T) {
+	uiAsset := func(name string) map[string]interface{} {
+		return map[string]interface{}{"Name": name, "Type": 0, "URL": "http://host/" + name + ".png"}
+	}
+	data := map[string]interface{}{
+		"CustomUIAssets": []interface{}{uiAsset("logo"), uiAsset("background"), uiAsset("stale")},
+		"XmlUI":          `<Panel image="background"><Image image=" logo "/></Panel>`,
+		"LuaScript":      `UI.setAttribute("x", "image", "fromlua")`,
+		"ObjectStates": []interface{}{
+			map[string]interface{}{
+				"GUID":           "ABC123",
+				"CustomUIAssets": []interface{}{uiAsset("fromlua"), uiAsset("objstale")},
+				"ContainedObjects": []interface{}{
+					map[string]interface{}{
+						"GUID":           "DEF456",
+						"CustomUIAssets": []interface{}{uiAsset("nested")},
+						// can't be read as xml, so searched as text
+						"XmlUI": `<Image image="nested">`,
+					},
+				},
+			},
+		},
+	}
+	want := []UIAsset{
+		{Name: "stale", URL: "http://host/stale.png"},
+		{Name: "objstale", URL: "http://host/objstale.png", GUID: "ABC123"},
+	}
+	if diff := cmp.Diff(want, UnusedUIAssets(data)); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,803 @@
+package main
+
+import (
+	"ModCreator/assets"
+	"ModCreator/bundler"
+	file "ModCreator/file"
+	"ModCreator/handler"
+	"ModCreator/mod"
+	"ModCreator/objects"
+	"ModCreator/project"
+	"ModCreator/types"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var cacheFile = filepath.Join(".ttsmm-cache", "objects.json")
+
+// options are the settings shared by every way of building or reversing a mod,
+// whether it is invoked through a subcommand or the deprecated top-level flags.
+type options struct {
+	moddir     string
+	bonusdir   string
+	libpaths   project.StringList
+	writeToSrc bool
+	// merge reverses over the existing objects directory instead of clearing
+	// it first
+	merge bool
+	// modfile is the output when building and the input when reversing
+	modfile  string
+	objin    string
+	objout   string
+	savedobj bool
+	watch    bool
+	noCache  bool
+	jobs     int
+
+	skipLuaCheck bool
+	minifyLua    bool
+	// allowCycles accepts circular requires the bundle's loader can run
+	allowCycles bool
+	// shared are patterns of the modules bundled once into Global
+	shared project.StringList
+	// reportBundles is where to write a bundle report, if anywhere
+	reportBundles string
+	skipXMLCheck  bool
+	skipGUIDCheck bool
+
+	// project is loaded from moddir by loadProject
+	project *project.Project
+}
+
+// loadProject reads the project file in o.moddir. Every option not set
+// explicitly on the command line is taken from it; outputFlag names the flag
+// that sets the build output, or is empty when modfile is an input.
+func loadProject(o *options, fs *flag.FlagSet, outputFlag string) error {
+	p, err := project.Load(o.moddir)
+	if err != nil {
+		return err
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	p.AddSources(o.bonusdir, o.libpaths)
+	o.shared = append(o.shared, p.SharedModules...)
+	if outputFlag != "" && !set[outputFlag] && p.Output != "" {
+		o.modfile = p.Output
+	}
+	if !set["savedobj"] {
+		o.savedobj = p.SavedObject
+	}
+	if !set["minify-lua"] {
+		o.minifyLua = p.MinifyLua
+	}
+	if !set["allow-require-cycles"] {
+		o.allowCycles = p.AllowRequireCycles
+	}
+	if !set["jobs"] && p.Jobs != 0 {
+		o.jobs = p.Jobs
+	}
+	o.project = p
+	return nil
+}
+
+func newFileOps(o options) project.Ops {
+	if o.objin != "" {
+		// handling for saved objects instead of a full savegame
+		return o.project.ObjectOps(o.moddir, o.objin, o.objout)
+	}
+	return o.project.Ops(o.moddir)
+}
+
+// newMod wires a Mod up to read from ops. It does not set where to write.
+func newMod(o options, ops project.Ops) *mod.Mod {
+	// setting this to empty instead of default return value (".") if not found
+	onlyObjStates := filepath.Base(o.objin)
+	if onlyObjStates == "." {
+		onlyObjStates = ""
+	}
+	m := mod.New(ops)
+	m.OnlyObjStates = onlyObjStates
+	m.SavedObj = o.savedobj
+	m.Jobs = o.jobs
+	m.LuaOptions = luaOptions(o)
+	m.XMLOptions = handler.XMLOptions{SkipCheck: o.skipXMLCheck}
+	return m
+}
+
+func luaOptions(o options) handler.LuaOptions {
+	lo := handler.LuaOptions{SkipCheck: o.skipLuaCheck, Minify: o.minifyLua, AllowCycles: o.allowCycles}
+	if o.objin == "" {
+		// only a whole mod has a Global to share modules through
+		lo.Shared = o.shared
+	}
+	return lo
+}
+
+// build generates a mod (or a single object when objin is set) from o.moddir
+// and writes it out, then keeps rebuilding on changes if o.watch is set.
+func build(o options) error {
+	ops := newFileOps(o)
+
+	// When generating a full mod with no explicit output path, default it to
+	// <moddir>/output.json. This must happen before basename/outputOps are
+	// derived, otherwise they are computed from an empty path (issue #93).
+	if o.modfile == "" {
+		o.modfile = filepath.Join(o.moddir, "output.json")
+	}
+	outputPath := o.modfile
+	if o.objin != "" {
+		outputPath = o.objout
+	}
+	basename := filepath.Base(outputPath)
+	outputOps := file.NewJSONOps(filepath.Dir(outputPath))
+
+	// Cache entries are keyed by paths relative to the objects directory, so
+	// the cache is only valid for the directory it was built from.
+	cachePath := filepath.Join(o.moddir, cacheFile)
+	cacheSettings := "mod"
+	if o.objin != "" {
+		cacheSettings = fmt.Sprintf("objin=%s", filepath.Dir(o.objin))
+	}
+	if o.skipLuaCheck {
+		// objects built unchecked mustn't be reused by a checked build
+		cacheSettings += ",skip-lua-check"
+	}
+	if o.minifyLua {
+		cacheSettings += ",minify-lua"
+	}
+	if o.allowCycles {
+		cacheSettings += ",allow-require-cycles"
+	}
+	if lo := luaOptions(o); len(lo.Shared) > 0 {
+		cacheSettings += ",shared=" + strings.Join(lo.Shared, ",")
+	}
+	cache := objects.LoadBuildCache(cachePath, cacheSettings)
+	if o.noCache {
+		cache = objects.NewBuildCache(cacheSettings)
+	}
+
+	buildOnce := func() error {
+		// A fresh Mod per build: GenerateFromConfig fills in m.Data in place.
+		m := newMod(o, ops)
+		m.RootWrite = outputOps
+		m.Cache = cache
+		m.SourceMaps = bundler.NewSourceMaps()
+		if err := m.GenerateFromConfig(); err != nil {
+			return fmt.Errorf("generateMod(<config>) : %v", err)
+		}
+		if err := m.Print(basename); err != nil {
+			return fmt.Errorf("printMod(...) : %v", err)
+		}
+		if o.reportBundles != "" {
+			if err := writeBundleReport(m.Data, o.reportBundles); err != nil {
+				return fmt.Errorf("writing bundle report: %v", err)
+			}
+		}
+		if err := m.SourceMaps.Save(bundler.SidecarPath(outputPath), o.moddir); err != nil {
+			return fmt.Errorf("writing source maps: %v", err)
+		}
+		hits, misses := cache.Stats()
+		log.Printf("objects: %d reused from cache, %d rebuilt", hits, misses)
+		if err := cache.Save(cachePath); err != nil {
+			// a cache that can't be written only costs time on the next build
+			log.Printf("could not save build cache %s: %v", cachePath, err)
+		}
+		return nil
+	}
+
+	if !o.watch {
+		return buildOnce()
+	}
+
+	// the settings of the project file are only read at the start, so a
+	// change to it is only reported
+	projectFile := filepath.Join(o.moddir, project.Filename)
+	candidates := []string{
+		filepath.Join(o.moddir, "config.json"),
+		projectFile,
+	}
+	for _, d := range o.project.SubDirs() {
+		candidates = append(candidates, filepath.Join(o.moddir, d))
+	}
+	candidates = append(candidates, o.project.LuaPaths(o.moddir)...)
+	candidates = append(candidates, o.project.XMLPaths(o.moddir)...)
+	if o.objin != "" {
+		candidates = append(candidates, filepath.Dir(o.objin))
+	}
+	watchPaths := []string{}
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if !seen[c] {
+			seen[c] = true
+			watchPaths = append(watchPaths, c)
+		}
+	}
+	// what every build writes mustn't trigger the next one, as it would when
+	// objout is next to objin
+	outputs := []string{outputPath, bundler.SidecarPath(outputPath), cachePath}
+	if o.reportBundles != "" && o.reportBundles != "-" {
+		outputs = append(outputs, o.reportBundles)
+	}
+	return watchAndBuild(watchPaths, outputs, []string{projectFile}, buildOnce)
+}
+
+// validate builds the mod in memory without writing anything, so every error a
+// real build would hit is reported. Unless skipGUIDCheck is set, it then writes
+// every shared GUID and lookup of a missing one to w.
+func validate(o options, w io.Writer) error {
+	m := newMod(o, newFileOps(o))
+	m.SourceMaps = bundler.NewSourceMaps()
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	if o.skipGUIDCheck {
+		return nil
+	}
+	problems, err := m.CheckGUIDs()
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d GUID problems found", len(problems))
+	}
+	return nil
+}
+
+// checkXML builds the mod in memory, like validate, and writes every warning
+// about its XmlUI to w, by file and line.
+func checkXML(o options, w io.Writer) error {
+	m := newMod(o, newFileOps(o))
+	var mu sync.Mutex
+	warnings := []handler.XMLWarning{}
+	m.XMLOptions = handler.XMLOptions{Warn: func(xw handler.XMLWarning) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, xw)
+	}}
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		if warnings[i].File != warnings[j].File {
+			return warnings[i].File < warnings[j].File
+		}
+		return warnings[i].Line < warnings[j].Line
+	})
+	for _, xw := range warnings {
+		fmt.Fprintln(w, xw)
+	}
+	if len(warnings) > 0 {
+		return fmt.Errorf("%d problems found in XmlUI", len(warnings))
+	}
+	return nil
+}
+
+// listAssets writes every asset URL of the mod and its uses to w, followed by
+// the CustomUIAssets that seem unused, which takes building the mod in memory.
+func listAssets(o options, asJSON bool, w io.Writer) error {
+	m := newMod(o, newFileOps(o))
+	inv, err := m.Assets()
+	if err != nil {
+		return err
+	}
+	for _, uses := range inv {
+		for i := range uses {
+			uses[i].File = relToMod(o, uses[i].File)
+		}
+	}
+	// only the references are wanted, so the build needn't check anything
+	m.LuaOptions.SkipCheck = true
+	m.XMLOptions = handler.XMLOptions{SkipCheck: true}
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	unused := assets.UnusedUIAssets(m.Data)
+
+	if asJSON {
+		type entry struct {
+			URL  string       `json:"url"`
+			Uses []assets.Use `json:"uses"`
+		}
+		report := struct {
+			Assets         []entry          `json:"assets"`
+			UnusedUIAssets []assets.UIAsset `json:"unusedUIAssets"`
+		}{Assets: []entry{}, UnusedUIAssets: unused}
+		for _, url := range inv.URLs() {
+			report.Assets = append(report.Assets, entry{URL: url, Uses: inv[url]})
+		}
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	if err := inv.WriteText(w); err != nil {
+		return err
+	}
+	if len(unused) > 0 {
+		fmt.Fprintf(w, "\nCustomUIAssets not referred to by any XmlUI or script:\n")
+		for _, a := range unused {
+			owner := a.GUID
+			if owner == "" {
+				owner = "Global"
+			}
+			fmt.Fprintf(w, "  %-6s  %s  %s\n", owner, a.Name, a.URL)
+		}
+	}
+	log.Printf("%d asset URLs, %d CustomUIAssets seemingly unused", len(inv), len(unused))
+	return nil
+}
+
+// rewriteAssets replaces the asset URLs of the mod by the mapping read from
+// mappingPath, writing every changed source file back and what changed to w.
+func rewriteAssets(o options, mappingPath string, w io.Writer) error {
+	raw, err := file.ReadRawFile(mappingPath)
+	if err != nil {
+		return fmt.Errorf("ReadRawFile(%s): %v", mappingPath, err)
+	}
+	mapping, err := assets.NewMapping(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %v", mappingPath, err)
+	}
+	ops := newFileOps(o)
+	m := newMod(o, ops)
+	changed, err := m.RewriteAssets(ops.Root, ops.ModSettings, ops.Objs, mapping.Rewrite)
+	names := make([]string, 0, len(changed))
+	total := 0
+	for f, n := range changed {
+		names = append(names, f)
+		total += n
+	}
+	sort.Strings(names)
+	for _, f := range names {
+		fmt.Fprintf(w, "%s: %d URLs rewritten\n", relToMod(o, f), changed[f])
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("rewrote %d URLs in %d files", total, len(changed))
+	return nil
+}
+
+// verifyAssets builds the mod in memory and writes every use of an asset URL
+// whose file is missing or empty in the mirror to w.
+func verifyAssets(o options, mirrorDir string, asJSON bool, w io.Writer) error {
+	mirror, err := assets.OpenMirror(mirrorDir)
+	if err != nil {
+		return fmt.Errorf("OpenMirror(%s): %v", mirrorDir, err)
+	}
+	m := newMod(o, newFileOps(o))
+	// only the URLs are wanted, so the build needn't check anything
+	m.LuaOptions.SkipCheck = true
+	m.XMLOptions = handler.XMLOptions{SkipCheck: true}
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	inv := assets.InMod(m.Data)
+	problems := mirror.Verify(inv)
+
+	if asJSON {
+		report := struct {
+			Mirror   string          `json:"mirror"`
+			URLs     int             `json:"urls"`
+			Problems []assets.Result `json:"problems"`
+		}{mirrorDir, len(inv), problems}
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", b)
+	} else {
+		for _, r := range problems {
+			owner := r.GUID
+			if owner == "" {
+				owner = "mod"
+			}
+			fmt.Fprintf(w, "%-6s  %-7s  %s  %s\n", owner, r.Problem, r.Field, r.URL)
+			if r.Problem == assets.Missing {
+				fmt.Fprintf(w, "        expected %s\n", r.Path)
+			} else {
+				fmt.Fprintf(w, "        %s\n", r.Path)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d uses of asset URLs have no file in %s", len(problems), mirrorDir)
+	}
+	log.Printf("all %d asset URLs found in %s", len(inv), mirrorDir)
+	return nil
+}
+
+// relToMod shows path relative to the mod directory where it is inside it.
+func relToMod(o options, path string) string {
+	if rel, err := filepath.Rel(o.moddir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// checkCalls builds the mod in memory, like validate, and writes every
+// function called by name that its script doesn't define to w.
+func checkCalls(o options, w io.Writer) error {
+	m := newMod(o, newFileOps(o))
+	m.XMLOptions = handler.XMLOptions{SkipCheck: true}
+	m.SourceMaps = bundler.NewSourceMaps()
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	problems, err := m.CheckCalls()
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d calls of undefined functions found", len(problems))
+	}
+	return nil
+}
+
+// resolveNames writes, for each require (or Include when isXML) in names, the
+// file it is read from followed by every later candidate that it shadows.
+func resolveNames(o options, names []string, isXML bool, w io.Writer) error {
+	ops := newFileOps(o)
+	reader, ext := ops.Lua, ".ttslua"
+	if isXML {
+		reader, ext = ops.XML, ".xml"
+	}
+	missing := []string{}
+	for _, n := range names {
+		fname := n
+		if !strings.HasSuffix(fname, ext) {
+			fname += ext
+		}
+		found := reader.ResolveAll(fname)
+		if len(found) == 0 {
+			fmt.Fprintf(w, "%s: not found\n", n)
+			missing = append(missing, n)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", n, found[0])
+		for _, f := range found[1:] {
+			fmt.Fprintf(w, "  shadows %s\n", f)
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "searched:\n")
+		for _, p := range reader.ReadPaths() {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+		return fmt.Errorf("could not resolve %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// writeBundleReport reports the modules bundled into every script of data to
+// path: as JSON if it ends in .json, otherwise as text, to stdout for "-".
+func writeBundleReport(data types.J, path string) error {
+	rep, err := bundler.ReportMod(data)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		return rep.WriteText(os.Stdout)
+	}
+	var b bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rep)
+	} else {
+		err = rep.WriteText(&b)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// scriptPos matches a line on its own, or a position as TTS reports it in
+// script errors: "chunk_3:(1204,5-20)".
+var scriptPos = regexp.MustCompile(`^(?:[^:()]*:)?\((\d+),(\d+)(?:-\d+)?\)$|^(\d+)$`)
+
+// parseScriptPos reads a position in a built script. col is 0 when not given.
+func parseScriptPos(s string) (line, col int, ok bool) {
+	m := scriptPos.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, 0, false
+	}
+	if m[3] != "" {
+		line, _ = strconv.Atoi(m[3])
+		return line, 0, line > 0
+	}
+	line, _ = strconv.Atoi(m[1])
+	col, _ = strconv.Atoi(m[2])
+	return line, col, line > 0
+}
+
+// locate prints the source of a line of owner's built script, read from the
+// source maps at mapPath, or beside the build output when mapPath is empty.
+func locate(o options, mapPath, owner string, line, col int, w io.Writer) error {
+	if mapPath == "" {
+		out := o.modfile
+		if out == "" {
+			out = filepath.Join(o.moddir, "output.json")
+		}
+		mapPath = bundler.SidecarPath(out)
+	}
+	maps, err := bundler.LoadSourceMaps(mapPath)
+	if err != nil {
+		return fmt.Errorf("reading source maps (build the mod to write them): %v", err)
+	}
+	if strings.EqualFold(owner, bundler.GlobalScript) {
+		owner = bundler.GlobalScript
+	}
+	m, ok := maps.Get(owner)
+	if !ok {
+		return fmt.Errorf("%s has no script for %q", mapPath, owner)
+	}
+	seg, fileLine, ok := m.Locate(line)
+	if !ok {
+		return fmt.Errorf("line %d of %s's script is part of the bundler, not of any source file", line, owner)
+	}
+	pos := fmt.Sprintf("%s:%d", seg.File, fileLine)
+	if col > 0 {
+		pos += fmt.Sprintf(":%d", col)
+	}
+	if seg.Inline {
+		pos += " (inline script)"
+	}
+	fmt.Fprintln(w, pos)
+	return nil
+}
+
+// reverse splits o.modfile (or the single object in o.objin) into a directory
+// structure under o.moddir (or o.objout).
+func reverse(o options) error {
+	ops := newFileOps(o)
+	if o.objin != "" {
+		o.modfile = o.objin
+		ops.Objs = file.NewJSONOps(filepath.Dir(o.objout))
+	}
+
+	raw, err := prepForReverse(o.moddir, o.project.SubDirs(), o.modfile)
+	if err != nil {
+		return fmt.Errorf("prepForReverse (%s) failed : %v", o.modfile, err)
+	}
+
+	// Clear the objects directory to avoid orphaned files (by removing and
+	// recreating). This must run only after prepForReverse has successfully
+	// read and parsed the mod file, so a bad --modfile path can't wipe
+	// objects/ before failing (issue #90).
+	if o.objin == "" && !o.merge {
+		if err := ops.ObjDir.Clear(); err != nil {
+			return fmt.Errorf("Failed to clear objects directory before writing: %v", err)
+		}
+	}
+
+	r := newReverser(o, ops)
+	if o.merge {
+		return mergeReverse(o, ops, r, raw, os.Stdout)
+	}
+	if err := r.Write(raw); err != nil {
+		return fmt.Errorf("reverse.Write(<%s>) failed : %v", o.modfile, err)
+	}
+	return nil
+}
+
+// newReverser makes the Reverser writing a mod, or the object in o.objin,
+// through ops.
+func newReverser(o options, ops project.Ops) mod.Reverser {
+	r := mod.Reverser{
+		ModSettingsWriter:  ops.ModSettings,
+		LuaWriter:          ops.Lua,
+		XMLWriter:          ops.XML,
+		ObjWriter:          ops.Objs,
+		ObjDirCreator:      ops.ObjDir,
+		RootWrite:          ops.Root,
+		OnlyObjState:       o.objin,
+		Jobs:               o.jobs,
+		InlineLimit:        o.project.Thresholds.Script,
+		SettingsObjLimit:   o.project.Thresholds.ModSettingsObject,
+		SettingsArrayLimit: o.project.Thresholds.ModSettingsArray,
+	}
+	if o.writeToSrc {
+		r.LuaSrcWriter = ops.LuaSrc
+		r.XMLSrcWriter = ops.XMLSrc
+	}
+	return r
+}
+
+// mergeReverse writes raw over the existing objects directory, keeping the
+// files of every object already in it, and writes what became of each object
+// to w.
+func mergeReverse(o options, ops project.Ops, r mod.Reverser, raw map[string]interface{}, w io.Writer) error {
+	r.Layout = &objects.Layout{}
+	if _, err := os.Stat(filepath.Join(o.moddir, o.project.Dirs.Objects)); err == nil {
+		p := &objects.Parser{J: ops.Objs, Dir: ops.ObjDir, Jobs: o.jobs}
+		layout, err := p.Layout()
+		if err != nil {
+			return fmt.Errorf("reading the objects directory to merge into: %v", err)
+		}
+		r.Layout = layout
+	}
+	changes, err := r.Merge(raw, ops.ObjDir)
+	if err != nil {
+		return fmt.Errorf("reverse.Merge(<%s>) failed : %v", o.modfile, err)
+	}
+	updated := 0
+	for _, c := range changes {
+		if c.Kind == objects.Updated {
+			updated++
+			continue
+		}
+		fmt.Fprintln(w, c)
+	}
+	fmt.Fprintf(w, "%d objects updated in place\n", updated)
+	return nil
+}
+
+// mergeSave merges the changes the TTS save in savePath made to the mod built
+// as basePath into o.moddir, writing what it applied and the conflicts it
+// found to w, and them as json to reportPath if set. Conflicting fields keep
+// the values of o.moddir.
+func mergeSave(o options, basePath, savePath, reportPath string, w io.Writer) error {
+	base, err := file.ReadRawFile(basePath)
+	if err != nil {
+		return err
+	}
+	save, err := file.ReadRawFile(savePath)
+	if err != nil {
+		return err
+	}
+	ops := newFileOps(o)
+	m := newMod(o, ops)
+	m.LuaOptions.SkipCheck = true
+	m.XMLOptions = handler.XMLOptions{SkipCheck: true}
+	if err := m.GenerateFromConfig(); err != nil {
+		return fmt.Errorf("generateMod(<config>) : %v", err)
+	}
+	merged, applied, conflicts, err := mod.MergeMods(base, m.Data, save)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d changes applied from %s:\n", len(applied), savePath)
+	for _, a := range applied {
+		fmt.Fprintf(w, "  %s\n", a)
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(w, "%d conflicts, where %s keeps its own values:\n", len(conflicts), o.moddir)
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "  %s\n", c)
+		}
+	}
+	if reportPath != "" {
+		b, err := json.MarshalIndent(map[string]interface{}{
+			"base":      basePath,
+			"save":      savePath,
+			"applied":   applied,
+			"conflicts": conflicts,
+		}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("json.MarshalIndent(): %v", err)
+		}
+		if err := os.WriteFile(reportPath, append(b, '\n'), 0644); err != nil {
+			return fmt.Errorf("os.WriteFile(%s): %v", reportPath, err)
+		}
+	}
+
+	o.modfile = savePath
+	if err := mergeReverse(o, ops, newReverser(o, ops), merged, w); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d conflicts found merging %s", len(conflicts), savePath)
+	}
+	return nil
+}
+
+// watchAndBuild runs build once, then again after every debounced burst of
+// changes under paths, other than to the files in ignore. A change to one of
+// the files in restart, which build only reads once, is logged as needing a
+// restart instead. Build errors are reported but never end the session.
+func watchAndBuild(paths, ignore, restart []string, build func() error) error {
+	report := func() {
+		start := time.Now()
+		if err := build(); err != nil {
+			log.Printf("build failed: %v", err)
+			return
+		}
+		log.Printf("build succeeded in %v", time.Since(start).Round(time.Millisecond))
+	}
+	stop := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		close(stop)
+	}()
+
+	w := file.NewWatcher(paths)
+	w.Ignore = ignore
+	// the first build runs after the first snapshot, so that files saved
+	// while it runs are built again
+	w.OnStart = func() {
+		report()
+		log.Printf("watching %d paths for changes, press Ctrl+C to stop", len(paths))
+	}
+	needsRestart := map[string]bool{}
+	for _, r := range restart {
+		needsRestart[r] = true
+	}
+	return w.Watch(stop, func(changed []string) {
+		rebuild := false
+		for _, c := range changed {
+			if needsRestart[c] {
+				log.Printf("changed: %s; stop and run the command again for its settings to apply", c)
+				continue
+			}
+			log.Printf("changed: %s", c)
+			rebuild = true
+		}
+		if rebuild {
+			report()
+		}
+	})
+}
+
+// prepForReverse creates the expected subdirectories in config path
+func prepForReverse(cPath string, subDirs []string, modfile string) (types.J, error) {
+	for _, s := range subDirs {
+		p := filepath.Join(cPath, s)
+		if _, err := os.Stat(p); err == nil {
+			// directory already exists
+		} else if os.IsNotExist(err) {
+			err = os.Mkdir(p, 0777)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("undefined error checking for subdirectory %s : %v", s, err)
+		}
+	}
+
+	mFile, err := os.Open(modfile)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open(%s) : %v", modfile, err)
+	}
+
+	defer mFile.Close()
+
+	b, err := io.ReadAll(mFile)
+	if err != nil {
+		return nil, err
+	}
+	var o types.J
+	err = json.Unmarshal(b, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
@@ -0,0 +1,254 @@
+package bundler
+
+import (
+	"ModCreator/file"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The Atom and VSCode TTS plugins paste a file in place of an #include
+// directive, between a pair of markers naming it:
+//
+//	----#include lib/util
+//	...contents of lib/util.ttslua...
+//	----#include lib/util
+//
+// "#include name" is looked up next to the file containing it, then on the
+// search paths; "#include <name>" only on the search paths.
+var (
+	includeDirective = regexp.MustCompile(`^(\s*)#include\s+(\S.*?)\s*$`)
+	includeMarker    = regexp.MustCompile(`^(\s*)----#include\s+(\S.*?)\s*$`)
+)
+
+// HasIncludes reports whether src has an #include directive.
+func HasIncludes(src string) bool {
+	for _, l := range strings.Split(src, "\n") {
+		if includeDirective.MatchString(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// includeCandidates lists the modules an include in module may name, in the
+// order they are looked for. Modules are named like required ones, without the
+// .ttslua extension.
+func includeCandidates(module, name string) []string {
+	global := strings.HasPrefix(name, "<") && strings.HasSuffix(name, ">")
+	n := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "<"), ">"), ".ttslua")
+	if global || module == Rootname {
+		return []string{n}
+	}
+	if rel := path.Join(path.Dir(module), n); rel != n {
+		return []string{rel, n}
+	}
+	return []string{n}
+}
+
+// ExpandIncludes pastes the files named by the #include directives of src, the
+// source of module, in place of the directives.
+func ExpandIncludes(src, module string, l file.TextReader) (string, error) {
+	return expandIncludes(src, module, l, []string{module})
+}
+
+// expandIncludes replaces every #include directive of src, the source of
+// module, with the file it names between markers. chain lists the modules
+// being expanded, to catch files including each other.
+func expandIncludes(src, module string, l file.TextReader, chain []string) (string, error) {
+	if !strings.Contains(src, "#include") {
+		return src, nil
+	}
+	lines := strings.Split(src, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		m := includeDirective.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if m == nil {
+			out = append(out, line)
+			continue
+		}
+		indent, name := m[1], m[2]
+		included, body, err := readInclude(module, name, l)
+		if err != nil {
+			return "", &ModuleError{Module: module, Err: err}
+		}
+		for i, c := range chain {
+			if c == included {
+				cycle := append(append([]string{}, chain[i:]...), included)
+				return "", &CycleError{Chain: cycle, Include: true}
+			}
+		}
+		body, err = expandIncludes(body, included, l, append(append([]string{}, chain...), included))
+		if err != nil {
+			return "", err
+		}
+		marker := indent + "----#include " + name
+		out = append(out, marker, body, marker)
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// includedModule picks which of the modules an include in module may name it
+// was read from, the first for which exists is true. With exists nil, or when
+// none exist, it is the first looked for.
+func includedModule(module, name string, exists func(module string) bool) string {
+	candidates := includeCandidates(module, name)
+	if exists != nil {
+		for _, c := range candidates {
+			if exists(c) {
+				return c
+			}
+		}
+	}
+	return candidates[0]
+}
+
+func readInclude(module, name string, l file.TextReader) (string, string, error) {
+	var first error
+	for _, c := range includeCandidates(module, name) {
+		body, err := l.EncodeFromFile(c + ".ttslua")
+		if err == nil {
+			return c, body, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return "", "", fmt.Errorf("#include %s: %v", name, first)
+}
+
+// includePairs pairs up the opening and closing markers of included files in
+// lines, by index. Markers without a partner are left out.
+func includePairs(lines []string) map[int]int {
+	pairs := map[int]int{}
+	type open struct {
+		at   int
+		text string
+	}
+	stack := []open{}
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if !includeMarker.MatchString(l) {
+			continue
+		}
+		// a file can't include itself, so the next marker naming the same
+		// file closes it
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].text == l {
+				pairs[stack[j].at] = i
+				stack = stack[:j]
+				l = ""
+				break
+			}
+		}
+		if l != "" {
+			stack = append(stack, open{at: i, text: l})
+		}
+	}
+	return pairs
+}
+
+// collapseIncludes puts back the #include directives that expandIncludes
+// replaced in body, the source of module, adding the contents of each included
+// file to modules.
+func collapseIncludes(body, module string, modules map[string]string) string {
+	if !strings.Contains(body, "----#include") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	pairs := includePairs(lines)
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		end, ok := pairs[i]
+		if !ok {
+			out = append(out, lines[i])
+			continue
+		}
+		m := includeMarker.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+		indent, name := m[1], m[2]
+		// the file is written where it is looked for first, which it will
+		// then be read from
+		included := includedModule(module, name, nil)
+		modules[included] = collapseIncludes(strings.Join(lines[i+1:end], "\n"), included, modules)
+		out = append(out, indent+"#include "+name)
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// lineRun is a run of lines of an expanded module that came from one file.
+type lineRun struct {
+	// start and end are the first and last lines of the run, from 1
+	start, end int
+	module     string
+	// line is the line of module that start came from
+	line int
+}
+
+// includeRuns splits the lines of body, the expanded source of module, by the
+// file each came from, using exists as for includedModule. An opening marker
+// comes from the #include directive it replaced; closing markers come from
+// nowhere.
+func includeRuns(body, module string, exists func(module string) bool) []lineRun {
+	lines := strings.Split(body, "\n")
+	pairs := includePairs(lines)
+	type frame struct {
+		module string
+		next   int
+		close  int
+	}
+	stack := []*frame{{module: module, next: 1, close: -1}}
+	runs := []lineRun{}
+	emit := func(n int, module string, line int) {
+		if len(runs) > 0 {
+			last := &runs[len(runs)-1]
+			if last.end == n-1 && last.module == module && last.line+n-last.start == line {
+				last.end = n
+				return
+			}
+		}
+		runs = append(runs, lineRun{start: n, end: n, module: module, line: line})
+	}
+	for i := range lines {
+		top := stack[len(stack)-1]
+		if i == top.close {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		emit(i+1, top.module, top.next)
+		top.next++
+		if end, ok := pairs[i]; ok {
+			m := includeMarker.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+			stack = append(stack, &frame{module: includedModule(top.module, m[2], exists), next: 1, close: end})
+		}
+	}
+	return runs
+}
+
+// LocateSource is LocateLine, following the markers of included files to the
+// module a line came from. exists reports whether a module can be read, to
+// tell where an included file was found; it may be nil. Lines outside of any
+// file, like the closing markers, are left to the module they are in.
+func LocateSource(rawlua string, line int, exists func(module string) bool) (module string, moduleLine int, ok bool) {
+	module, moduleLine, ok = LocateLine(rawlua, line)
+	if !ok {
+		return "", 0, false
+	}
+	body := rawlua
+	if IsBundled(rawlua) {
+		for _, r := range moduleRanges(rawlua) {
+			if r.name == module && line >= r.start && line <= r.end {
+				lines := strings.Split(rawlua, "\n")
+				body = strings.Join(lines[r.start-1:r.end-1], "\n")
+				break
+			}
+		}
+	}
+	for _, run := range includeRuns(body, module, exists) {
+		if moduleLine >= run.start && moduleLine <= run.end {
+			return run.module, run.line + moduleLine - run.start, true
+		}
+	}
+	return module, moduleLine, true
+}
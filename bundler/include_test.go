@@ -0,0 +1,173 @@
+package bundler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBundleIncludes(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		fs    map[string]string
+		want  string
+	}{
+		{
+			name:  "plain",
+			input: "#include lib/util\nprint(util())",
+			fs:    map[string]string{"lib/util.ttslua": "function util() return 1 end"},
+			want:  "----#include lib/util\nfunction util() return 1 end\n----#include lib/util\nprint(util())",
+		},
+		{
+			name:  "angle brackets, indent and extension",
+			input: "do\n  #include <lib/util.ttslua>\nend",
+			fs:    map[string]string{"lib/util.ttslua": "x = 1"},
+			want:  "do\n  ----#include <lib/util.ttslua>\nx = 1\n  ----#include <lib/util.ttslua>\nend",
+		},
+		{
+			name:  "nested, next to the including file first",
+			input: "#include lib/a",
+			fs: map[string]string{
+				"lib/a.ttslua": "#include b\n#include <b>",
+				"lib/b.ttslua": "lib_b = 1",
+				"b.ttslua":     "b = 1",
+			},
+			want: "----#include lib/a\n----#include b\nlib_b = 1\n----#include b\n----#include <b>\nb = 1\n----#include <b>\n----#include lib/a",
+		},
+		{
+			name:  "falls back to the search paths",
+			input: "#include lib/a",
+			fs: map[string]string{
+				"lib/a.ttslua": "#include b",
+				"b.ttslua":     "b = 1",
+			},
+			want: "----#include lib/a\n----#include b\nb = 1\n----#include b\n----#include lib/a",
+		},
+		{
+			name:  "not a directive",
+			input: "local n = #include\n-- #include x",
+			fs:    map[string]string{},
+			want:  "local n = #include\n-- #include x",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Bundle(tc.input, &fakeLuaReader{fs: tc.fs})
+			if err != nil {
+				t.Fatalf("Bundle(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
+
+func TestBundleIncludesErrors(t *testing.T) {
+	_, err := Bundle("#include a", &fakeLuaReader{fs: map[string]string{
+		"a.ttslua": "#include b",
+		"b.ttslua": "#include a",
+	}})
+	var ce *CycleError
+	if !errors.As(err, &ce) {
+		t.Fatalf("want a CycleError, got %v", err)
+	}
+	if want := "circular #include: a.ttslua -> b.ttslua -> a.ttslua"; ce.Error() != want {
+		t.Errorf("want %q, got %q", want, ce.Error())
+	}
+
+	_, err = Bundle("require('a')", &fakeLuaReader{fs: map[string]string{"a.ttslua": "#include missing"}})
+	if err == nil || !strings.Contains(err.Error(), "a.ttslua: #include missing") {
+		t.Errorf("want an error naming the missing include, got %v", err)
+	}
+}
+
+func TestUnbundleIncludes(t *testing.T) {
+	fs := map[string]string{
+		"lib/a.ttslua": "#include b\nlocal a = 1",
+		"lib/b.ttslua": "b = 1\n",
+		"c.ttslua":     "c = 1\n  #include <lib/a>",
+	}
+	root := "#include lib/a\nrequire('c')"
+	bundled, err := Bundle(root, &fakeLuaReader{fs: fs})
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	got, _, err := UnbundleAll(bundled)
+	if err != nil {
+		t.Fatalf("UnbundleAll(): %v", err)
+	}
+	want := map[string]string{
+		Rootname: root,
+		"lib/a":  fs["lib/a.ttslua"],
+		"lib/b":  fs["lib/b.ttslua"],
+		"c":      fs["c.ttslua"],
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	// a script with includes but no requires isn't bundled
+	expanded, err := Bundle("#include lib/a", &fakeLuaReader{fs: fs})
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	if IsBundled(expanded) {
+		t.Fatalf("want a script that isn't bundled, got\n%s", expanded)
+	}
+	got, _, err = UnbundleAll(expanded)
+	if err != nil {
+		t.Fatalf("UnbundleAll(): %v", err)
+	}
+	want = map[string]string{Rootname: "#include lib/a", "lib/a": fs["lib/a.ttslua"], "lib/b": fs["lib/b.ttslua"]}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestLocateSourceIncludes(t *testing.T) {
+	fs := map[string]string{
+		"lib/a.ttslua": "a1\n#include b\na3",
+		"lib/b.ttslua": "b1\nb2",
+		"c.ttslua":     "#include lib/a\nc2",
+	}
+	bundled, err := Bundle("r1\n#include lib/a\nr3\nrequire('c')", &fakeLuaReader{fs: fs})
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	want := map[string]string{
+		"r1": Rootname + ":1", "r3": Rootname + ":3",
+		"a1": "lib/a:1", "a3": "lib/a:3",
+		"b1": "lib/b:1", "b2": "lib/b:2",
+		"c2": "c:2",
+	}
+	got := map[string]string{}
+	for i, l := range strings.Split(bundled, "\n") {
+		if _, ok := want[l]; !ok {
+			continue
+		}
+		module, line, ok := LocateSource(bundled, i+1, nil)
+		if !ok {
+			t.Errorf("LocateSource(%d) found nothing for %q", i+1, l)
+			continue
+		}
+		got[l] = fmt.Sprintf("%s:%d", module, line)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	m := MapSource(bundled, func(module string) (string, bool) { return module + ".ttslua", false }, nil)
+	for i, l := range strings.Split(bundled, "\n") {
+		if l != "b2" {
+			continue
+		}
+		seg, line, ok := m.Locate(i + 1)
+		if !ok || seg.File != "lib/b.ttslua" || line != 2 {
+			t.Errorf("Locate(%d) = %v, %d, %v; want lib/b.ttslua:2", i+1, seg, line, ok)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package bundler
 
 import (
 	"ModCreator/file"
+	luaparse "ModCreator/lua"
 	"fmt"
+	"log"
 	"regexp"
 	"sort"
 	"strings"
@@ -96,8 +98,24 @@ func AnalyzeBundle(rawlua string, log func(s string, a ...interface{})) {
 // detected locally from the bundle's `return __bundle_require("...")` line rather
 // than read from or written to package state, so concurrent or repeated calls
 // cannot contaminate one another. When the input is not bundled it is returned
-// as the sole module under the canonical Rootname.
+// as the sole module under the canonical Rootname. Files pasted in by #include
+// are put back as directives, and returned as modules of their own.
 func UnbundleAll(rawlua string) (map[string]string, string, error) {
+	scripts, rootName, err := unbundleAll(rawlua)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		scripts[name] = collapseIncludes(scripts[name], name, scripts)
+	}
+	return scripts, rootName, nil
+}
+
+func unbundleAll(rawlua string) (map[string]string, string, error) {
 	if !IsBundled(rawlua) {
 		return map[string]string{Rootname: rawlua}, Rootname, nil
 	}
@@ -165,37 +183,80 @@ func Unbundle(rawlua string) (string, error) {
 
 // Bundle grabs all dependencies and creates a single luascript
 func Bundle(rawlua string, l file.TextReader) (string, error) {
+	return BundleWith(rawlua, l, Options{})
+}
+
+// Options change how BundleWith bundles a script.
+type Options struct {
+	// Shared, when set, picks the modules registered as stubs fetching them
+	// from Global. The modules they require are still bundled, since the stub
+	// loads them with the script's own require.
+	Shared func(module string) bool
+	// AllowCycles accepts modules requiring each other in a circle, as long as
+	// one of the requires in the circle is inside a function. The bundle's
+	// loader gives a module that is still loading nil, so a circle of requires
+	// that all run as the modules load can never work.
+	AllowCycles bool
+}
+
+// BundleWith bundles like Bundle, with opts.
+func BundleWith(rawlua string, l file.TextReader, opts Options) (string, error) {
 	if IsBundled(rawlua) {
 		return rawlua, nil
 	}
+	shared := opts.Shared
+	if shared == nil {
+		shared = func(string) bool { return false }
+	}
+	root, err := ExpandIncludes(rawlua, Rootname, l)
+	if err != nil {
+		return "", err
+	}
 	reqs := map[string]string{
-		Rootname: rawlua,
+		Rootname: root,
 	}
+	// graph holds, for each module, the modules it requires; true when one of
+	// the requires runs as the module loads
+	graph := map[string]map[string]bool{}
 	todo := []string{Rootname}
 	for len(todo) > 0 {
 		fname := todo[0]
 		todo = todo[1:] // pop first element off
 
 		scriptToInvestigate := reqs[fname]
-		reqsToLoad, err := getAllReqValues(scriptToInvestigate)
+		reqsToLoad, dynamic, err := findRequires(scriptToInvestigate)
 		if err != nil {
-			return "", fmt.Errorf("for %s getAllReqValues(%s): %v", fname, scriptToInvestigate, err)
+			return "", &ModuleError{Module: fname, Err: err}
+		}
+		for _, p := range dynamic {
+			log.Printf("warning: %s:%d:%d: require with a non-literal argument can't be bundled", describeModule(fname), p.Line, p.Col)
 		}
 		sort.Slice(reqsToLoad, func(i int, j int) bool {
-			return reqsToLoad[i] < reqsToLoad[j]
+			return reqsToLoad[i].Name < reqsToLoad[j].Name
 		})
+		graph[fname] = map[string]bool{}
 		for _, r := range reqsToLoad {
-			val, err := l.EncodeFromFile(r + ".ttslua")
+			graph[fname][r.Name] = graph[fname][r.Name] || !r.Deferred
+			if _, ok := reqs[r.Name]; ok {
+				continue
+			}
+			val, err := l.EncodeFromFile(r.Name + ".ttslua")
 			if err != nil {
-				return "", fmt.Errorf("EncodeFromFile(%s) : %v", r, err)
+				return "", fmt.Errorf("EncodeFromFile(%s) : %v", r.Name, err)
+			}
+			if val, err = ExpandIncludes(val, r.Name, l); err != nil {
+				return "", err
 			}
-			reqs[r] = val
+			reqs[r.Name] = val
+			todo = append(todo, r.Name)
 		}
-		todo = append(todo, reqsToLoad...)
 	}
 	if len(reqs) == 1 {
 		// if there were no requires to load in, no need to bundle
-		return rawlua, nil
+		return root, nil
+	}
+	if chain := findCycle(graph, opts.AllowCycles); chain != nil {
+		return "", &CycleError{Chain: chain, Eager: cycleIsEager(graph, chain)}
 	}
 
 	bundlestr := metaprefix + "\n"
@@ -208,6 +269,9 @@ func Bundle(rawlua string, l file.TextReader) (string, error) {
 
 	for _, k := range sortedReqKeys {
 		v := reqs[k]
+		if k != Rootname && shared(k) {
+			v = SharedStub(k)
+		}
 		bundlestr += strings.Replace(funcprefix, funcprefixReplace, k, 1) + "\n"
 		bundlestr += v + "\n"
 		bundlestr += funcsuffix + "\n"
@@ -218,19 +282,159 @@ func Bundle(rawlua string, l file.TextReader) (string, error) {
 	return bundlestr, nil
 }
 
-func getAllReqValues(lua string) ([]string, error) {
-	rsxp := regexp.MustCompile(`require\((\\)?\"[-a-zA-Z0-9/._@]+(\\)?\"\)`)
-	reqs := rsxp.FindAllString(lua, -1)
+// CycleError is a circle of modules requiring each other.
+type CycleError struct {
+	// Chain lists the modules of the circle in the order they require each
+	// other, starting and ending with the same module.
+	Chain []string
+	// Eager is set when every require in the circle runs as the modules load.
+	Eager bool
+	// Include is set for files including each other with #include.
+	Include bool
+	// XML is set for XML files including each other with <Include>.
+	XML bool
+	// Owner is the GUID of the object whose script (or XmlUI) it is, or
+	// GlobalScript, filled in by the caller when known.
+	Owner string
+	// Root names the file of the root script, filled in by the caller when
+	// known.
+	Root string
+}
+
+func (e *CycleError) Error() string {
+	what := "require"
+	switch {
+	case e.Include:
+		what = "#include"
+	case e.XML:
+		what = "Include"
+	}
+	names := make([]string, len(e.Chain))
+	for i, m := range e.Chain {
+		switch {
+		case m == Rootname && e.Root != "":
+			names[i] = e.Root
+		case m != Rootname && e.XML:
+			names[i] = m + ".xml"
+		default:
+			names[i] = describeModule(m)
+		}
+	}
+	msg := fmt.Sprintf("circular %s: %s", what, strings.Join(names, " -> "))
+	switch {
+	case e.Owner != "" && e.XML:
+		msg = fmt.Sprintf("XmlUI of %s: %s", e.Owner, msg)
+	case e.Owner != "":
+		msg = fmt.Sprintf("script of %s: %s", e.Owner, msg)
+	}
+	if e.Eager {
+		msg += " (every one of these requires runs as the modules load, so one of them would get nil)"
+	}
+	return msg
+}
+
+// findCycle looks for a circle of requires reachable from the root script,
+// only following requires that run as the modules load when eagerOnly is set.
+// It returns the first circle found, or nil.
+func findCycle(graph map[string]map[string]bool, eagerOnly bool) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	stack := []string{}
+	var visit func(m string) []string
+	visit = func(m string) []string {
+		state[m] = visiting
+		stack = append(stack, m)
+		next := make([]string, 0, len(graph[m]))
+		for r, eager := range graph[m] {
+			if eager || !eagerOnly {
+				next = append(next, r)
+			}
+		}
+		sort.Strings(next)
+		for _, r := range next {
+			switch state[r] {
+			case visiting:
+				for i, s := range stack {
+					if s == r {
+						return append(append([]string{}, stack[i:]...), r)
+					}
+				}
+			case unvisited:
+				if chain := visit(r); chain != nil {
+					return chain
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[m] = done
+		return nil
+	}
+	return visit(Rootname)
+}
+
+func cycleIsEager(graph map[string]map[string]bool, chain []string) bool {
+	for i := 0; i+1 < len(chain); i++ {
+		if !graph[chain[i]][chain[i+1]] {
+			return false
+		}
+	}
+	return true
+}
+
+// ModuleError is a problem with one module of a bundle, which is the root
+// script or a file it required.
+type ModuleError struct {
+	Module string
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	return fmt.Sprintf("%s: %v", describeModule(e.Module), e.Err)
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+func describeModule(name string) string {
+	if name == Rootname {
+		return "root script"
+	}
+	return name + ".ttslua"
+}
 
+// getAllReqValues lists the modules required by lua. Requires whose argument
+// is not a string literal can't be bundled and are returned separately.
+func getAllReqValues(lua string) ([]string, []luaparse.Pos, error) {
+	reqs, dynamic, err := findRequires(lua)
+	if err != nil {
+		return nil, nil, err
+	}
 	fnames := []string{}
-	for _, req := range reqs {
-		filexp := regexp.MustCompile(`require\(\\?"([-a-zA-Z0-9/._@]+)\\?"\)`)
-		matches := filexp.FindSubmatch([]byte(req))
-		if len(matches) != 2 {
-			return nil, fmt.Errorf("regex error parsing requirement (%s)", req)
+	for _, r := range reqs {
+		fnames = append(fnames, r.Name)
+	}
+	return fnames, dynamic, nil
+}
+
+// findRequires is getAllReqValues keeping the details of each require.
+func findRequires(lua string) ([]luaparse.Require, []luaparse.Pos, error) {
+	all, err := luaparse.FindRequires(lua)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs := []luaparse.Require{}
+	dynamic := []luaparse.Pos{}
+	for _, r := range all {
+		if r.Dynamic {
+			dynamic = append(dynamic, r.Pos)
+			continue
 		}
-		f := matches[1]
-		fnames = append(fnames, string(f))
+		reqs = append(reqs, r)
 	}
-	return fnames, nil
+	return reqs, dynamic, nil
 }
@@ -1,7 +1,9 @@
 package bundler
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
 
@@ -507,6 +509,165 @@ func TestBundleNoRequires(t *testing.T) {
 	}
 }
 
+func TestBundleIgnoresCommentedRequires(t *testing.T) {
+	fr := &fakeLuaReader{
+		fs: map[string]string{},
+	}
+	input := `-- local old = require("removed/Module")
+local msg = "call require('x') to load x"`
+
+	got, err := Bundle(input, fr)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if diff := cmp.Diff(input, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestBundleCycles(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		root        string
+		fs          map[string]string
+		allowCycles bool
+		wantChain   []string
+		wantEager   bool
+		wantModules []string
+	}{
+		{
+			name: "eager cycle",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `local b = require("b")`,
+				"b.ttslua": `local a = require("a")`,
+			},
+			wantChain: []string{"a", "b", "a"},
+			wantEager: true,
+		},
+		{
+			name: "eager cycle is never allowed",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `local b = require("b")`,
+				"b.ttslua": `local c = require("c")`,
+				"c.ttslua": `if x then require("a") end`,
+			},
+			allowCycles: true,
+			wantChain:   []string{"a", "b", "c", "a"},
+			wantEager:   true,
+		},
+		{
+			name: "module requiring itself",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `require("a")`,
+			},
+			wantChain: []string{"a", "a"},
+			wantEager: true,
+		},
+		{
+			name: "through the root",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `function f() return require("__root") end`,
+			},
+			wantChain: []string{Rootname, "a", Rootname},
+		},
+		{
+			name: "deferred cycle",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `local b = require("b")`,
+				"b.ttslua": `function getA() return require("a") end`,
+			},
+			wantChain: []string{"a", "b", "a"},
+		},
+		{
+			name: "deferred cycle allowed",
+			root: `require("a")`,
+			fs: map[string]string{
+				"a.ttslua": `local b = require("b")`,
+				"b.ttslua": `function getA() return require("a") end`,
+			},
+			allowCycles: true,
+			wantModules: []string{Rootname, "a", "b"},
+		},
+		{
+			name: "shared dependency is not a cycle",
+			root: `require("a") require("b")`,
+			fs: map[string]string{
+				"a.ttslua": `require("c")`,
+				"b.ttslua": `require("c")`,
+				"c.ttslua": `c = 1`,
+			},
+			wantModules: []string{Rootname, "a", "b", "c"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := BundleWith(tc.root, &fakeLuaReader{fs: tc.fs}, Options{AllowCycles: tc.allowCycles})
+			if tc.wantChain != nil {
+				var ce *CycleError
+				if !errors.As(err, &ce) {
+					t.Fatalf("want a CycleError, got %v", err)
+				}
+				if diff := cmp.Diff(tc.wantChain, ce.Chain); diff != "" {
+					t.Errorf("want != got:\n%v\n", diff)
+				}
+				if ce.Eager != tc.wantEager {
+					t.Errorf("want Eager %v, got %v", tc.wantEager, ce.Eager)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BundleWith(): %v", err)
+			}
+			modules, _, err := UnbundleAll(got)
+			if err != nil {
+				t.Fatalf("UnbundleAll(): %v", err)
+			}
+			names := []string{}
+			for n := range modules {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			if diff := cmp.Diff(tc.wantModules, names); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
+
+func TestCycleErrorMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  *CycleError
+		want string
+	}{
+		{
+			name: "bare",
+			err:  &CycleError{Chain: []string{"a", "b", "a"}},
+			want: "circular require: a.ttslua -> b.ttslua -> a.ttslua",
+		},
+		{
+			name: "owned and eager",
+			err:  &CycleError{Chain: []string{Rootname, "a", Rootname}, Eager: true, Owner: "a1b2c3", Root: "src/obj.ttslua"},
+			want: "script of a1b2c3: circular require: src/obj.ttslua -> a.ttslua -> src/obj.ttslua (every one of these requires runs as the modules load, so one of them would get nil)",
+		},
+		{
+			name: "inline root",
+			err:  &CycleError{Chain: []string{Rootname, "a", Rootname}, Owner: GlobalScript},
+			want: "script of Global: circular require: root script -> a.ttslua -> root script",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.err.Error(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestFailedUnbundle(t *testing.T) {
 	rawlua := `  __bundle_register("__root", function(require, _LOADED, __bundle_register, __bundle_modules)
 	  MIN_VALUE = -99
@@ -537,24 +698,25 @@ func TestGetReqs(t *testing.T) {
 	for _, tc := range []struct {
 		input, name string
 		want        []string
+		wantDynamic int
 	}{
 		{
 			name:  "single inputs simple",
-			input: `local ExtensionHandler = require(\"ExtensionHandler\")\n`,
+			input: "local ExtensionHandler = require(\"ExtensionHandler\")\n",
 			want: []string{
 				"ExtensionHandler",
 			},
 		},
 		{
 			name:  "single inputs complicated",
-			input: `avefile = require(\"campaign-manager.Savefile\")\nloca`,
+			input: "avefile = require(\"campaign-manager.Savefile\")\nloca",
 			want: []string{
 				"campaign-manager.Savefile",
 			},
 		},
 		{
 			name:  "many inputs from GHE",
-			input: `local ExtensionHandler = require(\"ExtensionHandler\")\n\nlocal Savefile = require(\"campaign-manager.Savefile\")\nlocal Cleanup = require(\"campaign-manager.Cleanup\")\nlocal Achievement = require(\"campaign-manager.Achievement\")\nlocal`,
+			input: "local ExtensionHandler = require(\"ExtensionHandler\")\n\nlocal Savefile = require(\"campaign-manager.Savefile\")\nlocal Cleanup = require(\"campaign-manager.Cleanup\")\nlocal Achievement = require(\"campaign-manager.Achievement\")\nlocal",
 			want: []string{
 				"ExtensionHandler",
 				"campaign-manager.Savefile",
@@ -562,9 +724,30 @@ func TestGetReqs(t *testing.T) {
 				"campaign-manager.Achievement",
 			},
 		},
+		{
+			name: "other call forms and names",
+			input: `require "a b"
+require('c')
+require [[d/e]]`,
+			want: []string{"a b", "c", "d/e"},
+		},
+		{
+			name: "comments and strings",
+			input: `-- require("x")
+--[[ require("y") ]]
+print("require('z')")
+require("real")`,
+			want: []string{"real"},
+		},
+		{
+			name:        "dynamic",
+			input:       `local n = "lib" require(n) require("a" .. n) require("b")`,
+			want:        []string{"b"},
+			wantDynamic: 2,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := getAllReqValues(tc.input)
+			got, dynamic, err := getAllReqValues(tc.input)
 			if err != nil {
 				t.Fatalf("%v", err)
 			}
@@ -572,6 +755,9 @@ func TestGetReqs(t *testing.T) {
 			if diff := cmp.Diff(tc.want, got); diff != "" {
 				t.Errorf("want != got:\n%v\n", diff)
 			}
+			if len(dynamic) != tc.wantDynamic {
+				t.Errorf("want %d dynamic requires, got %v", tc.wantDynamic, dynamic)
+			}
 		})
 	}
 
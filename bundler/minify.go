@@ -0,0 +1,42 @@
+package bundler
+
+import (
+	luaparse "ModCreator/lua"
+	"strings"
+)
+
+// MinifyBundle minifies every module registered in a bundle, leaving the
+// bundle's own code as it is. Each module keeps its line count, so the bundle
+// can still be unbundled and source maps of it stay correct. A script that
+// isn't bundled is returned unchanged.
+func MinifyBundle(rawlua string) (string, error) {
+	if !IsBundled(rawlua) {
+		return rawlua, nil
+	}
+	lines := strings.Split(rawlua, "\n")
+	out := make([]string, 0, len(lines))
+	next := 0
+	for _, r := range moduleRanges(rawlua) {
+		// r.end is the line closing the module; anything else means this
+		// isn't a layout we wrote, so leave the module alone
+		if r.end < r.start || strings.TrimSpace(lines[r.end-1]) != funcsuffix {
+			continue
+		}
+		body := strings.Join(lines[r.start-1:r.end-1], "\n")
+		minified, err := luaparse.Minify(body)
+		if err != nil {
+			return "", &ModuleError{Module: r.name, Err: err}
+		}
+		out = append(out, lines[next:r.start-1]...)
+		// the module must keep its line count, and the closing line must stay
+		// on a line of its own
+		minLines := strings.Split(minified, "\n")
+		for len(minLines) < r.end-r.start {
+			minLines = append(minLines, "")
+		}
+		out = append(out, minLines...)
+		next = r.end - 1
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, "\n"), nil
+}
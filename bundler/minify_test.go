@@ -0,0 +1,62 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMinifyBundle(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"lib/a.ttslua": "-- helpers\nfunction a()\n    return 1  -- one\nend\n",
+		"lib/b.ttslua": "--[[ nothing\nbut comments ]]",
+	}}
+	bundled, err := Bundle("require('lib/a')\nrequire('lib/b')\n\n  print( a() )", fr)
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	got, err := MinifyBundle(bundled)
+	if err != nil {
+		t.Fatalf("MinifyBundle(): %v", err)
+	}
+	if len(got) >= len(bundled) {
+		t.Errorf("minified bundle is %d bytes, want fewer than %d", len(got), len(bundled))
+	}
+	if want, got := strings.Count(bundled, "\n"), strings.Count(got, "\n"); want != got {
+		t.Errorf("minifying changed the bundle from %d lines to %d", want, got)
+	}
+	if !strings.HasPrefix(got, metaprefix) || !strings.HasSuffix(got, metasuffix) {
+		t.Errorf("minifying changed the bundle's own code:\n%s", got)
+	}
+
+	modules, root, err := UnbundleAll(got)
+	if err != nil {
+		t.Fatalf("UnbundleAll(): %v", err)
+	}
+	want := map[string]string{
+		Rootname: "require('lib/a')\nrequire('lib/b')\n\nprint(a())",
+		"lib/a":  "function a()\nreturn 1\nend",
+		"lib/b":  "",
+	}
+	if root != Rootname {
+		t.Errorf("UnbundleAll() root = %q, want %q", root, Rootname)
+	}
+	if diff := cmp.Diff(want, modules); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	// line numbers of the minified bundle still lead to the source
+	lines := strings.Split(got, "\n")
+	for i, l := range lines {
+		if l == "return 1" {
+			if m, line, ok := LocateLine(got, i+1); m != "lib/a" || line != 3 || !ok {
+				t.Errorf("LocateLine(%d) = %q, %d, %v; want lib/a, 3, true", i+1, m, line, ok)
+			}
+		}
+	}
+
+	if plain, err := MinifyBundle("-- not bundled\nx = 1"); err != nil || plain != "-- not bundled\nx = 1" {
+		t.Errorf("MinifyBundle(<not bundled>) = %q, %v; want it unchanged", plain, err)
+	}
+}
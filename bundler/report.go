@@ -0,0 +1,294 @@
+package bundler
+
+import (
+	"ModCreator/types"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ModuleSize is one module of a bundled script.
+type ModuleSize struct {
+	Name string `json:"name"`
+	// Bytes is how much the module adds to the script, including the code
+	// registering it.
+	Bytes int `json:"bytes"`
+	// Shared is set for the stub of a module that is fetched from Global.
+	Shared bool `json:"shared,omitempty"`
+}
+
+// ScriptReport describes the modules bundled into one script.
+type ScriptReport struct {
+	// Owner is the GUID of the object owning the script, or GlobalScript.
+	Owner    string `json:"owner"`
+	Nickname string `json:"nickname,omitempty"`
+	Bytes    int    `json:"bytes"`
+	// Modules are ordered from largest to smallest.
+	Modules []ModuleSize `json:"modules"`
+	// Unused are the modules that nothing reachable from the root requires.
+	Unused []string `json:"unused,omitempty"`
+}
+
+// ModuleUsage totals one module over every script it is bundled into.
+type ModuleUsage struct {
+	Name    string `json:"name"`
+	Scripts int    `json:"scripts"`
+	Bytes   int    `json:"bytes"`
+}
+
+// Report describes every bundled script of a mod.
+type Report struct {
+	Scripts []ScriptReport `json:"scripts"`
+	// Modules are the modules bundled into more than one script, ordered by
+	// the bytes they take up in total.
+	Modules []ModuleUsage `json:"modules"`
+}
+
+// AnalyzeScript lists the modules of a bundled script with their sizes, and
+// finds those that are never required once requires are followed from the
+// root. ok is false when rawlua isn't bundled.
+func AnalyzeScript(owner, nickname, rawlua string) (r ScriptReport, ok bool, err error) {
+	if !IsBundled(rawlua) {
+		return ScriptReport{}, false, nil
+	}
+	r = ScriptReport{Owner: owner, Nickname: nickname, Bytes: len(rawlua), Modules: []ModuleSize{}}
+	lines := strings.Split(rawlua, "\n")
+	bodies := map[string]string{}
+	for _, m := range moduleRanges(rawlua) {
+		// from the registering line to the line closing the module, each with
+		// its line break
+		size := 0
+		for _, l := range lines[m.start-2 : m.end] {
+			size += len(l) + 1
+		}
+		body := strings.Join(lines[m.start-1:m.end-1], "\n")
+		_, shared := IsSharedStub(body)
+		r.Modules = append(r.Modules, ModuleSize{Name: m.name, Bytes: size, Shared: shared})
+		bodies[m.name] = body
+	}
+	sort.SliceStable(r.Modules, func(i, j int) bool {
+		return r.Modules[i].Bytes > r.Modules[j].Bytes
+	})
+
+	root := Rootname
+	if _, ok := bodies[root]; !ok {
+		_, root, err = UnbundleAll(rawlua)
+		if err != nil {
+			return ScriptReport{}, false, fmt.Errorf("UnbundleAll(): %v", err)
+		}
+	}
+	required := map[string]bool{root: true}
+	todo := []string{root}
+	for len(todo) > 0 {
+		name := todo[0]
+		todo = todo[1:]
+		reqs, _, err := getAllReqValues(bodies[name])
+		if err != nil {
+			return ScriptReport{}, false, &ModuleError{Module: name, Err: err}
+		}
+		for _, req := range reqs {
+			if _, bundled := bodies[req]; bundled && !required[req] {
+				required[req] = true
+				todo = append(todo, req)
+			}
+		}
+	}
+	for _, m := range r.Modules {
+		if !required[m.Name] {
+			r.Unused = append(r.Unused, m.Name)
+		}
+	}
+	sort.Strings(r.Unused)
+	return r, true, nil
+}
+
+// ReportMod analyzes the bundled scripts of a built mod, or of a single
+// object when data isn't a whole mod.
+func ReportMod(data types.J) (*Report, error) {
+	rep := &Report{Scripts: []ScriptReport{}, Modules: []ModuleUsage{}}
+	err := WalkScripts(data, func(owner, nickname, script string) error {
+		r, ok, err := AnalyzeScript(owner, nickname, script)
+		if err != nil {
+			return fmt.Errorf("%s: %v", owner, err)
+		}
+		if ok {
+			rep.Scripts = append(rep.Scripts, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rep.Scripts, func(i, j int) bool {
+		a, b := rep.Scripts[i], rep.Scripts[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Owner < b.Owner
+	})
+	usage := map[string]*ModuleUsage{}
+	for _, s := range rep.Scripts {
+		for _, m := range s.Modules {
+			if m.Name == Rootname || m.Shared {
+				continue
+			}
+			if usage[m.Name] == nil {
+				usage[m.Name] = &ModuleUsage{Name: m.Name}
+			}
+			usage[m.Name].Scripts++
+			usage[m.Name].Bytes += m.Bytes
+		}
+	}
+	for _, u := range usage {
+		if u.Scripts > 1 {
+			rep.Modules = append(rep.Modules, *u)
+		}
+	}
+	sort.Slice(rep.Modules, func(i, j int) bool {
+		a, b := rep.Modules[i], rep.Modules[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+	return rep, nil
+}
+
+// WriteText writes the report in a form meant to be read by people.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if len(r.Scripts) == 0 {
+		b.WriteString("no bundled scripts\n")
+	}
+	for _, s := range r.Scripts {
+		name := s.Owner
+		if s.Nickname != "" {
+			name = fmt.Sprintf("%s (%s)", s.Owner, s.Nickname)
+		}
+		fmt.Fprintf(&b, "%s: %d bytes in %d modules\n", name, s.Bytes, len(s.Modules))
+		for _, m := range s.Modules {
+			shared := ""
+			if m.Shared {
+				shared = " (fetched from Global)"
+			}
+			fmt.Fprintf(&b, "  %10d  %s%s\n", m.Bytes, m.Name, shared)
+		}
+		if len(s.Unused) > 0 {
+			fmt.Fprintf(&b, "  never required: %s\n", strings.Join(s.Unused, ", "))
+		}
+	}
+	if len(r.Modules) > 0 {
+		b.WriteString("\nmodules bundled into more than one script:\n")
+		for _, m := range r.Modules {
+			fmt.Fprintf(&b, "  %10d  %s, in %d scripts\n", m.Bytes, m.Name, m.Scripts)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WalkScripts calls fn with the LuaScript of Global, if data is a whole mod,
+// and of every object in it, including contained objects and states. owner is
+// GlobalScript or the object's GUID.
+func WalkScripts(data types.J, fn func(owner, nickname, script string) error) error {
+	var walk func(o map[string]interface{}) error
+	walk = func(o map[string]interface{}) error {
+		guid, _ := o["GUID"].(string)
+		nickname, _ := o["Nickname"].(string)
+		script, _ := o["LuaScript"].(string)
+		if err := fn(guid, nickname, script); err != nil {
+			return err
+		}
+		for _, c := range objectList(o["ContainedObjects"]) {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		for _, s := range objectMap(o["States"]) {
+			if err := walk(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	states, ok := data["ObjectStates"]
+	if !ok {
+		return walk(data)
+	}
+	script, _ := data["LuaScript"].(string)
+	if err := fn(GlobalScript, "", script); err != nil {
+		return err
+	}
+	for _, o := range objectList(states) {
+		if err := walk(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectList reads a json array of objects, however it was built.
+func objectList(v interface{}) []map[string]interface{} {
+	switch l := v.(type) {
+	case []map[string]interface{}:
+		return l
+	case types.ObjArray:
+		return l
+	case []types.J:
+		out := make([]map[string]interface{}, len(l))
+		for i, o := range l {
+			out[i] = o
+		}
+		return out
+	case []interface{}:
+		out := []map[string]interface{}{}
+		for _, o := range l {
+			if m, ok := asObject(o); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// objectMap reads a json object whose values are objects, in key order.
+func objectMap(v interface{}) []map[string]interface{} {
+	all := map[string]map[string]interface{}{}
+	switch m := v.(type) {
+	case map[string]types.J:
+		for k, o := range m {
+			all[k] = o
+		}
+	default:
+		obj, _ := asObject(v)
+		for k, o := range obj {
+			if om, ok := asObject(o); ok {
+				all[k] = om
+			}
+		}
+	}
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]interface{}, len(keys))
+	for i, k := range keys {
+		out[i] = all[k]
+	}
+	return out
+}
+
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return o, true
+	case types.J:
+		return o, true
+	}
+	return nil, false
+}
@@ -0,0 +1,107 @@
+package bundler
+
+import (
+	"ModCreator/types"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyzeScript(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"a.ttslua": "require('b')\nfunction a() end",
+		"b.ttslua": "b = 1",
+	}}
+	bundled, err := Bundle("require('a')", fr)
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	// a module that was bundled by something else, and that nothing requires
+	stale := "__bundle_register(\"old\", function(require, _LOADED, __bundle_register, __bundle_modules)\nold = true\nend)\n"
+	bundled = strings.Replace(bundled, "return __bundle_require", stale+"return __bundle_require", 1)
+
+	got, ok, err := AnalyzeScript("abc123", "Box", bundled)
+	if err != nil || !ok {
+		t.Fatalf("AnalyzeScript() = %v, %v", ok, err)
+	}
+	size := func(name, body string) int {
+		return len(strings.Replace(funcprefix, funcprefixReplace, name, 1)) + len(body) + len(funcsuffix) + 3
+	}
+	want := ScriptReport{
+		Owner:    "abc123",
+		Nickname: "Box",
+		Bytes:    len(bundled),
+		Modules: []ModuleSize{
+			{Name: "a", Bytes: size("a", "require('b')\nfunction a() end")},
+			{Name: Rootname, Bytes: size(Rootname, "require('a')")},
+			{Name: "old", Bytes: size("old", "old = true")},
+			{Name: "b", Bytes: size("b", "b = 1")},
+		},
+		Unused: []string{"old"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	if _, ok, err := AnalyzeScript("abc123", "", "print(1)"); ok || err != nil {
+		t.Errorf("AnalyzeScript(<not bundled>) = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestReportMod(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"util.ttslua": "function util() end",
+		"big.ttslua":  "-- " + strings.Repeat("x", 200),
+	}}
+	bundle := func(src string) string {
+		b, err := Bundle(src, fr)
+		if err != nil {
+			t.Fatalf("Bundle(): %v", err)
+		}
+		return b
+	}
+	mod := types.J{
+		"LuaScript": bundle("require('util')\nrequire('big')"),
+		"ObjectStates": []map[string]interface{}{
+			{
+				"GUID":      "111111",
+				"Nickname":  "Bag",
+				"LuaScript": "print('not bundled')",
+				"ContainedObjects": []types.J{
+					{"GUID": "222222", "LuaScript": bundle("require('util')")},
+				},
+				"States": map[string]interface{}{
+					"2": map[string]interface{}{"GUID": "333333", "LuaScript": bundle("require('util')")},
+				},
+			},
+		},
+	}
+	rep, err := ReportMod(mod)
+	if err != nil {
+		t.Fatalf("ReportMod(): %v", err)
+	}
+	owners := []string{}
+	for _, s := range rep.Scripts {
+		owners = append(owners, s.Owner)
+	}
+	if diff := cmp.Diff([]string{GlobalScript, "222222", "333333"}, owners); diff != "" {
+		t.Errorf("scripts want != got:\n%v\n", diff)
+	}
+	utilSize := len(strings.Replace(funcprefix, funcprefixReplace, "util", 1)) + len("function util() end") + len(funcsuffix) + 3
+	want := []ModuleUsage{{Name: "util", Scripts: 3, Bytes: 3 * utilSize}}
+	if diff := cmp.Diff(want, rep.Modules); diff != "" {
+		t.Errorf("modules want != got:\n%v\n", diff)
+	}
+
+	var text bytes.Buffer
+	if err := rep.WriteText(&text); err != nil {
+		t.Fatalf("WriteText(): %v", err)
+	}
+	for _, s := range []string{"Global: ", "util, in 3 scripts"} {
+		if !strings.Contains(text.String(), s) {
+			t.Errorf("text report is missing %q:\n%s", s, text.String())
+		}
+	}
+}
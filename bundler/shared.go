@@ -0,0 +1,193 @@
+package bundler
+
+import (
+	luaparse "ModCreator/lua"
+	"ModCreator/types"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A shared module is bundled once, into Global's script, instead of into every
+// script requiring it. Those scripts register a stub in its place, which
+// fetches the module's source from Global the first time it is required and
+// loads it into the requiring script. The stub keeps the module's line numbers,
+// so errors in it still point at the right line of the module.
+const (
+	sharedStub  = `return load(Global.call("__bundle_shared", {name = %q}), %q)(require, _LOADED, __bundle_register, __bundle_modules)`
+	sharedBegin = "-- Shared modules, bundled once here and fetched by object scripts"
+	sharedEnd   = "-- End of shared modules"
+	// the module is given the same arguments as a bundled one, ahead of its
+	// first line so that line numbers are kept
+	sharedGetter = `function __bundle_shared(params)
+	return "local require, _LOADED, __bundle_register, __bundle_modules = ... " .. __bundle_shared_sources[params.name]
+end`
+)
+
+var sharedStubLine = regexp.MustCompile(`^return load\(Global\.call\("__bundle_shared", \{name = "(.*?)"\}\)`)
+
+// SharedStub is the body registered in place of a shared module.
+func SharedStub(module string) string {
+	return fmt.Sprintf(sharedStub, module, module+".ttslua")
+}
+
+// IsSharedStub reports whether a module body is the stub of a shared module,
+// and which.
+func IsSharedStub(body string) (string, bool) {
+	m := sharedStubLine.FindStringSubmatch(strings.TrimSpace(body))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// SharedModule is a module bundled once into Global's script.
+type SharedModule struct {
+	Name   string
+	Source string
+	// File is where Source was read from, for the source map.
+	File string
+}
+
+// SharedModulesUsed lists the shared modules that the scripts of data fetch
+// from Global, sorted.
+func SharedModulesUsed(data types.J) ([]string, error) {
+	used := map[string]bool{}
+	err := WalkScripts(data, func(owner, nickname, script string) error {
+		for _, name := range SharedStubs(script) {
+			used[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(used))
+	for n := range used {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SharedStubs lists the shared modules script fetches from Global, in the
+// order of its modules.
+func SharedStubs(script string) []string {
+	if !IsBundled(script) {
+		return nil
+	}
+	names := []string{}
+	lines := strings.Split(script, "\n")
+	for _, r := range moduleRanges(script) {
+		if r.start > len(lines) {
+			continue
+		}
+		if name, ok := IsSharedStub(lines[r.start-1]); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AddSharedModules puts the shared modules at the top of Global's script. m is
+// the source map of global, if there is one; the returned map covers the new
+// script, including the lines of each module.
+func AddSharedModules(global string, mods []SharedModule, m *SourceMap) (string, *SourceMap) {
+	if len(mods) == 0 {
+		return global, m
+	}
+	out := &SourceMap{Segments: []Segment{}}
+	var b strings.Builder
+	b.WriteString(sharedBegin + "\n__bundle_shared_sources = {\n")
+	line := 3
+	for _, mod := range mods {
+		eq := strings.Repeat("=", longBracketLevel(mod.Source))
+		fmt.Fprintf(&b, "[%q] = [%s[\n%s\n]%s],\n", mod.Name, eq, mod.Source, eq)
+		n := strings.Count(mod.Source, "\n") + 1
+		// the line break after the opening bracket isn't part of the string
+		out.Segments = append(out.Segments, Segment{Start: line + 1, End: line + n, File: mod.File, Line: 1})
+		line += n + 2
+	}
+	b.WriteString("}\n" + sharedGetter + "\n" + sharedEnd + "\n")
+	offset := strings.Count(b.String(), "\n")
+	if m != nil {
+		for _, s := range m.Segments {
+			s.Start += offset
+			s.End += offset
+			out.Segments = append(out.Segments, s)
+		}
+	}
+	return b.String() + global, out
+}
+
+// SplitShared takes the shared modules back out of Global's script, returning
+// the rest of the script and the source of each module, and of the files they
+// #include.
+func SplitShared(global string) (string, map[string]string, error) {
+	srcs, rest, err := readShared(global)
+	if err != nil || srcs == nil {
+		return rest, nil, err
+	}
+	mods := map[string]string{}
+	for _, src := range srcs {
+		mods[src.Name] = src.Source
+	}
+	for _, src := range srcs {
+		mods[src.Name] = collapseIncludes(mods[src.Name], src.Name, mods)
+	}
+	return rest, mods, nil
+}
+
+// SharedSource is a shared module as bundled into Global's script.
+type SharedSource struct {
+	Name, Source string
+	// Line is the line of Global's script that Source starts on.
+	Line int
+}
+
+// SharedSources finds the shared modules bundled into Global's script, as
+// they are there: unlike with SplitShared, the files they #include are left
+// in, so that their lines are those of Global's script.
+func SharedSources(global string) ([]SharedSource, error) {
+	srcs, _, err := readShared(global)
+	return srcs, err
+}
+
+// readShared reads the shared modules at the top of Global's script, in
+// order, and returns them with the rest of the script. They are nil when
+// there are none.
+func readShared(global string) ([]SharedSource, string, error) {
+	if !strings.HasPrefix(global, sharedBegin+"\n") {
+		return nil, global, nil
+	}
+	end := strings.Index(global, "\n"+sharedEnd+"\n")
+	if end < 0 {
+		return nil, "", fmt.Errorf("shared modules at the top of Global's script are missing their end line %q", sharedEnd)
+	}
+	toks, err := luaparse.Lex(global[:end])
+	if err != nil {
+		return nil, "", fmt.Errorf("reading shared modules: %v", err)
+	}
+	srcs := []SharedSource{}
+	// entries are ["name"] = [[source]],
+	for i := 0; i+4 < len(toks); i++ {
+		if toks[i].Text == "[" && toks[i+1].Kind == luaparse.String && toks[i+2].Text == "]" &&
+			toks[i+3].Text == "=" && toks[i+4].Kind == luaparse.String {
+			// the line break after the opening bracket isn't part of the string
+			srcs = append(srcs, SharedSource{Name: toks[i+1].Value, Source: strings.TrimSuffix(toks[i+4].Value, "\n"), Line: toks[i+4].Pos.Line + 1})
+			i += 4
+		}
+	}
+	return srcs, global[end+len(sharedEnd)+2:], nil
+}
+
+// longBracketLevel finds how many "=" a long bracket needs for s to fit
+// inside it.
+func longBracketLevel(s string) int {
+	level := 0
+	for strings.Contains(s, "]"+strings.Repeat("=", level)+"]") {
+		level++
+	}
+	return level
+}
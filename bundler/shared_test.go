@@ -0,0 +1,113 @@
+package bundler
+
+import (
+	"ModCreator/types"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBundleSharing(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"lib/util.ttslua": "require('lib/dep')\nfunction util() end",
+		"lib/dep.ttslua":  "dep = 1",
+		"own.ttslua":      "own = 1",
+	}}
+	got, err := BundleWith("require('lib/util')\nrequire('own')", fr, Options{Shared: func(m string) bool {
+		return strings.HasPrefix(m, "lib/")
+	}})
+	if err != nil {
+		t.Fatalf("BundleWith(): %v", err)
+	}
+	modules, _, err := UnbundleAll(got)
+	if err != nil {
+		t.Fatalf("UnbundleAll(): %v", err)
+	}
+	want := map[string]string{
+		Rootname:   "require('lib/util')\nrequire('own')",
+		"lib/util": SharedStub("lib/util"),
+		"lib/dep":  SharedStub("lib/dep"),
+		"own":      "own = 1",
+	}
+	if diff := cmp.Diff(want, modules); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if name, ok := IsSharedStub(modules["lib/dep"]); name != "lib/dep" || !ok {
+		t.Errorf("IsSharedStub(<stub>) = %q, %v; want lib/dep, true", name, ok)
+	}
+	if _, ok := IsSharedStub(modules["own"]); ok {
+		t.Errorf("IsSharedStub(<module>) = true, want false")
+	}
+
+	used, err := SharedModulesUsed(types.J{"LuaScript": got})
+	if err != nil {
+		t.Fatalf("SharedModulesUsed(): %v", err)
+	}
+	if diff := cmp.Diff([]string{"lib/dep", "lib/util"}, used); diff != "" {
+		t.Errorf("SharedModulesUsed() want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff([]string{"lib/dep", "lib/util"}, SharedStubs(got)); diff != "" {
+		t.Errorf("SharedStubs() want != got:\n%v\n", diff)
+	}
+}
+
+func TestAddAndSplitShared(t *testing.T) {
+	mods := []SharedModule{
+		{Name: "lib/a", Source: "a = 1\nb = 2", File: "src/lib/a.ttslua"},
+		{Name: "lib/b", Source: "s = [[x]] .. [=[y]=]", File: "src/lib/b.ttslua"},
+	}
+	global := "print('global')\nprint(2)"
+	globalMap := WholeFileMap(global, "src/Global.ttslua", false)
+
+	got, m := AddSharedModules(global, mods, globalMap)
+	if !strings.HasSuffix(got, "\n"+global) {
+		t.Errorf("Global's own script should follow the shared modules:\n%s", got)
+	}
+	lines := strings.Split(got, "\n")
+	for i, l := range lines {
+		want := map[string]string{
+			"a = 1":                "src/lib/a.ttslua:1",
+			"b = 2":                "src/lib/a.ttslua:2",
+			"s = [[x]] .. [=[y]=]": "src/lib/b.ttslua:1",
+			"print(2)":             "src/Global.ttslua:2",
+		}[l]
+		if want == "" {
+			continue
+		}
+		seg, line, ok := m.Locate(i + 1)
+		if !ok || fmt.Sprintf("%s:%d", seg.File, line) != want {
+			t.Errorf("Locate(%q) = %s:%d, %v; want %s", l, seg.File, line, ok, want)
+		}
+	}
+
+	rest, split, err := SplitShared(got)
+	if err != nil {
+		t.Fatalf("SplitShared(): %v", err)
+	}
+	if rest != global {
+		t.Errorf("SplitShared() rest = %q, want %q", rest, global)
+	}
+	wantSplit := map[string]string{"lib/a": mods[0].Source, "lib/b": mods[1].Source}
+	if diff := cmp.Diff(wantSplit, split); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	srcs, err := SharedSources(got)
+	if err != nil {
+		t.Fatalf("SharedSources(): %v", err)
+	}
+	for _, src := range srcs {
+		if first := strings.Split(src.Source, "\n")[0]; lines[src.Line-1] != first {
+			t.Errorf("SharedSources() %s starts on line %d, which is %q, not %q", src.Name, src.Line, lines[src.Line-1], first)
+		}
+	}
+	if len(srcs) != len(mods) {
+		t.Errorf("SharedSources() found %d modules, want %d", len(srcs), len(mods))
+	}
+
+	if rest, split, err := SplitShared(global); rest != global || split != nil || err != nil {
+		t.Errorf("SplitShared(<no shared modules>) = %q, %v, %v", rest, split, err)
+	}
+}
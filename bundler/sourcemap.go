@@ -0,0 +1,255 @@
+package bundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// GlobalScript is the key of the Global script in SourceMaps.
+const GlobalScript = "Global"
+
+// sourceMapVersion changes whenever the sidecar format does.
+const sourceMapVersion = 1
+
+var moduleHeader = regexp.MustCompile(`^__bundle_register\("(.*?)", function\(require, _LOADED, __bundle_register, __bundle_modules\)`)
+
+// moduleRange is the lines of a bundle holding one module. Start is the
+// module's first line; End is the line closing its function.
+type moduleRange struct {
+	name       string
+	start, end int
+}
+
+// moduleRanges finds every module of a bundle, in order.
+func moduleRanges(rawlua string) []moduleRange {
+	ranges := []moduleRange{}
+	lines := strings.Split(rawlua, "\n")
+	closeLast := func(n int) {
+		if len(ranges) > 0 && ranges[len(ranges)-1].end == 0 {
+			ranges[len(ranges)-1].end = n - 1
+		}
+	}
+	for i, l := range lines {
+		n := i + 1
+		if m := moduleHeader.FindStringSubmatch(l); m != nil {
+			closeLast(n)
+			ranges = append(ranges, moduleRange{name: m[1], start: n + 1})
+		} else if strings.HasPrefix(l, "return __bundle_require(") {
+			closeLast(n)
+		}
+	}
+	closeLast(len(lines) + 1)
+	return ranges
+}
+
+// LocateLine finds which module line (counting from 1) of a bundle came from.
+// The module's own first line is line 1. ok is false for lines that belong to
+// the bundle's own scaffolding rather than to any module. A script that isn't
+// bundled is entirely the root module.
+func LocateLine(rawlua string, line int) (module string, moduleLine int, ok bool) {
+	if !IsBundled(rawlua) {
+		return Rootname, line, true
+	}
+	for _, r := range moduleRanges(rawlua) {
+		if line >= r.start && line <= r.end {
+			return r.name, line - r.start + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// Segment maps a run of lines in a bundled script to the file they came from.
+type Segment struct {
+	// Start and End are the first and last bundled lines covered, from 1.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// File is where the lines came from. For an Inline script it is the json
+	// file holding the script.
+	File   string `json:"file"`
+	Inline bool   `json:"inline,omitempty"`
+	// Line is the line in File that Start came from.
+	Line int `json:"line"`
+}
+
+// SourceMap maps the lines of one bundled script back to its source files.
+type SourceMap struct {
+	Segments []Segment `json:"segments"`
+}
+
+// MapSource builds the source map of a bundled script. file names the file
+// each module was read from; inline reports a root script that has no file of
+// its own. Files pasted in by #include are mapped as modules of their own,
+// with exists as for LocateSource.
+func MapSource(rawlua string, file func(module string) (name string, inline bool), exists func(module string) bool) *SourceMap {
+	m := &SourceMap{Segments: []Segment{}}
+	add := func(offset int, body, module string) {
+		for _, run := range includeRuns(body, module, exists) {
+			name, inline := file(run.module)
+			m.Segments = append(m.Segments, Segment{Start: offset + run.start, End: offset + run.end, File: name, Inline: inline, Line: run.line})
+		}
+	}
+	if !IsBundled(rawlua) {
+		add(0, rawlua, Rootname)
+		return m
+	}
+	lines := strings.Split(rawlua, "\n")
+	for _, r := range moduleRanges(rawlua) {
+		if r.end <= r.start {
+			// nothing but the line closing the module's function
+			continue
+		}
+		if _, ok := IsSharedStub(lines[r.start-1]); ok {
+			// the module's lines are in Global's script
+			continue
+		}
+		add(r.start-1, strings.Join(lines[r.start-1:r.end-1], "\n"), r.name)
+	}
+	return m
+}
+
+// WholeFileMap maps every line of script to the same line of one file.
+func WholeFileMap(script, file string, inline bool) *SourceMap {
+	return &SourceMap{Segments: []Segment{
+		{Start: 1, End: strings.Count(script, "\n") + 1, File: file, Inline: inline, Line: 1},
+	}}
+}
+
+// Locate finds the source of a bundled line. ok is false for lines of the
+// bundle's own scaffolding.
+func (m *SourceMap) Locate(line int) (seg Segment, fileLine int, ok bool) {
+	for _, s := range m.Segments {
+		if line >= s.Start && line <= s.End {
+			return s, s.Line + line - s.Start, true
+		}
+	}
+	return Segment{}, 0, false
+}
+
+// SourceMaps holds the source map of every script in a mod, keyed by the GUID
+// of the object owning it or GlobalScript. It is safe for concurrent use.
+type SourceMaps struct {
+	mu      sync.Mutex
+	scripts map[string]*SourceMap
+}
+
+// NewSourceMaps creates an empty set of source maps.
+func NewSourceMaps() *SourceMaps {
+	return &SourceMaps{scripts: map[string]*SourceMap{}}
+}
+
+// Add records the map of owner's script. GUIDs aren't guaranteed unique, so
+// when two scripts share one the choice between them is made by content, to
+// keep the result independent of the order objects were processed in.
+func (s *SourceMaps) Add(owner string, m *SourceMap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.scripts[owner]; ok && !mapLess(m, prev) {
+		return
+	}
+	s.scripts[owner] = m
+}
+
+// Merge adds every map of o.
+func (s *SourceMaps) Merge(o *SourceMaps) {
+	for owner, m := range o.All() {
+		s.Add(owner, m)
+	}
+}
+
+// Get returns owner's map, if there is one.
+func (s *SourceMaps) Get(owner string) (*SourceMap, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.scripts[owner]
+	return m, ok
+}
+
+// All returns a copy of every map, keyed by owner.
+func (s *SourceMaps) All() map[string]*SourceMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make(map[string]*SourceMap, len(s.scripts))
+	for k, v := range s.scripts {
+		all[k] = v
+	}
+	return all
+}
+
+func mapLess(a, b *SourceMap) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) < string(bb)
+}
+
+type sourceMapFile struct {
+	Version int                   `json:"version"`
+	Scripts map[string]*SourceMap `json:"scripts"`
+}
+
+// Save writes the maps to path, with file names made relative to base where
+// possible so the sidecar doesn't depend on where the mod was built.
+func (s *SourceMaps) Save(path, base string) error {
+	out := sourceMapFile{Version: sourceMapVersion, Scripts: map[string]*SourceMap{}}
+	for owner, sm := range s.All() {
+		m := &SourceMap{Segments: []Segment{}}
+		for _, seg := range sm.Segments {
+			seg.File = relativeTo(base, seg.File)
+			m.Segments = append(m.Segments, seg)
+		}
+		out.Scripts[owner] = m
+	}
+	// encoding/json sorts map keys, so the output is stable
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// relativeTo makes name relative to base, unless it is outside of base.
+func relativeTo(base, name string) string {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return name
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+	rel, err := filepath.Rel(absBase, absName)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
+
+// LoadSourceMaps reads maps written by Save.
+func LoadSourceMaps(path string) (*SourceMaps, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f sourceMapFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if f.Version != sourceMapVersion {
+		return nil, fmt.Errorf("%s has source map version %d, expected %d; rebuild to update it", path, f.Version, sourceMapVersion)
+	}
+	s := NewSourceMaps()
+	for owner, m := range f.Scripts {
+		s.scripts[owner] = m
+	}
+	return s, nil
+}
+
+// SidecarPath is where the source maps of a built mod or object are written,
+// next to the built file.
+func SidecarPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".srcmap.json"
+}
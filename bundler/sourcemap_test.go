@@ -0,0 +1,166 @@
+package bundler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocateLine(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"a.ttslua": "a1\na2\na3",
+		"b.ttslua": "b1",
+	}}
+	bundled, err := Bundle("require('a')\nrequire('b')", fr)
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	lines := strings.Split(bundled, "\n")
+	find := func(s string) int {
+		for i, l := range lines {
+			if l == s {
+				return i + 1
+			}
+		}
+		t.Fatalf("%q not in bundle:\n%s", s, bundled)
+		return 0
+	}
+
+	type loc struct {
+		module string
+		line   int
+		ok     bool
+	}
+	for _, tc := range []struct {
+		line int
+		want loc
+	}{
+		{line: 1, want: loc{}},
+		{line: find("a3"), want: loc{"a", 3, true}},
+		{line: find("b1"), want: loc{"b", 1, true}},
+		{line: find("require('b')"), want: loc{Rootname, 2, true}},
+		{line: find("b1") - 1, want: loc{}},
+		{line: len(lines), want: loc{}},
+	} {
+		m, l, ok := LocateLine(bundled, tc.line)
+		if diff := cmp.Diff(tc.want, loc{m, l, ok}, cmp.AllowUnexported(loc{})); diff != "" {
+			t.Errorf("LocateLine(%d) want != got:\n%v\n", tc.line, diff)
+		}
+	}
+
+	if m, l, ok := LocateLine("x = 1\ny = 2", 2); m != Rootname || l != 2 || !ok {
+		t.Errorf("LocateLine(<not bundled>, 2) = %q, %d, %v", m, l, ok)
+	}
+}
+
+func TestMapSource(t *testing.T) {
+	fr := &fakeLuaReader{fs: map[string]string{
+		"a.ttslua": "a1\na2\na3",
+		"b.ttslua": "b1",
+	}}
+	bundled, err := Bundle("require('a')\nrequire('b')", fr)
+	if err != nil {
+		t.Fatalf("Bundle(): %v", err)
+	}
+	m := MapSource(bundled, func(module string) (string, bool) {
+		if module == Rootname {
+			return "box.json", true
+		}
+		return "src/" + module + ".ttslua", false
+	}, nil)
+	lines := strings.Split(bundled, "\n")
+
+	type loc struct {
+		File   string
+		Inline bool
+		Line   int
+	}
+	got := map[string]loc{}
+	for i, l := range lines {
+		if seg, line, ok := m.Locate(i + 1); ok {
+			got[l] = loc{seg.File, seg.Inline, line}
+		}
+	}
+	want := map[string]loc{
+		"require('a')": {"box.json", true, 1},
+		"require('b')": {"box.json", true, 2},
+		"a1":           {"src/a.ttslua", false, 1},
+		"a3":           {"src/a.ttslua", false, 3},
+		"a2":           {"src/a.ttslua", false, 2},
+		"b1":           {"src/b.ttslua", false, 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	plain := MapSource("x = 1\ny = 2", func(string) (string, bool) { return "g.ttslua", false }, nil)
+	if seg, line, ok := plain.Locate(2); seg.File != "g.ttslua" || line != 2 || !ok {
+		t.Errorf("Locate(<not bundled>, 2) = %v, %d, %v", seg, line, ok)
+	}
+}
+
+func TestSourceMapsSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(filepath.Dir(dir), "lib", "x.ttslua")
+	maps := NewSourceMaps()
+	maps.Add("abc123", &SourceMap{Segments: []Segment{
+		{Start: 40, End: 42, File: filepath.Join(dir, "objects", "Box.abc123.json"), Inline: true, Line: 1},
+		{Start: 44, End: 50, File: outside, Line: 1},
+	}})
+	maps.Add(GlobalScript, WholeFileMap("a\nb", filepath.Join(dir, "src", "Global.ttslua"), false))
+
+	p := filepath.Join(dir, "output.srcmap.json")
+	if err := maps.Save(p, dir); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	loaded, err := LoadSourceMaps(p)
+	if err != nil {
+		t.Fatalf("LoadSourceMaps(): %v", err)
+	}
+	want := map[string]*SourceMap{
+		"abc123": {Segments: []Segment{
+			{Start: 40, End: 42, File: "objects/Box.abc123.json", Inline: true, Line: 1},
+			{Start: 44, End: 50, File: outside, Line: 1},
+		}},
+		GlobalScript: {Segments: []Segment{
+			{Start: 1, End: 2, File: "src/Global.ttslua", Line: 1},
+		}},
+	}
+	if diff := cmp.Diff(want, loaded.All()); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	if err := os.WriteFile(p, []byte(`{"version": 0, "scripts": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSourceMaps(p); err == nil {
+		t.Errorf("LoadSourceMaps(<old version>): wanted error, got none")
+	}
+}
+
+func TestSourceMapsAddIsOrderIndependent(t *testing.T) {
+	a := WholeFileMap("x", "a.json", true)
+	b := WholeFileMap("x", "b.json", true)
+	ab, ba := NewSourceMaps(), NewSourceMaps()
+	ab.Add("dup", a)
+	ab.Add("dup", b)
+	ba.Add("dup", b)
+	ba.Add("dup", a)
+	if diff := cmp.Diff(ab.All(), ba.All()); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestSidecarPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"mod/output.json": "mod/output.srcmap.json",
+		"out/obj":         "out/obj.srcmap.json",
+	} {
+		if got := SidecarPath(in); got != want {
+			t.Errorf("SidecarPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,47 +2,212 @@ package bundler
 
 import (
 	"ModCreator/file"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 )
 
+// An included file is pasted in place of its <Include> element between a pair
+// of markers naming it. An element alone on its line is replaced by the lines
+// of the file, indented like it:
+//
+//	<!-- include ui/panel -->
+//	...contents of ui/panel.xml...
+//	<!-- include ui/panel -->
+//
+// while one sharing its line with anything else is replaced in place. The
+// markers also hold the parameters the file was filled in with, see
+// xmltemplate.go.
+var xmlIncludeMarker = regexp.MustCompile(`<!-- include (\S+)((?: \??[A-Za-z_][\w.-]*="[^"]*")*) -->`)
+
 // BundleXML converts <Include ... >'s into full xml
 func BundleXML(rawxml string, xr file.TextReader) (string, error) {
-	lines := strings.Split(rawxml, "\n")
-	final := []string{}
-	inctag := regexp.MustCompile(`(?m)^(.*)<Include src="(.*)"/>`)
+	return bundleXML(rawxml, xr, []string{Rootname})
+}
 
-	for _, v := range lines {
-		if !inctag.Match([]byte(v)) {
-			final = append(final, v)
-			continue
+// bundleXML is BundleXML, with chain listing the files being expanded to catch
+// files including each other.
+func bundleXML(rawxml string, xr file.TextReader, chain []string) (string, error) {
+	incs, err := findIncludes(rawxml)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	last := 0
+	for _, inc := range incs {
+		name := strings.TrimSuffix(inc.src, ".xml")
+		if strings.Contains(name, " ") {
+			return "", fmt.Errorf("line %d: Include src %q can't have a space, which the include markers can't hold", inc.line, inc.src)
 		}
-		subs := inctag.FindSubmatch([]byte(v))
-		indent := string(subs[1])
-		name := string(subs[2])
-
-		replacement := fmt.Sprintf("%s<!-- include %s -->", indent, name)
-		final = append(final, replacement)
-
-		fname := name
-		if !strings.HasSuffix(name, ".xml") {
-			fname = name + ".xml"
+		for i, c := range chain {
+			if c == name {
+				cycle := append(append([]string{}, chain[i:]...), name)
+				return "", &CycleError{Chain: cycle, XML: true}
+			}
 		}
+		fname := name + ".xml"
 		incXMLRaw, err := xr.EncodeFromFile(fname)
 		if err != nil {
 			return "", fmt.Errorf("EncodeFromFile(%s): %v", fname, err)
 		}
-		incXMLBundled, err := BundleXML(incXMLRaw, xr)
+		filled, defaults, err := fillTemplate(incXMLRaw, inc.params)
 		if err != nil {
+			return "", fmt.Errorf("line %d: <Include src=%q>: %s: %v", inc.line, inc.src, fname, err)
+		}
+		incXMLBundled, err := bundleXML(filled, xr, append(append([]string{}, chain...), name))
+		if err != nil {
+			var ce *CycleError
+			if errors.As(err, &ce) {
+				return "", err
+			}
 			return "", fmt.Errorf("BundleXML(<%s>): %v", fname, err)
 		}
-		final = append(final, indentString(incXMLBundled, indent))
 
-		final = append(final, replacement)
+		marker := xmlMarker(inc.src, inc.params, defaults)
+		lineStart, lineEnd := lineAround(rawxml, inc.start, inc.end)
+		indent := rawxml[lineStart:inc.start]
+		if strings.TrimSpace(indent) == "" && strings.TrimSpace(rawxml[inc.end:lineEnd]) == "" {
+			b.WriteString(rawxml[last:lineStart])
+			b.WriteString(indent + marker + "\n")
+			b.WriteString(indentString(incXMLBundled, indent) + "\n")
+			b.WriteString(indent + marker)
+			last = lineEnd
+			continue
+		}
+		b.WriteString(rawxml[last:inc.start])
+		b.WriteString(marker + incXMLBundled + marker)
+		last = inc.end
+	}
+	b.WriteString(rawxml[last:])
+	return b.String(), nil
+}
+
+// xmlInclude is one <Include> element of a file.
+type xmlInclude struct {
+	src string
+	// params are the element's other attributes, in order
+	params     []xmlParam
+	start, end int
+	line       int
+}
+
+// findIncludes finds every <Include> element of rawxml, outside of comments
+// and CDATA sections, with the offsets it spans. Each is read with an XML
+// tokenizer, so any quoting, attribute order and spacing is understood,
+// without needing the rest of the file to be well formed.
+func findIncludes(rawxml string) ([]xmlInclude, error) {
+	incs := []xmlInclude{}
+	for i := 0; i < len(rawxml); {
+		next := strings.IndexByte(rawxml[i:], '<')
+		if next < 0 {
+			break
+		}
+		i += next
+		rest := rawxml[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				return incs, nil
+			}
+			i += end + len("-->")
+			continue
+		case strings.HasPrefix(rest, "<![CDATA["):
+			end := strings.Index(rest, "]]>")
+			if end < 0 {
+				return incs, nil
+			}
+			i += end + len("]]>")
+			continue
+		case !isIncludeStart(rest):
+			i++
+			continue
+		}
+		line := strings.Count(rawxml[:i], "\n") + 1
+		inc, err := readXMLInclude(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		inc.start, inc.end, inc.line = i, i+inc.end, line
+		incs = append(incs, inc)
+		i = inc.end
+	}
+	return incs, nil
+}
+
+func isIncludeStart(s string) bool {
+	const tag = "<Include"
+	if !strings.HasPrefix(s, tag) || len(s) == len(tag) {
+		return false
+	}
+	c := s[len(tag)]
+	return c == '/' || c == '>' || c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// readXMLInclude reads the <Include> element that s starts with. The end of the
+// result is relative to s.
+func readXMLInclude(s string) (xmlInclude, error) {
+	d := xml.NewDecoder(strings.NewReader(s))
+	tok, err := d.RawToken()
+	if err != nil {
+		return xmlInclude{}, fmt.Errorf("reading <Include>: %v", err)
+	}
+	start, ok := tok.(xml.StartElement)
+	if !ok {
+		return xmlInclude{}, fmt.Errorf("reading <Include>: found %T", tok)
+	}
+	inc := xmlInclude{end: int(d.InputOffset())}
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Space != "":
+			return xmlInclude{}, fmt.Errorf("<Include> can't have a parameter with a namespace, %s:%s", a.Name.Space, a.Name.Local)
+		case a.Name.Local == "src":
+			inc.src = a.Value
+		case !paramName.MatchString(a.Name.Local):
+			return xmlInclude{}, fmt.Errorf("<Include> parameter %s isn't a name a placeholder can have", a.Name.Local)
+		default:
+			inc.params = append(inc.params, xmlParam{name: a.Name.Local, value: a.Value})
+		}
+	}
+	if inc.src == "" {
+		return xmlInclude{}, fmt.Errorf("<Include> has no src")
+	}
+	if strings.HasSuffix(s[:inc.end], "/>") {
+		return inc, nil
+	}
+	// <Include src="..."></Include>, with nothing but spaces between
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return xmlInclude{}, fmt.Errorf("<Include src=%q> isn't closed: %v", inc.src, err)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) == "" {
+				continue
+			}
+		case xml.EndElement:
+			if t.Name.Local == "Include" {
+				inc.end = int(d.InputOffset())
+				return inc, nil
+			}
+		}
+		return xmlInclude{}, fmt.Errorf("<Include src=%q> can only be empty", inc.src)
+	}
+}
 
+// lineAround finds the start and end of the line(s) holding s[start:end],
+// without the line break ending it.
+func lineAround(s string, start, end int) (int, int) {
+	lineStart := strings.LastIndexByte(s[:start], '\n') + 1
+	lineEnd := strings.IndexByte(s[end:], '\n')
+	if lineEnd < 0 {
+		return lineStart, len(s)
 	}
-	return strings.Join(final, "\n"), nil
+	return lineStart, end + lineEnd
 }
 
 func indentString(s string, indent string) string {
@@ -64,53 +229,162 @@ func indentString(s string, indent string) string {
 // per-bundle entry declaration, so the root is always the canonical Rootname;
 // it is returned so callers can treat Lua and XML unbundling uniformly.
 func UnbundleAllXML(rawxml string) (map[string]string, string, error) {
-	type inc struct {
-		name  string
-		start int
-	}
 	store := map[string]string{}
-	inctag := regexp.MustCompile(`(?m)^(.*?)<!-- include ([^ ]+) -->`)
-	stack := []inc{}
-	xmlarray := strings.Split(rawxml, "\n")
+	root, err := collapseXML(rawxml, store)
+	if err != nil {
+		return nil, "", err
+	}
+	store[Rootname] = root
+	return store, Rootname, nil
+}
 
-	for ln := 0; ln < len(xmlarray); ln++ {
-		val := xmlarray[ln]
-		if !inctag.Match([]byte(val)) {
-			continue
+// collapseXML puts back the <Include> elements whose files bundleXML pasted
+// into rawxml, adding the contents of each file to store. A file filled in
+// with parameters that can't be recovered is left pasted in place.
+func collapseXML(rawxml string, store map[string]string) (string, error) {
+	var b strings.Builder
+	last := 0
+	for {
+		span, ok, err := nextIncluded(rawxml, last)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			break
+		}
+		content := rawxml[span.contentStart:span.contentEnd]
+		if span.block {
+			content = unindentAndJoin(strings.Split(strings.TrimSuffix(content, "\n"), "\n"), span.indent)
+		}
+		inner, err := collapseXML(content, store)
+		if err != nil {
+			return "", err
 		}
-		submatches := inctag.FindSubmatch([]byte(val))
-		key := string(submatches[2])
-		if len(stack) > 0 && stack[0].name == key {
-			// found end of include, process it
-			indent := string(submatches[1])
-			indentedvals := xmlarray[stack[0].start+1 : ln]
+		b.WriteString(rawxml[last:span.start])
+		last = span.end
 
-			// do not let mods specify relative paths
-			storedName := strings.Replace(stack[0].name, "../", "", -1)
+		// do not let mods specify relative paths
+		storedName := strings.Replace(span.name, "../", "", -1)
+		template := inner
+		if len(span.params) > 0 || len(span.defaults) > 0 {
+			template, err = recoverTemplate(inner, span.params, span.defaults)
+			if prev, ok := store[storedName]; err == nil && ok && prev != template {
+				err = fmt.Errorf("it is included elsewhere with parameters giving different XML")
+			}
+			if err != nil {
+				log.Printf("warning: can't recover the parameters of <Include src=%q>, leaving its XML in place: %v", storedName, err)
+				if span.block {
+					inner = indentString(inner, span.indent)
+				}
+				b.WriteString(inner)
+				continue
+			}
+		}
+		if span.block {
+			b.WriteString(span.indent)
+		}
+		b.WriteString(includeElement(storedName, span.params))
+		store[storedName] = template
+	}
+	b.WriteString(rawxml[last:])
+	return b.String(), nil
+}
 
-			store[storedName] = unindentAndJoin(indentedvals, indent)
+// includedSpan is where a file bundleXML pasted in sits in the bundled xml.
+type includedSpan struct {
+	name string
+	// params are those the file was filled in with, and defaults the
+	// placeholders left to their default
+	params, defaults []xmlParam
+	// start and end span the markers and the file between them, which
+	// replaced the <Include> element; contentStart and contentEnd the file.
+	start, end               int
+	contentStart, contentEnd int
+	// block is set when the element was alone on its line, and indent is
+	// then what the file was indented by.
+	block  bool
+	indent string
+}
 
-			insertLine := fmt.Sprintf("%s<Include src=\"%s\"/>", indent, storedName)
-			// remove the include from xmlarray
-			tmp := append(xmlarray[:stack[0].start], insertLine)
-			xmlarray = append(tmp, xmlarray[ln+1:]...)
-			ln = stack[0].start
-			stack = stack[1:]
-		} else {
-			stack = append([]inc{{name: key, start: ln}}, stack...)
+// nextIncluded finds the first file pasted into rawxml at or after from.
+func nextIncluded(rawxml string, from int) (includedSpan, bool, error) {
+	open := xmlIncludeMarker.FindStringSubmatchIndex(rawxml[from:])
+	if open == nil {
+		return includedSpan{}, false, nil
+	}
+	name := rawxml[from+open[2] : from+open[3]]
+	params, defaults := parseMarkerParams(rawxml[from+open[4] : from+open[5]])
+	openStart, openEnd := from+open[0], from+open[1]
+	marker := rawxml[openStart:openEnd]
+	// a file can't include itself, so the next marker naming the same
+	// file with the same parameters closes it
+	closeAt := strings.Index(rawxml[openEnd:], marker)
+	if closeAt < 0 {
+		return includedSpan{}, false, fmt.Errorf("Bundled xml left after finished reading file: include %s is never closed", name)
+	}
+	closeStart, closeEnd := openEnd+closeAt, openEnd+closeAt+len(marker)
+
+	openLineStart, openLineEnd := lineAround(rawxml, openStart, openEnd)
+	closeLineStart, closeLineEnd := lineAround(rawxml, closeStart, closeEnd)
+	indent := rawxml[closeLineStart:closeStart]
+	block := openLineEnd < closeLineStart &&
+		strings.TrimSpace(rawxml[openLineStart:openStart]) == "" && strings.TrimSpace(rawxml[openEnd:openLineEnd]) == "" &&
+		strings.TrimSpace(indent) == "" && strings.TrimSpace(rawxml[closeEnd:closeLineEnd]) == ""
+	if block {
+		return includedSpan{
+			name: name, params: params, defaults: defaults,
+			start: openLineStart, end: closeLineEnd,
+			contentStart: openLineEnd + 1, contentEnd: closeLineStart,
+			block: true, indent: indent,
+		}, true, nil
+	}
+	return includedSpan{
+		name: name, params: params, defaults: defaults,
+		start: openStart, end: closeEnd,
+		contentStart: openEnd, contentEnd: closeStart,
+	}, true, nil
+}
+
+// IsBundledXML reports whether rawxml has files pasted into it by BundleXML.
+func IsBundledXML(rawxml string) bool {
+	return xmlIncludeMarker.MatchString(rawxml)
+}
+
+// LocateXML finds which file the byte at offset of bundled xml came from, and
+// the line of that file it is on. Files are named like the src of the
+// <Include> element pasting them in, without the .xml extension, and the
+// bundled xml itself is Rootname. The markers around a file belong to the line
+// of the element they replaced.
+func LocateXML(bundled string, offset int) (module string, line int) {
+	return locateXML(bundled, Rootname, offset)
+}
+
+func locateXML(rawxml, module string, offset int) (string, int) {
+	line, last := 1, 0
+	for {
+		span, ok, err := nextIncluded(rawxml, last)
+		if err != nil || !ok || offset < span.start {
+			break
 		}
+		line += strings.Count(rawxml[last:span.start], "\n")
+		if offset < span.end {
+			if offset >= span.contentStart && offset < span.contentEnd {
+				return locateXML(rawxml[span.contentStart:span.contentEnd], strings.TrimSuffix(span.name, ".xml"), offset-span.contentStart)
+			}
+			return module, line
+		}
+		last = span.end
 	}
-	if len(stack) != 0 {
-		return nil, "", fmt.Errorf("Bundled xml left after finished reading file: %v", stack)
+	if offset > len(rawxml) {
+		offset = len(rawxml)
 	}
-	store[Rootname] = unindentAndJoin(xmlarray, "")
-	return store, Rootname, nil
+	return module, line + strings.Count(rawxml[last:offset], "\n")
 }
 
 func unindentAndJoin(raw []string, indent string) string {
 	ret := []string{}
 	for _, v := range raw {
-		ret = append(ret, strings.Replace(v, indent, "", 1))
+		ret = append(ret, strings.TrimPrefix(v, indent))
 	}
 	return strings.Join(ret, "\n")
 }
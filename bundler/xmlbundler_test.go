@@ -2,6 +2,8 @@ package bundler
 
 import (
 	"ModCreator/tests"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -138,3 +140,143 @@ func TestBundleXML(t *testing.T) {
 		t.Errorf("want != got:\n%v\n", diff)
 	}
 }
+
+func TestBundleXMLIncludeForms(t *testing.T) {
+	files := map[string]string{
+		"a.xml":     `<Text>a</Text>`,
+		"b.xml":     "<Text>b1</Text>\n<Text>b2</Text>",
+		"outer.xml": `<Panel><Include src="a"/></Panel>`,
+	}
+	for _, tc := range []struct {
+		name, input string
+		want        string
+		// wantRoot is what unbundling gives back, when not the input
+		wantRoot string
+	}{
+		{
+			name:     "single quotes, unused parameters and spaces",
+			input:    "  <Include id=\"x\" src='a'  />",
+			want:     "  <!-- include a id=\"x\" -->\n  <Text>a</Text>\n  <!-- include a id=\"x\" -->",
+			wantRoot: `  <Include src="a" id="x"/>`,
+		},
+		{
+			name:     "end tag",
+			input:    "<Include src=\"b\">\n</Include>",
+			want:     "<!-- include b -->\n<Text>b1</Text>\n<Text>b2</Text>\n<!-- include b -->",
+			wantRoot: `<Include src="b"/>`,
+		},
+		{
+			name:  "several on a line",
+			input: `<Row><Include src="a"/><Include src="b"/></Row>`,
+			want:  "<Row><!-- include a --><Text>a</Text><!-- include a --><!-- include b --><Text>b1</Text>\n<Text>b2</Text><!-- include b --></Row>",
+		},
+		{
+			name:  "nested inline",
+			input: "<Include src=\"outer\"/> <Text/>",
+			want:  `<!-- include outer --><Panel><!-- include a --><Text>a</Text><!-- include a --></Panel><!-- include outer --> <Text/>`,
+		},
+		{
+			name:  "not includes",
+			input: "<!-- <Include src=\"a\"/> -->\n<![CDATA[<Include src=\"b\"/>]]>\n<Includes src=\"c\"/>",
+			want:  "<!-- <Include src=\"a\"/> -->\n<![CDATA[<Include src=\"b\"/>]]>\n<Includes src=\"c\"/>",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ff := tests.NewFF()
+			ff.Fs = files
+			got, err := BundleXML(tc.input, ff)
+			if err != nil {
+				t.Fatalf("BundleXML(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+
+			unbundled, _, err := UnbundleAllXML(got)
+			if err != nil {
+				t.Fatalf("UnbundleAllXML(): %v", err)
+			}
+			wantRoot := tc.input
+			if tc.wantRoot != "" {
+				wantRoot = tc.wantRoot
+			}
+			if diff := cmp.Diff(wantRoot, unbundled[Rootname]); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+			for name, body := range unbundled {
+				if name == Rootname {
+					continue
+				}
+				if diff := cmp.Diff(files[name+".xml"], body); diff != "" {
+					t.Errorf("%s: want != got:\n%v\n", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestBundleXMLErrors(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Fs = map[string]string{
+		"a.xml": `<Include src="b"/>`,
+		"b.xml": "<Panel>\n  <Include src=\"a\"/>\n</Panel>",
+	}
+	for _, tc := range []struct {
+		name, input, want string
+	}{
+		{
+			name:  "cycle",
+			input: `<Include src="a"/>`,
+			want:  "circular Include: a.xml -> b.xml -> a.xml",
+		},
+		{
+			name:  "no src",
+			input: "<Panel/>\n<Include file=\"a\"/>",
+			want:  "line 2: <Include> has no src",
+		},
+		{
+			name:  "not well formed",
+			input: `<Include src="a/>`,
+			want:  "line 1: reading <Include>: XML syntax error on line 1: unexpected EOF",
+		},
+		{
+			name:  "content",
+			input: `<Include src="a"><Text/></Include>`,
+			want:  `line 1: <Include src="a"> can only be empty`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BundleXML(tc.input, ff)
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("want error %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLocateXML(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Fs = map[string]string{
+		"ui/panel.xml": "<Panel>\n  <Text>p2</Text>\n  <Include src=\"ui/row\"/>\n  <Text>p4</Text>\n</Panel>",
+		"ui/row.xml":   "<Row>\n  <Cell>r2</Cell>\n</Row>",
+		"ui/icon.xml":  "<Image>i1</Image>",
+	}
+	bundled, err := BundleXML("<Text>m1</Text>\n<Include src=\"ui/panel\"/>\n<Button><Include src=\"ui/icon\"/></Button><Text>m3</Text>\n<Text>m4</Text>", ff)
+	if err != nil {
+		t.Fatalf("BundleXML(): %v", err)
+	}
+	want := map[string]string{
+		"m1": Rootname + ":1", "m3": Rootname + ":3", "m4": Rootname + ":4",
+		"p2": "ui/panel:2", "p4": "ui/panel:4",
+		"r2": "ui/row:2",
+		"i1": "ui/icon:1",
+	}
+	got := map[string]string{}
+	for text := range want {
+		module, line := LocateXML(bundled, strings.Index(bundled, text))
+		got[text] = fmt.Sprintf("%s:%d", module, line)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,282 @@
+package bundler
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An included xml file may hold {{name}} placeholders, filled in from the
+// attributes of the <Include> element other than src:
+//
+//	<Include src="ui/button" id="btn1" color="#ff0000"/>
+//
+// A placeholder may give a default, {{name|default}}, used when the element
+// doesn't set it; every other placeholder must be set. An <Include> without
+// parameters includes the file as it is, braces and all, as includes did
+// before they took parameters. The markers around the
+// filled in file repeat the parameters, and the defaults prefixed with ?, so
+// that reversing can put the placeholders back:
+//
+//	<!-- include ui/button id="btn1" color="#ff0000" ?size="20" -->
+var (
+	paramName   = regexp.MustCompile(`^[A-Za-z_][\w.-]*$`)
+	placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w.-]*)\s*(?:\|([^}]*))?\}\}`)
+	markerParam = regexp.MustCompile(` (\??)([A-Za-z_][\w.-]*)="([^"]*)"`)
+)
+
+// xmlParam is a parameter of an <Include>, or the default of a placeholder.
+type xmlParam struct {
+	name, value string
+}
+
+// fillTemplate replaces the placeholders of template with the values of
+// params, escaped for XML, or their defaults. The default of each placeholder
+// having one is returned, used or not. Without params, template is returned as
+// it is.
+func fillTemplate(template string, params []xmlParam) (string, []xmlParam, error) {
+	if len(params) == 0 {
+		return template, nil, nil
+	}
+	values := map[string]string{}
+	for _, p := range params {
+		values[p.name] = p.value
+	}
+	hasDefault := map[string]bool{}
+	defaults := []xmlParam{}
+	var missing error
+	filled := placeholder.ReplaceAllStringFunc(template, func(ph string) string {
+		m := placeholder.FindStringSubmatch(ph)
+		name, def := m[1], strings.TrimSpace(m[2])
+		if strings.Contains(ph, "|") && !hasDefault[name] {
+			hasDefault[name] = true
+			defaults = append(defaults, xmlParam{name: name, value: def})
+		}
+		if v, ok := values[name]; ok {
+			return escapeAttr(v)
+		}
+		if strings.Contains(ph, "|") {
+			return def
+		}
+		if missing == nil {
+			missing = fmt.Errorf("%s has no value: set %s on the <Include> or give it a default, as {{%s|...}}", ph, name, name)
+		}
+		return ph
+	})
+	if missing != nil {
+		return "", nil, missing
+	}
+	return filled, defaults, nil
+}
+
+// escapeAttr escapes v to be written inside a double quoted attribute.
+func escapeAttr(v string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(v)
+}
+
+// xmlMarker is the marker around the file included as src, filled in with
+// params and defaults. Values are escaped so they can't end the comment.
+func xmlMarker(src string, params, defaults []xmlParam) string {
+	var b strings.Builder
+	b.WriteString("<!-- include " + src)
+	write := func(prefix string, p xmlParam) {
+		v := strings.Replace(escapeAttr(p.value), "--", "-&#45;", -1)
+		fmt.Fprintf(&b, ` %s%s="%s"`, prefix, p.name, v)
+	}
+	for _, p := range params {
+		write("", p)
+	}
+	for _, p := range defaults {
+		write("?", p)
+	}
+	b.WriteString(" -->")
+	return b.String()
+}
+
+// parseMarkerParams reads back the parameters and defaults of xmlMarker.
+func parseMarkerParams(s string) (params, defaults []xmlParam) {
+	for _, m := range markerParam.FindAllStringSubmatch(s, -1) {
+		p := xmlParam{name: m[2], value: html.UnescapeString(m[3])}
+		if m[1] == "?" {
+			defaults = append(defaults, p)
+		} else {
+			params = append(params, p)
+		}
+	}
+	return params, defaults
+}
+
+// includeElement is the <Include> element of src with params.
+func includeElement(src string, params []xmlParam) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<Include src="%s"`, src)
+	for _, p := range params {
+		fmt.Fprintf(&b, ` %s="%s"`, p.name, escapeAttr(p.value))
+	}
+	b.WriteString("/>")
+	return b.String()
+}
+
+// recoverTemplate puts placeholders back into filled, a file filled in with
+// params and defaults, where their values stand apart from the text around
+// them. It fails unless filling the result in again gives filled.
+func recoverTemplate(filled string, params, defaults []xmlParam) (string, error) {
+	type sub struct{ value, ph string }
+	subs := []sub{}
+	given := map[string]bool{}
+	ph := func(name string) string {
+		for _, d := range defaults {
+			if d.name == name {
+				return "{{" + name + "|" + d.value + "}}"
+			}
+		}
+		return "{{" + name + "}}"
+	}
+	for _, p := range params {
+		given[p.name] = true
+		subs = append(subs, sub{escapeAttr(p.value), ph(p.name)})
+	}
+	for _, d := range defaults {
+		if !given[d.name] {
+			subs = append(subs, sub{d.value, ph(d.name)})
+		}
+	}
+	// longer values first, so that one holding another is put back whole
+	sort.SliceStable(subs, func(i, j int) bool { return len(subs[i].value) > len(subs[j].value) })
+
+	// values are only looked for in text and attribute values, so the text is
+	// split into what is left to search and what is fixed: markup and the
+	// placeholders already put back
+	pieces := []piece{}
+	last := 0
+	for _, r := range valueRanges(filled) {
+		pieces = append(pieces, piece{text: filled[last:r[0]], fixed: true}, piece{text: filled[r[0]:r[1]]})
+		last = r[1]
+	}
+	pieces = append(pieces, piece{text: filled[last:], fixed: true})
+	for _, s := range subs {
+		if s.value == "" {
+			continue
+		}
+		next := []piece{}
+		for _, p := range pieces {
+			if p.fixed {
+				next = append(next, p)
+				continue
+			}
+			rest := p.text
+			for {
+				i := standaloneIndex(rest, s.value)
+				if i < 0 {
+					break
+				}
+				next = append(next, piece{text: rest[:i]}, piece{text: s.ph, fixed: true})
+				rest = rest[i+len(s.value):]
+			}
+			next = append(next, piece{text: rest})
+		}
+		pieces = next
+	}
+	var b strings.Builder
+	for _, p := range pieces {
+		b.WriteString(p.text)
+	}
+	template := b.String()
+
+	refilled, refilledDefaults, err := fillTemplate(template, params)
+	if err != nil {
+		return "", err
+	}
+	if refilled != filled || !sameParams(refilledDefaults, defaults) {
+		return "", fmt.Errorf("its values don't only appear where placeholders were")
+	}
+	return template, nil
+}
+
+type piece struct {
+	text  string
+	fixed bool
+}
+
+// valueRanges finds the text and the attribute values of xml, as start and
+// end offsets, skipping comments, CDATA and processing instructions.
+func valueRanges(xml string) [][2]int {
+	ranges := [][2]int{}
+	add := func(start, end int) {
+		if start < end {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+	skip := func(i int, end string) int {
+		if j := strings.Index(xml[i:], end); j >= 0 {
+			return i + j + len(end)
+		}
+		return len(xml)
+	}
+	for i := 0; i < len(xml); {
+		next := strings.IndexByte(xml[i:], '<')
+		if next < 0 {
+			add(i, len(xml))
+			break
+		}
+		add(i, i+next)
+		i += next
+		switch rest := xml[i:]; {
+		case strings.HasPrefix(rest, "<!--"):
+			i = skip(i, "-->")
+		case strings.HasPrefix(rest, "<![CDATA["):
+			i = skip(i, "]]>")
+		case strings.HasPrefix(rest, "<?"):
+			i = skip(i, "?>")
+		default:
+			// a tag, whose quoted attribute values are searched
+			for i++; i < len(xml) && xml[i] != '>'; i++ {
+				if q := xml[i]; q == '"' || q == '\'' {
+					end := strings.IndexByte(xml[i+1:], q)
+					if end < 0 {
+						return ranges
+					}
+					add(i+1, i+1+end)
+					i += 1 + end
+				}
+			}
+			i++
+		}
+	}
+	return ranges
+}
+
+// standaloneIndex finds the first v in s that isn't part of a longer word.
+func standaloneIndex(s, v string) int {
+	for from := 0; ; {
+		i := strings.Index(s[from:], v)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		end := i + len(v)
+		if !(isWordByte(v[0]) && i > 0 && isWordByte(s[i-1])) &&
+			!(isWordByte(v[len(v)-1]) && end < len(s) && isWordByte(s[end])) {
+			return i
+		}
+		from = i + 1
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func sameParams(a, b []xmlParam) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
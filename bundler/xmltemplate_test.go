@@ -0,0 +1,153 @@
+package bundler
+
+import (
+	"ModCreator/tests"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBundleXMLParams(t *testing.T) {
+	files := map[string]string{
+		"ui/button.xml": `<Button id="{{id}}" color="{{color|#FFFFFF}}" fontSize="{{size|20}}">{{label}}</Button>`,
+		"ui/row.xml":    "<Row>\n  <Include src=\"ui/button\" id=\"{{id}}\" label=\"Row {{id}}\"/>\n</Row>",
+	}
+	for _, tc := range []struct {
+		name, input, want string
+	}{
+		{
+			name:  "parameters and defaults",
+			input: `<Panel><Include src="ui/button" id="btn1" label="Go &amp; &quot;see&quot;" size="14"/></Panel>`,
+			want: `<Panel><!-- include ui/button id="btn1" label="Go &amp; &quot;see&quot;" size="14" ?color="#FFFFFF" ?size="20" -->` +
+				`<Button id="btn1" color="#FFFFFF" fontSize="14">Go &amp; &quot;see&quot;</Button>` +
+				`<!-- include ui/button id="btn1" label="Go &amp; &quot;see&quot;" size="14" ?color="#FFFFFF" ?size="20" --></Panel>`,
+		},
+		{
+			name:  "passed on to a nested include",
+			input: `<Include src="ui/row" id="ok"/>`,
+			want: "<!-- include ui/row id=\"ok\" -->\n<Row>\n" +
+				"  <!-- include ui/button id=\"ok\" label=\"Row ok\" ?color=\"#FFFFFF\" ?size=\"20\" -->\n" +
+				"  <Button id=\"ok\" color=\"#FFFFFF\" fontSize=\"20\">Row ok</Button>\n" +
+				"  <!-- include ui/button id=\"ok\" label=\"Row ok\" ?color=\"#FFFFFF\" ?size=\"20\" -->\n" +
+				"</Row>\n<!-- include ui/row id=\"ok\" -->",
+		},
+		{
+			name:  "a value that would end the marker",
+			input: `<Include src="ui/button" id="a--b" label="x"/>`,
+			want: `<!-- include ui/button id="a-&#45;b" label="x" ?color="#FFFFFF" ?size="20" -->` + "\n" +
+				`<Button id="a--b" color="#FFFFFF" fontSize="20">x</Button>` + "\n" +
+				`<!-- include ui/button id="a-&#45;b" label="x" ?color="#FFFFFF" ?size="20" -->`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ff := tests.NewFF()
+			ff.Fs = files
+			got, err := BundleXML(tc.input, ff)
+			if err != nil {
+				t.Fatalf("BundleXML(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+
+			unbundled, _, err := UnbundleAllXML(got)
+			if err != nil {
+				t.Fatalf("UnbundleAllXML(): %v", err)
+			}
+			if diff := cmp.Diff(tc.input, unbundled[Rootname]); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+			for name, body := range unbundled {
+				if name == Rootname {
+					continue
+				}
+				if diff := cmp.Diff(files[name+".xml"], body); diff != "" {
+					t.Errorf("%s: want != got:\n%v\n", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestFillTemplate(t *testing.T) {
+	got, defaults, err := fillTemplate(`{{ a }} {{ b | x y }} {{b|z}} {{a|w}}`, []xmlParam{{name: "a", value: "<1>"}})
+	if err != nil {
+		t.Fatalf("fillTemplate(): %v", err)
+	}
+	if want := "&lt;1&gt; x y z &lt;1&gt;"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if diff := cmp.Diff([]xmlParam{{name: "b", value: "x y"}, {name: "a", value: "w"}}, defaults, cmp.AllowUnexported(xmlParam{})); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestBundleXMLParamsMissing(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Fs = map[string]string{"ui/button.xml": `<Button id="{{id}}"/>`}
+	_, err := BundleXML("<Panel/>\n<Include src=\"ui/button\" label=\"Go\"/>", ff)
+	want := `line 2: <Include src="ui/button">: ui/button.xml: {{id}} has no value: set id on the <Include> or give it a default, as {{id|...}}`
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+// TestBundleXMLWithoutParams includes a file with literal braces, as files
+// could hold before includes took parameters, which must be left alone.
+func TestBundleXMLWithoutParams(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Fs = map[string]string{"tip.xml": `<Text>Type {{name}} to greet, {{a|b}} too</Text>`}
+	got, err := BundleXML(`<Include src="tip"/>`, ff)
+	if err != nil {
+		t.Fatalf("BundleXML(): %v", err)
+	}
+	want := "<!-- include tip -->\n<Text>Type {{name}} to greet, {{a|b}} too</Text>\n<!-- include tip -->"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	files, _, err := UnbundleAllXML(got)
+	if err != nil {
+		t.Fatalf("UnbundleAllXML(): %v", err)
+	}
+	wantFiles := map[string]string{Rootname: `<Include src="tip"/>`, "tip": ff.Fs["tip.xml"]}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestUnbundleXMLParamsFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name, input, want string
+		wantFiles         map[string]string
+	}{
+		{
+			name: "value also in the file",
+			input: `<!-- include ui/t c="Red" --><Text color="Red" outline="Red"/><!-- include ui/t c="Red" -->` +
+				"\n" + `<!-- include ui/t c="Blue" --><Text color="Blue" outline="Red"/><!-- include ui/t c="Blue" -->`,
+			want: `<Include src="ui/t" c="Red"/>` + "\n" + `<Text color="Blue" outline="Red"/>`,
+			wantFiles: map[string]string{
+				"ui/t": `<Text color="{{c}}" outline="{{c}}"/>`,
+			},
+		},
+		{
+			name:      "value only in markup",
+			input:     `<!-- include ui/t tag="Text" --><Text/><!-- include ui/t tag="Text" -->`,
+			want:      `<Include src="ui/t" tag="Text"/>`,
+			wantFiles: map[string]string{"ui/t": "<Text/>"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := UnbundleAllXML(tc.input)
+			if err != nil {
+				t.Fatalf("UnbundleAllXML(): %v", err)
+			}
+			want := map[string]string{Rootname: tc.want}
+			for k, v := range tc.wantFiles {
+				want[k] = v
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
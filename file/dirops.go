@@ -31,6 +31,13 @@ type DirCreator interface {
 	Clear() error
 }
 
+// FileRemover deletes files, for writers updating a directory in place
+type FileRemover interface {
+	// Remove deletes relpath, and any directories left empty by it; a file
+	// that doesn't exist is not an error
+	Remove(relpath string) error
+}
+
 // DirExplorer allows files and folders to be enumerated
 type DirExplorer interface {
 	// ListFilesAndFolders returns files, folders, err with names sharing prefix of relpath
@@ -234,6 +241,25 @@ func (d *DirOps) Clear() error {
 	return nil
 }
 
+// Remove deletes the file relpath and then every parent directory it leaves
+// empty, up to but not including the base directory.
+func (d *DirOps) Remove(relpath string) error {
+	p := filepath.Join(d.base, relpath)
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove(%s) : %v", p, err)
+	}
+	for dir := filepath.Dir(p); dir != filepath.Clean(d.base) && strings.HasPrefix(dir, filepath.Clean(d.base)); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("os.Remove(%s) : %v", dir, err)
+		}
+	}
+	return nil
+}
+
 // ListFilesAndFolders allows for file exploration. returns relateive file or folder names
 func (d *DirOps) ListFilesAndFolders(relpath string) ([]string, []string, error) {
 	p := filepath.Join(d.base, relpath)
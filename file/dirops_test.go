@@ -167,6 +167,30 @@ func TestListFilesAndFolders(t *testing.T) {
 	}
 }
 
+func TestRemove(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(path.Join(base, "Bag", "Inner"), 0755); err != nil {
+		t.Fatalf("setup MkdirAll(): %v", err)
+	}
+	writeFile(t, path.Join(base, "Bag", "Inner", "Card.json"), "x")
+	writeFile(t, path.Join(base, "Bag", "Die.json"), "x")
+	d := NewDirOps(base)
+
+	if err := d.Remove("Bag/Inner/Card.json"); err != nil {
+		t.Fatalf("Remove(): %v", err)
+	}
+	// already gone
+	if err := d.Remove("Bag/Inner/Card.json"); err != nil {
+		t.Fatalf("Remove() of a missing file: %v", err)
+	}
+	if _, err := os.Stat(path.Join(base, "Bag", "Inner")); !os.IsNotExist(err) {
+		t.Errorf("expected the emptied directory Bag/Inner to be removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(path.Join(base, "Bag", "Die.json")); err != nil {
+		t.Errorf("expected Bag/Die.json to be kept: %v", err)
+	}
+}
+
 // --- ownership-marker + path-guard tests (#96) ---
 
 func writeFile(t *testing.T, p, content string) {
@@ -8,7 +8,8 @@ import (
 	"path"
 )
 
-// JSONOps implements the corresponding reader & writer interfaces
+// JSONOps implements the corresponding reader & writer interfaces. It holds no
+// mutable state, so it is safe for concurrent use.
 type JSONOps struct {
 	basepath string
 }
@@ -53,6 +54,11 @@ func NewJSONOps(base string) *JSONOps {
 	}
 }
 
+// Resolve reports the full path filename is read from.
+func (j *JSONOps) Resolve(filename string) (string, error) {
+	return path.Join(j.basepath, filename), nil
+}
+
 // ReadObj pulls a file from configs and encodes it as a string.
 func (j *JSONOps) ReadObj(filename string) (map[string]interface{}, error) {
 	b, err := j.pullRawFile(filename)
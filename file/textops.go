@@ -5,14 +5,23 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync"
 )
 
-// TextOps allows for arbitrary reads and writes of text files
+// TextOps allows for arbitrary reads and writes of text files. It is safe for
+// concurrent use.
 type TextOps struct {
 	basepaths        []string
 	writeBasepath    string
 	readFileToBytes  func(string) ([]byte, error)
 	writeBytesToFile func(string, []byte) error
+	// fileExists tells whether a path is a file that could be read, without
+	// reading it
+	fileExists func(string) bool
+
+	// writes are serialized so objects sharing a required file can be written
+	// concurrently without interleaving
+	writeMu sync.Mutex
 }
 
 // TextReader serves to describe all ways to read luascripts
@@ -20,6 +29,12 @@ type TextReader interface {
 	EncodeFromFile(string) (string, error)
 }
 
+// PathResolver is implemented by readers that can tell which file a name is
+// read from, for reporting.
+type PathResolver interface {
+	Resolve(string) (string, error)
+}
+
 // TextWriter serves to describe all ways to write luascripts
 type TextWriter interface {
 	EncodeToFile(script, file string) error
@@ -61,6 +76,10 @@ func NewTextOpsMulti(readDirs []string, writeDir string) *TextOps {
 			}
 			return os.WriteFile(p, b, 0644)
 		},
+		fileExists: func(p string) bool {
+			info, err := os.Stat(p)
+			return err == nil && info.Mode().IsRegular()
+		},
 	}
 }
 
@@ -78,8 +97,37 @@ func (l *TextOps) EncodeFromFile(filename string) (string, error) {
 	return "", fmt.Errorf("%s not found among any known paths", filename)
 }
 
+// Resolve reports the full path EncodeFromFile reads filename from.
+func (l *TextOps) Resolve(filename string) (string, error) {
+	all := l.ResolveAll(filename)
+	if len(all) == 0 {
+		return "", fmt.Errorf("%s not found among any known paths", filename)
+	}
+	return all[0], nil
+}
+
+// ResolveAll lists every read path containing filename, in precedence order.
+// The first is the one used; any others are shadowed by it.
+func (l *TextOps) ResolveAll(filename string) []string {
+	found := []string{}
+	for _, base := range l.basepaths {
+		p := path.Join(base, filename)
+		if l.fileExists(p) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// ReadPaths returns the directories searched by EncodeFromFile, in order.
+func (l *TextOps) ReadPaths() []string {
+	return append([]string{}, l.basepaths...)
+}
+
 // EncodeToFile takes a single string and decodes escape characters; writes it.
 func (l *TextOps) EncodeToFile(script, file string) error {
 	p := path.Join(l.writeBasepath, file)
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
 	return l.writeBytesToFile(p, []byte(script))
 }
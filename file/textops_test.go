@@ -2,6 +2,8 @@ package file
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"testing"
 
@@ -19,6 +21,11 @@ func (ff *fakeFiles) read(s string) ([]byte, error) {
 	return nil, fmt.Errorf("fake file %s not found", s)
 }
 
+func (ff *fakeFiles) exists(s string) bool {
+	_, ok := ff.fs[s]
+	return ok
+}
+
 func (ff *fakeFiles) write(path string, val []byte) error {
 	ff.fs[path] = val
 	return nil
@@ -85,6 +92,54 @@ func TestReadErr(t *testing.T) {
 	}
 }
 
+func TestResolve(t *testing.T) {
+	ff := &fakeFiles{
+		fs: map[string][]byte{
+			"lib2/util.ttslua": []byte("two"),
+			"lib3/util.ttslua": []byte("three"),
+		},
+	}
+	l := &TextOps{
+		basepaths:       []string{"lib1", "lib2", "lib3"},
+		readFileToBytes: ff.read,
+		fileExists:      ff.exists,
+	}
+
+	got, err := l.Resolve("util.ttslua")
+	if err != nil {
+		t.Fatalf("Resolve(util.ttslua): %v", err)
+	}
+	if got != "lib2/util.ttslua" {
+		t.Errorf("Resolve(util.ttslua) = %q, want lib2/util.ttslua", got)
+	}
+	want := []string{"lib2/util.ttslua", "lib3/util.ttslua"}
+	if diff := cmp.Diff(want, l.ResolveAll("util.ttslua")); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if _, err := l.Resolve("missing.ttslua"); err == nil {
+		t.Errorf("Resolve(missing.ttslua): wanted error, got none")
+	}
+}
+
+func TestResolveAllOnDisk(t *testing.T) {
+	base := t.TempDir()
+	for _, d := range []string{"lib1/util.ttslua", "lib2"} {
+		// a directory named like the file isn't it
+		if err := os.MkdirAll(filepath.Join(base, d), 0755); err != nil {
+			t.Fatalf("MkdirAll(): %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "lib2", "util.ttslua"), []byte("two"), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	l := NewTextOpsMulti([]string{filepath.Join(base, "lib1"), filepath.Join(base, "lib2")}, base)
+
+	want := []string{filepath.Join(base, "lib2", "util.ttslua")}
+	if diff := cmp.Diff(want, l.ResolveAll("util.ttslua")); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
 func TestWrite(t *testing.T) {
 	ff := &fakeFiles{
 		fs: map[string][]byte{},
@@ -0,0 +1,154 @@
+package file
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileStamp is what the watcher remembers about a file between polls. A change
+// in either field is treated as a modification.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher polls a set of files and directories and reports when anything under
+// them is created, modified or removed. Polling keeps the tool free of
+// platform-specific notification APIs and is cheap for the size of a mod tree.
+type Watcher struct {
+	// Paths are the files or directories to watch. Paths that do not exist are
+	// tolerated and start being watched as soon as they appear.
+	Paths []string
+	// Interval is how often the watched paths are scanned.
+	Interval time.Duration
+	// Debounce is how long the tree must stay quiet after a change before
+	// onChange is called, so that a burst of saves triggers a single rebuild.
+	Debounce time.Duration
+	// Ignore are files under Paths that are not watched, such as the outputs
+	// of the build run on every change.
+	Ignore []string
+	// OnStart, if set, is called once the first snapshot is taken, so that
+	// files changed while it runs are reported like any later change.
+	OnStart func()
+}
+
+// NewWatcher creates a watcher over paths with sensible polling defaults.
+func NewWatcher(paths []string) *Watcher {
+	return &Watcher{
+		Paths:    paths,
+		Interval: 500 * time.Millisecond,
+		Debounce: 300 * time.Millisecond,
+	}
+}
+
+// Watch blocks until stop is closed, calling onChange with the sorted list of
+// changed paths after each debounced burst of changes. An error from onChange
+// is not fatal; it is the caller's job to report it.
+func (w *Watcher) Watch(stop <-chan struct{}, onChange func(changed []string)) error {
+	prev, err := w.snapshot()
+	if err != nil {
+		return fmt.Errorf("snapshot(%v): %v", w.Paths, err)
+	}
+	if w.OnStart != nil {
+		w.OnStart()
+	}
+	pending := map[string]bool{}
+	var lastChange time.Time
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case now := <-ticker.C:
+			cur, err := w.snapshot()
+			if err != nil {
+				return fmt.Errorf("snapshot(%v): %v", w.Paths, err)
+			}
+			if changed := diffSnapshots(prev, cur); len(changed) > 0 {
+				for _, c := range changed {
+					pending[c] = true
+				}
+				lastChange = now
+			}
+			prev = cur
+			if len(pending) > 0 && now.Sub(lastChange) >= w.Debounce {
+				changed := make([]string, 0, len(pending))
+				for p := range pending {
+					changed = append(changed, p)
+				}
+				sort.Strings(changed)
+				pending = map[string]bool{}
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// snapshot records the stamp of every regular file under the watched paths.
+func (w *Watcher) snapshot() (map[string]fileStamp, error) {
+	ignored := map[string]bool{}
+	for _, p := range w.Ignore {
+		ignored[absPath(p)] = true
+	}
+	snap := map[string]fileStamp{}
+	for _, root := range w.Paths {
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					// files may legitimately vanish between listing and stat
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || ignored[absPath(p)] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			snap[p] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// absPath makes p absolute, so that the same file is recognized however it
+// was named.
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// diffSnapshots returns every path that was added, removed or modified between
+// two snapshots, sorted for stable reporting.
+func diffSnapshots(prev, cur map[string]fileStamp) []string {
+	changed := []string{}
+	for p, s := range cur {
+		if old, ok := prev[p]; !ok || !old.modTime.Equal(s.modTime) || old.size != s.size {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
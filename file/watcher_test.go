@@ -0,0 +1,149 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	t1 := time.Unix(2000, 0)
+	prev := map[string]fileStamp{
+		"same":    {modTime: t0, size: 1},
+		"touched": {modTime: t0, size: 1},
+		"grown":   {modTime: t0, size: 1},
+		"removed": {modTime: t0, size: 1},
+	}
+	cur := map[string]fileStamp{
+		"same":    {modTime: t0, size: 1},
+		"touched": {modTime: t1, size: 1},
+		"grown":   {modTime: t0, size: 2},
+		"added":   {modTime: t0, size: 1},
+	}
+
+	want := []string{"added", "grown", "removed", "touched"}
+	got := diffSnapshots(prev, cur)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestSnapshotMissingPath(t *testing.T) {
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "a.ttslua"), []byte("x"), 0644); err != nil {
+		t.Fatalf("setup WriteFile(): %v", err)
+	}
+	w := NewWatcher([]string{base, filepath.Join(base, "does-not-exist")})
+
+	snap, err := w.snapshot()
+	if err != nil {
+		t.Fatalf("snapshot(): %v", err)
+	}
+	if _, ok := snap[filepath.Join(base, "a.ttslua")]; !ok || len(snap) != 1 {
+		t.Errorf("snapshot() = %v, want only a.ttslua", snap)
+	}
+}
+
+func TestSnapshotIgnore(t *testing.T) {
+	base := t.TempDir()
+	for _, name := range []string{"in.json", "out.json"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("setup WriteFile(): %v", err)
+		}
+	}
+	w := NewWatcher([]string{base})
+	// named differently from the walk, to be matched all the same
+	w.Ignore = []string{filepath.Join(base, ".", "out.json")}
+
+	snap, err := w.snapshot()
+	if err != nil {
+		t.Fatalf("snapshot(): %v", err)
+	}
+	if _, ok := snap[filepath.Join(base, "in.json")]; !ok || len(snap) != 1 {
+		t.Errorf("snapshot() = %v, want only in.json", snap)
+	}
+}
+
+// TestWatchOnStart saves a file while OnStart runs, as during the first build,
+// and expects it to be reported.
+func TestWatchOnStart(t *testing.T) {
+	base := t.TempDir()
+	w := &Watcher{
+		Paths:    []string{base},
+		Interval: 10 * time.Millisecond,
+		Debounce: 20 * time.Millisecond,
+		OnStart: func() {
+			if err := os.WriteFile(filepath.Join(base, "a.ttslua"), []byte("x"), 0644); err != nil {
+				t.Errorf("WriteFile(): %v", err)
+			}
+		},
+	}
+	stop := make(chan struct{})
+	calls := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(stop, func(changed []string) { calls <- changed })
+	}()
+
+	select {
+	case got := <-calls:
+		if diff := cmp.Diff([]string{filepath.Join(base, "a.ttslua")}, got); diff != "" {
+			t.Errorf("want != got:\n%v\n", diff)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("onChange was never called")
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("Watch(): %v", err)
+	}
+}
+
+// TestWatchDebouncesBurst writes several files in quick succession and expects
+// a single onChange call listing all of them.
+func TestWatchDebouncesBurst(t *testing.T) {
+	base := t.TempDir()
+	w := &Watcher{
+		Paths:    []string{base},
+		Interval: 10 * time.Millisecond,
+		Debounce: 50 * time.Millisecond,
+	}
+	stop := make(chan struct{})
+	calls := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(stop, func(changed []string) { calls <- changed })
+	}()
+
+	// give the watcher time to take its initial snapshot
+	time.Sleep(30 * time.Millisecond)
+	for _, name := range []string{"a.ttslua", "b.xml"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile(): %v", err)
+		}
+	}
+
+	select {
+	case got := <-calls:
+		want := []string{filepath.Join(base, "a.ttslua"), filepath.Join(base, "b.xml")}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("want != got:\n%v\n", diff)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("onChange was never called")
+	}
+	select {
+	case extra := <-calls:
+		t.Errorf("expected a single debounced call, got another: %v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Errorf("Watch(): %v", err)
+	}
+}
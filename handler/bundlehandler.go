@@ -3,10 +3,107 @@ package handler
 import (
 	"ModCreator/bundler"
 	"ModCreator/file"
+	"ModCreator/lua"
+	"ModCreator/xmlui"
+	"errors"
 	"fmt"
+	"log"
+	"path"
 	"strings"
 )
 
+// DefaultInlineLimit is the length above which a script is written to its own
+// file rather than kept inline in the json.
+const DefaultInlineLimit = 80
+
+// LuaOptions change how Lua is handled on the way into a mod.
+type LuaOptions struct {
+	// SkipCheck turns off the syntax check of every bundled script.
+	SkipCheck bool
+	// Minify strips comments and redundant whitespace from every module of a
+	// bundled script.
+	Minify bool
+	// Shared are patterns, as for path.Match, of the modules bundled once into
+	// Global's script instead of into every script requiring them.
+	Shared []string
+	// AllowCycles accepts modules requiring each other in a circle when one of
+	// the requires is inside a function, as for bundler.Options.
+	AllowCycles bool
+}
+
+// IsShared reports whether module matches one of o.Shared.
+func (o LuaOptions) IsShared(module string) bool {
+	for _, p := range o.Shared {
+		if ok, _ := path.Match(p, module); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// XMLOptions change how XmlUI is handled on the way into a mod.
+type XMLOptions struct {
+	// SkipCheck turns off checking bundled XmlUI against the TTS UI schema.
+	SkipCheck bool
+	// Warn, if set, receives every problem the check finds instead of them
+	// being logged. It may be called from several goroutines at once.
+	Warn func(XMLWarning)
+}
+
+// Report hands each of ws to o.Warn, or logs it.
+func (o XMLOptions) Report(ws []XMLWarning) {
+	for _, w := range ws {
+		if o.Warn != nil {
+			o.Warn(w)
+			continue
+		}
+		log.Printf("warning: %v", w)
+	}
+}
+
+// XMLWarning is something in XmlUI that TTS won't understand, located in the
+// file it was read from.
+type XMLWarning struct {
+	// File is as for ScriptError.File.
+	File   string
+	Inline bool
+	Line   int
+	Msg    string
+}
+
+func (w XMLWarning) String() string {
+	where := w.File
+	switch {
+	case w.Inline && w.File == "":
+		where = "inline XmlUI"
+	case w.Inline:
+		where = w.File + " (inline XmlUI)"
+	}
+	return fmt.Sprintf("%s:%d: %s", where, w.Line, w.Msg)
+}
+
+// ScriptError is a syntax error in a script, located in the file it was read
+// from.
+type ScriptError struct {
+	// File is the script's file, or for an inline script the json file holding
+	// it, which the caller fills in when known.
+	File      string
+	Inline    bool
+	Line, Col int
+	Msg       string
+}
+
+func (e *ScriptError) Error() string {
+	where := e.File
+	switch {
+	case e.Inline && e.File == "":
+		where = "inline script"
+	case e.Inline:
+		where = e.File + " (inline script)"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", where, e.Line, e.Col, e.Msg)
+}
+
 // Handler is needed because handling if a script should be written to src or to objects folder,
 // and if it it long enough to be written to separate file at all has become
 // burdensome. abstract into struct. Also for XML bundling
@@ -14,21 +111,47 @@ type Handler struct {
 	DefaultWriter file.TextWriter
 	SrcWriter     file.TextWriter
 	Reader        file.TextReader
+	// InlineLimit overrides DefaultInlineLimit when non-zero
+	InlineLimit int
+	// Lua is only used by the Lua handler
+	Lua LuaOptions
+	// XML is only used by the XML handler
+	XML XMLOptions
+	// SourceMap asks WhileReadingFromFile for the source map of each script
+	SourceMap bool
 
 	key, keypath, extension string
 	bundle                  func(string, file.TextReader) (string, error)
 	unbundle                func(string) (map[string]string, string, error)
+	// check, when set, validates the syntax of each bundled script
+	check func(string) error
+	// minify, when set, is used for LuaOptions.Minify
+	minify func(string) (string, error)
+	// splitShared, when set, takes shared modules back out of a script
+	splitShared func(string) (string, map[string]string, error)
+	// checkXML, when set, finds what TTS won't understand in bundled XmlUI
+	checkXML func(string) []xmlui.Problem
 }
 
 // NewLuaHandler fills in relevant info for lua bundling
 func NewLuaHandler() *Handler {
-	return &Handler{
-		key:       "LuaScript",
-		keypath:   "LuaScript_path",
-		extension: ".ttslua",
-		bundle:    bundler.Bundle,
-		unbundle:  bundler.UnbundleAll,
+	h := &Handler{
+		key:         "LuaScript",
+		keypath:     "LuaScript_path",
+		extension:   ".ttslua",
+		unbundle:    bundler.UnbundleAll,
+		check:       lua.Check,
+		minify:      bundler.MinifyBundle,
+		splitShared: bundler.SplitShared,
+	}
+	h.bundle = func(rawlua string, l file.TextReader) (string, error) {
+		opts := bundler.Options{AllowCycles: h.Lua.AllowCycles}
+		if len(h.Lua.Shared) > 0 {
+			opts.Shared = h.Lua.IsShared
+		}
+		return bundler.BundleWith(rawlua, l, opts)
 	}
+	return h
 }
 
 // NewXMLHandler fills in relevant info for lua bundling
@@ -39,6 +162,7 @@ func NewXMLHandler() *Handler {
 		extension: ".xml",
 		bundle:    bundler.BundleXML,
 		unbundle:  bundler.UnbundleAllXML,
+		checkXML:  xmlui.Check,
 	}
 }
 
@@ -48,12 +172,21 @@ type HandleAction struct {
 	Noop  bool
 	Key   string
 	Value string
+	// SourceMap is set when reading with Handler.SourceMap. An inline script's
+	// segments leave File for the caller to fill in.
+	SourceMap *bundler.SourceMap
+	// MinifiedFrom is the length of Value before it was minified, or 0 if it
+	// wasn't.
+	MinifiedFrom int
+	// Warnings are what the XML handler found TTS won't understand. Those of
+	// inline XmlUI leave File for the caller to fill in.
+	Warnings []XMLWarning
 }
 
 // WhileReadingFromFile consolidates expected behavior of both objects and root
 // json while reading lua from file.
 func (h *Handler) WhileReadingFromFile(rawj map[string]interface{}) (HandleAction, error) {
-	rawscript := ""
+	rawscript, scriptPath := "", ""
 	if spraw, ok := rawj[h.keypath]; ok {
 		sp, ok := spraw.(string)
 		if !ok {
@@ -63,30 +196,206 @@ func (h *Handler) WhileReadingFromFile(rawj map[string]interface{}) (HandleActio
 		if err != nil {
 			return HandleAction{}, fmt.Errorf("l.EncodeFromFile(%s) : %v", sp, err)
 		}
-		rawscript = encoded
+		rawscript, scriptPath = encoded, sp
 	}
 	if sraw, ok := rawj[h.key]; ok {
 		s, ok := sraw.(string)
 		if !ok {
 			return HandleAction{}, fmt.Errorf("Expected %s to be type string, was %T", h.key, sraw)
 		}
-		rawscript = s
+		rawscript, scriptPath = s, ""
 	}
 	bundled, err := h.bundle(rawscript, h.Reader)
 	if err != nil {
-		return HandleAction{}, fmt.Errorf("Bundle(%s): %v", rawscript, err)
+		return HandleAction{}, h.moduleError(err, scriptPath, fmt.Sprintf("Bundle(%s)", rawscript))
 	}
 	if bundled == "" {
 		return HandleAction{
 			Noop: true,
 		}, nil
 	}
+	if h.check != nil && !h.Lua.SkipCheck {
+		if err := h.checkBundled(bundled, rawscript, scriptPath); err != nil {
+			return HandleAction{}, err
+		}
+	}
 
-	return HandleAction{
+	act := HandleAction{
 		Key:   h.key,
 		Value: bundled,
 		Noop:  false,
-	}, nil
+	}
+	if h.checkXML != nil && !h.XML.SkipCheck {
+		act.Warnings = h.xmlWarnings(bundled, rawscript, scriptPath)
+	}
+	if h.minify != nil && h.Lua.Minify {
+		minified, err := h.minify(bundled)
+		if err != nil {
+			return HandleAction{}, h.moduleError(err, scriptPath, "MinifyBundle()")
+		}
+		if minified != bundled {
+			act.Value, act.MinifiedFrom = minified, len(bundled)
+		}
+	}
+	if h.SourceMap {
+		// minifying keeps every line where it was, but drops the markers of
+		// included files that the map is made from
+		act.SourceMap = h.sourceMap(bundled, rawscript, scriptPath)
+	}
+	return act, nil
+}
+
+func (h *Handler) sourceMap(bundled, rawscript, scriptPath string) *bundler.SourceMap {
+	if bundler.IsBundled(rawscript) {
+		// already bundled when it was read, so the script is its own source
+		return bundler.WholeFileMap(bundled, h.resolve(scriptPath), scriptPath == "")
+	}
+	return bundler.MapSource(bundled, func(module string) (string, bool) {
+		fname := h.moduleFile(module, scriptPath)
+		if fname == "" {
+			return "", true
+		}
+		return h.resolve(fname), false
+	}, h.exists)
+}
+
+// exists reports whether a module of a bundle can be read.
+func (h *Handler) exists(module string) bool {
+	_, err := h.Reader.EncodeFromFile(module + h.extension)
+	return err == nil
+}
+
+// checkBundled validates a bundled script, reporting any error against the
+// file it came from: the object's own script (rawscript, read from scriptPath
+// unless inline) or one of the files it required.
+func (h *Handler) checkBundled(bundled, rawscript, scriptPath string) error {
+	err := h.check(bundled)
+	var se *lua.SyntaxError
+	if err == nil || !errors.As(err, &se) {
+		return err
+	}
+	if bundler.IsBundled(rawscript) {
+		// already bundled when it was read, so the script is its own source
+		return h.scriptError(scriptPath, se)
+	}
+	module, line, ok := bundler.LocateSource(bundled, se.Line, h.exists)
+	if !ok {
+		return fmt.Errorf("bundled script: %v", err)
+	}
+	fname, src := h.moduleFile(module, scriptPath), rawscript
+	if module != bundler.Rootname {
+		if src, err = h.Reader.EncodeFromFile(fname); err != nil {
+			return fmt.Errorf("EncodeFromFile(%s) : %v", fname, err)
+		}
+	}
+	// Checking the module on its own places errors exactly, even ones the
+	// bundle only notices in a later module, like a missing end. That can't be
+	// done for a module that includes others, which only parses once they're
+	// pasted in.
+	var own *lua.SyntaxError
+	if !bundler.HasIncludes(src) && errors.As(h.check(src), &own) {
+		return h.scriptError(fname, own)
+	}
+	return h.scriptError(fname, &lua.SyntaxError{Line: line, Col: se.Col, Msg: se.Msg})
+}
+
+// xmlWarnings checks bundled XmlUI, reporting each problem against the file
+// it came from: the object's own XmlUI (rawscript, read from scriptPath unless
+// inline) or one of the files it included.
+func (h *Handler) xmlWarnings(bundled, rawscript, scriptPath string) []XMLWarning {
+	var ws []XMLWarning
+	for _, p := range h.checkXML(bundled) {
+		fname, line := scriptPath, p.Line
+		if !bundler.IsBundledXML(rawscript) {
+			// otherwise already bundled when it was read, so its own source
+			module, l := bundler.LocateXML(bundled, p.Offset)
+			fname, line = h.moduleFile(module, scriptPath), l
+		}
+		w := XMLWarning{File: h.resolve(fname), Inline: fname == "", Line: line, Msg: p.Msg}
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// Minified is how much smaller minifying made the script of Owner, in bytes.
+type Minified struct {
+	Owner    string
+	From, To int
+}
+
+func (m Minified) String() string {
+	saved := m.From - m.To
+	return fmt.Sprintf("minified Lua of %s: %d -> %d bytes, saved %d (%.0f%%)", m.Owner, m.From, m.To, saved, 100*float64(saved)/float64(m.From))
+}
+
+// MinifiedBy tells what minifying did to owner's script in act, if it was
+// minified.
+func MinifiedBy(owner string, act HandleAction) (Minified, bool) {
+	if act.MinifiedFrom == 0 {
+		return Minified{}, false
+	}
+	return Minified{Owner: owner, From: act.MinifiedFrom, To: len(act.Value)}, true
+}
+
+// LogMinified reports how much smaller minifying made owner's script, if it
+// was minified.
+func LogMinified(owner string, act HandleAction) {
+	if m, ok := MinifiedBy(owner, act); ok {
+		log.Print(m)
+	}
+}
+
+// moduleError reports a syntax error in one module of a bundle against the
+// file it is in, a circle of requires as it is, and anything else as a failure
+// of what.
+func (h *Handler) moduleError(err error, scriptPath, what string) error {
+	var me *bundler.ModuleError
+	var se *lua.SyntaxError
+	if errors.As(err, &me) && errors.As(me.Err, &se) {
+		return h.scriptError(h.moduleFile(me.Module, scriptPath), se)
+	}
+	var ce *bundler.CycleError
+	if errors.As(err, &ce) {
+		ce.Root = h.resolve(scriptPath)
+		return ce
+	}
+	return fmt.Errorf("%s: %v", what, err)
+}
+
+// moduleFile names the file a module of a bundle was read from, empty for an
+// inline root script.
+func (h *Handler) moduleFile(module, scriptPath string) string {
+	if module == bundler.Rootname {
+		return scriptPath
+	}
+	return module + h.extension
+}
+
+func (h *Handler) scriptError(fname string, se *lua.SyntaxError) *ScriptError {
+	if fname == "" {
+		return &ScriptError{Inline: true, Line: se.Line, Col: se.Col, Msg: se.Msg}
+	}
+	return &ScriptError{File: h.resolve(fname), Line: se.Line, Col: se.Col, Msg: se.Msg}
+}
+
+// resolve gives the full path of a file read by h.Reader, when it can tell.
+func (h *Handler) resolve(fname string) string {
+	if fname == "" {
+		return ""
+	}
+	if r, ok := h.Reader.(file.PathResolver); ok {
+		if full, err := r.Resolve(fname); err == nil {
+			return full
+		}
+	}
+	return fname
+}
+
+func (h *Handler) inlineLimit() int {
+	if h.InlineLimit != 0 {
+		return h.InlineLimit
+	}
+	return DefaultInlineLimit
 }
 
 // WhileWritingToFile consolidates the logic and flow of conditionally writing
@@ -102,14 +411,31 @@ func (h *Handler) WhileWritingToFile(rawj map[string]interface{}, possiblefname
 			h.key, rawscript, rawscript)
 	}
 
+	var shared map[string]string
+	if h.splitShared != nil {
+		var err error
+		if script, shared, err = h.splitShared(script); err != nil {
+			return HandleAction{}, fmt.Errorf("SplitShared(...): %v", err)
+		}
+	}
 	allScripts, rootName, err := h.unbundle(script)
 	if err != nil {
 		return HandleAction{}, fmt.Errorf("UnbundleAll(...): %v", err)
 	}
+	for name, body := range allScripts {
+		// the stub of a shared module stands in for a module kept in Global,
+		// and the require lines using it are untouched
+		if _, ok := bundler.IsSharedStub(body); ok && name != rootName {
+			delete(allScripts, name)
+		}
+	}
+	for name, body := range shared {
+		allScripts[name] = body
+	}
 	// root bundle is promised to exist
 	rootscript, _ := allScripts[rootName]
 	returnAction := HandleAction{Noop: false}
-	if len(rootscript) > 80 {
+	if len(rootscript) > h.inlineLimit() {
 		err = h.DefaultWriter.EncodeToFile(rootscript, possiblefname)
 		if err != nil {
 			return HandleAction{}, fmt.Errorf("EncodeToFile(<root script>, %s): %v", possiblefname, err)
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"ModCreator/bundler"
 	"ModCreator/tests"
+	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 // constructors returns both handlers so each behavior is asserted for Lua and
@@ -272,3 +276,242 @@ func TestWhileReadingFromFileNoop(t *testing.T) {
 		})
 	}
 }
+
+// TestWhileReadingFromFileSyntaxError covers syntax errors being reported
+// against the file they are in, whether the object's own script or a file it
+// required.
+func TestWhileReadingFromFileSyntaxError(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rawj map[string]interface{}
+		want string
+		// a script that can't be tokenized can't be bundled either, so
+		// skipping the check doesn't help
+		failsUnchecked bool
+	}{
+		{
+			name: "inline",
+			rawj: map[string]interface{}{"LuaScript": "x = 1\nif x then"},
+			want: "inline script:2:10: 'end' expected near '<eof>'",
+		},
+		{
+			name: "own file",
+			rawj: map[string]interface{}{"LuaScript_path": "broken.ttslua"},
+			want: "broken.ttslua:2:5: unexpected symbol near ')'",
+		},
+		{
+			name: "required file",
+			rawj: map[string]interface{}{"LuaScript": "require('lib/bad')\nprint('ok')"},
+			want: "lib/bad.ttslua:3:1: 'end' expected (to close 'function' at line 1) near '<eof>'",
+		},
+		{
+			name:           "required file that can't be tokenized",
+			rawj:           map[string]interface{}{"LuaScript": "require('lib/unfinished')"},
+			want:           "lib/unfinished.ttslua:1:5: unfinished string",
+			failsUnchecked: true,
+		},
+		{
+			name: "included file",
+			rawj: map[string]interface{}{"LuaScript": "require('lib/includes')"},
+			want: "broken.ttslua:2:5: unexpected symbol near ')'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := tests.NewFF()
+			reader.Fs["broken.ttslua"] = "print(1)\nx = )"
+			reader.Fs["lib/includes.ttslua"] = "x = 1\n#include broken\ny = 2"
+			reader.Fs["lib/bad.ttslua"] = "function f()\n  return 1\n"
+			reader.Fs["lib/unfinished.ttslua"] = "x = 'abc"
+			h := NewLuaHandler()
+			h.Reader = reader
+
+			_, err := h.WhileReadingFromFile(tc.rawj)
+			if err == nil {
+				t.Fatalf("WhileReadingFromFile: wanted error, got none")
+			}
+			if err.Error() != tc.want {
+				t.Errorf("want error %q, got %q", tc.want, err.Error())
+			}
+
+			h.Lua.SkipCheck = true
+			if _, err := h.WhileReadingFromFile(tc.rawj); (err != nil) != tc.failsUnchecked {
+				t.Errorf("with SkipCheck, got error %v, want error: %v", err, tc.failsUnchecked)
+			}
+		})
+	}
+}
+
+// TestWhileReadingFromFileSourceMap covers the source map of a bundled script
+// naming the object's own file and each required file.
+func TestWhileReadingFromFileSourceMap(t *testing.T) {
+	reader := tests.NewFF()
+	reader.Fs["own.ttslua"] = "require('lib/a')\nprint(2)"
+	reader.Fs["lib/a.ttslua"] = "a = 1"
+	h := NewLuaHandler()
+	h.Reader = reader
+	h.SourceMap = true
+
+	got, err := h.WhileReadingFromFile(map[string]interface{}{"LuaScript_path": "own.ttslua"})
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	if got.SourceMap == nil {
+		t.Fatalf("wanted a source map, got none")
+	}
+	lines := strings.Split(got.Value, "\n")
+	for want, text := range map[string]string{
+		"own.ttslua:2":   "print(2)",
+		"lib/a.ttslua:1": "a = 1",
+	} {
+		found := false
+		for i, l := range lines {
+			if l != text {
+				continue
+			}
+			found = true
+			seg, line, ok := got.SourceMap.Locate(i + 1)
+			if g := fmt.Sprintf("%s:%d", seg.File, line); !ok || g != want {
+				t.Errorf("Locate(%q) = %s, want %s", text, g, want)
+			}
+		}
+		if !found {
+			t.Errorf("%q not in bundle:\n%s", text, got.Value)
+		}
+	}
+
+	h.SourceMap = false
+	if got, _ := h.WhileReadingFromFile(map[string]interface{}{"LuaScript_path": "own.ttslua"}); got.SourceMap != nil {
+		t.Errorf("wanted no source map when not asked for, got %v", got.SourceMap)
+	}
+}
+
+// TestWhileReadingFromFileMinify covers minifying bundled scripts only when
+// asked to, and reporting the size before.
+func TestWhileReadingFromFileCycle(t *testing.T) {
+	reader := tests.NewFF()
+	reader.Fs["own.ttslua"] = "require('a')"
+	reader.Fs["a.ttslua"] = "function f() return require('b') end"
+	reader.Fs["b.ttslua"] = "require('a')"
+	h := NewLuaHandler()
+	h.Reader = reader
+
+	_, err := h.WhileReadingFromFile(map[string]interface{}{"LuaScript_path": "own.ttslua"})
+	want := "circular require: a.ttslua -> b.ttslua -> a.ttslua"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+
+	h.Lua.AllowCycles = true
+	if _, err := h.WhileReadingFromFile(map[string]interface{}{"LuaScript_path": "own.ttslua"}); err != nil {
+		t.Errorf("WhileReadingFromFile with AllowCycles: %v", err)
+	}
+}
+
+func TestWhileReadingFromFileXMLWarnings(t *testing.T) {
+	reader := tests.NewFF()
+	reader.Fs["own.xml"] = "<Panel>\n  <Include src=\"ui/row\"/>\n  <Txet/>\n</Panel>"
+	reader.Fs["ui/row.xml"] = "<Row>\n  <Cell><Text fontsize=\"20\"/></Cell>\n</Row>"
+	h := NewXMLHandler()
+	h.Reader = reader
+
+	got, err := h.WhileReadingFromFile(map[string]interface{}{"XmlUI_path": "own.xml"})
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	want := []XMLWarning{
+		{File: "ui/row.xml", Line: 2, Msg: "<Text> has no attribute fontsize, did you mean fontSize?"},
+		{File: "own.xml", Line: 3, Msg: "unknown element <Txet>, did you mean <Text>?"},
+	}
+	if diff := cmp.Diff(want, got.Warnings); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	got, err = h.WhileReadingFromFile(map[string]interface{}{"XmlUI": "<Txet/>"})
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	if len(got.Warnings) != 1 || got.Warnings[0].String() != "inline XmlUI:1: unknown element <Txet>, did you mean <Text>?" {
+		t.Errorf("want a warning for the inline XmlUI, got %v", got.Warnings)
+	}
+
+	h.XML.SkipCheck = true
+	if got, _ := h.WhileReadingFromFile(map[string]interface{}{"XmlUI": "<Txet/>"}); got.Warnings != nil {
+		t.Errorf("want no warnings with SkipCheck, got %v", got.Warnings)
+	}
+}
+
+func TestWhileReadingFromFileMinify(t *testing.T) {
+	reader := tests.NewFF()
+	reader.Fs["lib/a.ttslua"] = "-- a comment\na = 1"
+	rawj := map[string]interface{}{"LuaScript": "require('lib/a')"}
+	h := NewLuaHandler()
+	h.Reader = reader
+
+	plain, err := h.WhileReadingFromFile(rawj)
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	if plain.MinifiedFrom != 0 {
+		t.Errorf("MinifiedFrom = %d without Minify, want 0", plain.MinifiedFrom)
+	}
+
+	h.Lua.Minify = true
+	got, err := h.WhileReadingFromFile(rawj)
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	if got.MinifiedFrom != len(plain.Value) {
+		t.Errorf("MinifiedFrom = %d, want %d", got.MinifiedFrom, len(plain.Value))
+	}
+	if strings.Contains(got.Value, "a comment") || len(got.Value) >= len(plain.Value) {
+		t.Errorf("script was not minified:\n%s", got.Value)
+	}
+}
+
+// TestSharedModulesRoundTrip covers a script built with a shared module:
+// reading it registers a stub, and writing it back out drops the stub and
+// writes the module from the shared modules in Global's script instead.
+func TestSharedModulesRoundTrip(t *testing.T) {
+	reader := tests.NewFF()
+	reader.Fs["lib/util.ttslua"] = "function util() end"
+	h := NewLuaHandler()
+	h.Reader = reader
+	h.Lua.Shared = []string{"lib/*"}
+
+	root := "require('lib/util')\nutil()"
+	act, err := h.WhileReadingFromFile(map[string]interface{}{"LuaScript": root})
+	if err != nil {
+		t.Fatalf("WhileReadingFromFile: %v", err)
+	}
+	if strings.Contains(act.Value, "function util()") {
+		t.Errorf("shared module was bundled into the script:\n%s", act.Value)
+	}
+	global, _ := bundler.AddSharedModules(act.Value, []bundler.SharedModule{
+		{Name: "lib/util", Source: reader.Fs["lib/util.ttslua"]},
+	}, nil)
+
+	for _, tc := range []struct {
+		name, script string
+		wantSrc      map[string]string
+	}{
+		{name: "object", script: act.Value, wantSrc: map[string]string{}},
+		{name: "Global", script: global, wantSrc: map[string]string{"lib/util.ttslua": "function util() end"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewLuaHandler()
+			w.DefaultWriter = tests.NewFF()
+			src := tests.NewFF()
+			w.SrcWriter = src
+			got, err := w.WhileWritingToFile(map[string]interface{}{"LuaScript": tc.script}, "root.ttslua")
+			if err != nil {
+				t.Fatalf("WhileWritingToFile: %v", err)
+			}
+			if got.Key != "LuaScript" || got.Value != root {
+				t.Errorf("root script = %s: %q, want LuaScript: %q", got.Key, got.Value, root)
+			}
+			if diff := cmp.Diff(tc.wantSrc, src.Fs); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package lua
+
+// TargetKind says which script a call by name goes to.
+type TargetKind int
+
+// Kinds of Target.
+const (
+	// Unknown is a script only known when the script runs.
+	Unknown TargetKind = iota
+	// Self is the calling script's own.
+	Self
+	// Global is Global's script.
+	Global
+	// GUID is the script of the object with the Target's GUID.
+	GUID
+)
+
+// Target is the script a function is called by name in.
+type Target struct {
+	Kind TargetKind
+	GUID string
+}
+
+// NamedCall is a function of another script called by its name: through
+// obj.call("name") or Global.call("name"), or as the click_function of a
+// button, which TTS calls when it is clicked.
+type NamedCall struct {
+	Func   string
+	Target Target
+	// Button is set for a click_function
+	Button bool
+	Pos    Pos
+}
+
+// FindGlobalFunctions lists the global functions src defines, which another
+// script can call by name: function f() and f = function() anywhere in it,
+// and _G.f = function(), but not local functions or functions of tables.
+func FindGlobalFunctions(src string) (map[string]bool, error) {
+	toks, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	defs := map[string]bool{}
+	// open are the table constructors and blocks open at each token: a name
+	// assigned directly inside a table constructor is one of its fields
+	open := []string{}
+	for i, t := range toks {
+		switch {
+		case isOp(t, "{"):
+			open = append(open, "{")
+		case t.Kind == Keyword && (t.Text == "function" || t.Text == "do" || t.Text == "if" || t.Text == "repeat"):
+			open = append(open, t.Text)
+		case isOp(t, "}") || t.Kind == Keyword && (t.Text == "end" || t.Text == "until"):
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+		if t.Kind == Keyword && t.Text == "function" {
+			if i > 0 && toks[i-1].Kind == Keyword && toks[i-1].Text == "local" {
+				continue
+			}
+			if toks[i+1].Kind == Name && isOp(toks[i+2], "(") {
+				defs[toks[i+1].Text] = true
+			}
+			continue
+		}
+		if t.Kind != Name || !isOp(toks[i+1], "=") || !(toks[i+2].Kind == Keyword && toks[i+2].Text == "function") {
+			continue
+		}
+		switch {
+		case i >= 2 && isOp(toks[i-1], ".") && toks[i-2].Kind == Name && toks[i-2].Text == "_G":
+			defs[t.Text] = true
+		case len(open) > 0 && open[len(open)-1] == "{",
+			i > 0 && (isOp(toks[i-1], ".", ":", ",") || toks[i-1].Kind == Keyword && toks[i-1].Text == "local"):
+			// a field, or one of several names being assigned
+		default:
+			defs[t.Text] = true
+		}
+	}
+	return defs, nil
+}
+
+// FindNamedCalls lists the calls of src to functions of other scripts by a
+// name given as a string literal, in order. The target is worked out where
+// it is self, Global, getObjectFromGUID of a string literal, or a variable
+// last set to one of those.
+func FindNamedCalls(src string) ([]NamedCall, error) {
+	toks, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	// vars are the variables last set to getObjectFromGUID("..."), regardless
+	// of scope
+	vars := map[string]string{}
+	calls := []NamedCall{}
+	// braces are the token indices of the table constructors open
+	braces := []int{}
+	for i, t := range toks {
+		switch {
+		case isOp(t, "{"):
+			braces = append(braces, i)
+		case isOp(t, "}"):
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		case t.Kind == Name && isOp(toks[i+1], "="):
+			if i > 0 && isOp(toks[i-1], ".", ":") {
+				break
+			}
+			if guid, n := guidLookup(toks, i+2); n > 0 {
+				vars[t.Text] = guid
+			} else {
+				delete(vars, t.Text)
+			}
+			if t.Text == "click_function" && len(braces) > 0 && toks[i+2].Kind == String {
+				owner := Target{Kind: Global}
+				if j, ok := tableField(toks, braces[len(braces)-1], "function_owner"); ok {
+					owner = target(toks, j, vars)
+				}
+				calls = append(calls, NamedCall{Func: toks[i+2].Value, Target: owner, Button: true, Pos: toks[i+2].Pos})
+			}
+		case t.Kind == Name && t.Text == "call" && i >= 2 && isOp(toks[i-1], ".", ":"):
+			name, ok := "", false
+			switch next := toks[i+1]; {
+			case next.Kind == String:
+				name, ok = next.Value, true
+			case isOp(next, "(") && toks[i+2].Kind == String && isOp(toks[i+3], ",", ")"):
+				name, ok = toks[i+2].Value, true
+			}
+			if ok {
+				calls = append(calls, NamedCall{Func: name, Target: receiver(toks, i-1, vars), Pos: t.Pos})
+			}
+		}
+	}
+	return calls, nil
+}
+
+// guidLookup reads getObjectFromGUID("...") starting at toks[i], returning
+// the GUID and how many tokens it takes, 0 if it isn't there.
+func guidLookup(toks []Token, i int) (string, int) {
+	if toks[i].Kind != Name || toks[i].Text != "getObjectFromGUID" {
+		return "", 0
+	}
+	if toks[i+1].Kind == String {
+		return toks[i+1].Value, 2
+	}
+	if isOp(toks[i+1], "(") && toks[i+2].Kind == String && isOp(toks[i+3], ")") {
+		return toks[i+2].Value, 4
+	}
+	return "", 0
+}
+
+// target reads the script an expression starting at toks[i] refers to.
+func target(toks []Token, i int, vars map[string]string) Target {
+	if guid, n := guidLookup(toks, i); n > 0 {
+		if end := toks[i+n]; isOp(end, ",", ";", "}") {
+			return Target{Kind: GUID, GUID: guid}
+		}
+		return Target{}
+	}
+	if toks[i].Kind != Name || !isOp(toks[i+1], ",", ";", "}") {
+		return Target{}
+	}
+	return nameTarget(toks[i].Text, vars)
+}
+
+// receiver reads the script the expression ending just before the . or : at
+// toks[dot] refers to.
+func receiver(toks []Token, dot int, vars map[string]string) Target {
+	prev := toks[dot-1]
+	if prev.Kind == Name {
+		if dot >= 2 && isOp(toks[dot-2], ".", ":") {
+			// a field, like objs.board
+			return Target{}
+		}
+		return nameTarget(prev.Text, vars)
+	}
+	for _, n := range []int{4, 2} {
+		if dot < n {
+			continue
+		}
+		if guid, read := guidLookup(toks, dot-n); read == n && (dot == n || !isOp(toks[dot-n-1], ".", ":")) {
+			return Target{Kind: GUID, GUID: guid}
+		}
+	}
+	return Target{}
+}
+
+func nameTarget(name string, vars map[string]string) Target {
+	switch name {
+	case "self":
+		return Target{Kind: Self}
+	case "Global":
+		return Target{Kind: Global}
+	}
+	if guid, ok := vars[name]; ok {
+		return Target{Kind: GUID, GUID: guid}
+	}
+	return Target{}
+}
+
+// tableField finds the value of field name in the table constructor opened at
+// toks[open], not counting those of tables nested in it.
+func tableField(toks []Token, open int, name string) (int, bool) {
+	depth := 0
+	for i := open + 1; i < len(toks)-2; i++ {
+		t := toks[i]
+		switch {
+		case isOp(t, "{", "(", "["):
+			depth++
+		case isOp(t, "}", ")", "]"):
+			if depth == 0 {
+				return 0, false
+			}
+			depth--
+		case depth == 0 && t.Kind == Name && t.Text == name && isOp(toks[i+1], "="):
+			return i + 2, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindGlobalFunctions(t *testing.T) {
+	src := `function onLoad() end
+local function helper() end
+onClick = function() end
+_G.dynamic = function() end
+local handlers = {
+  onField = function() end,
+  nested = { deeper = function() end },
+}
+function handlers.method() end
+function handlers:other() end
+local notGlobal = function() end
+if ready then
+  function late() end
+  lateToo = function() end
+end
+`
+	got, err := FindGlobalFunctions(src)
+	if err != nil {
+		t.Fatalf("FindGlobalFunctions(): %v", err)
+	}
+	want := map[string]bool{"onLoad": true, "onClick": true, "dynamic": true, "late": true, "lateToo": true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestFindNamedCalls(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		want        []NamedCall
+	}{
+		{
+			name:  "call",
+			input: "Global.call(\"setup\", {})\nself.call('reset')\ngetObjectFromGUID(\"a1b2c3\").call(\"deal\")\nlocal deck = getObjectFromGUID(\"d4e5f6\")\ndeck.call(\"shuffleAll\")\nobjs[1].call(\"x\")\ndeck = pick()\ndeck.call(\"y\")\nGlobal.call(name)",
+			want: []NamedCall{
+				{Func: "setup", Target: Target{Kind: Global}, Pos: Pos{Offset: 7, Line: 1, Col: 8}},
+				{Func: "reset", Target: Target{Kind: Self}, Pos: Pos{Offset: 30, Line: 2, Col: 6}},
+				{Func: "deal", Target: Target{Kind: GUID, GUID: "a1b2c3"}, Pos: Pos{Offset: 72, Line: 3, Col: 29}},
+				{Func: "shuffleAll", Target: Target{Kind: GUID, GUID: "d4e5f6"}, Pos: Pos{Offset: 131, Line: 5, Col: 6}},
+				{Func: "x", Pos: Pos{Offset: 158, Line: 6, Col: 9}},
+				{Func: "y", Pos: Pos{Offset: 187, Line: 8, Col: 6}},
+			},
+		},
+		{
+			name: "click_function",
+			input: `self.createButton({click_function = "onClick", function_owner = self, label = "a"})
+self.createButton({
+  click_function = "onGlobal",
+  style = {function_owner = self},
+})
+board.createButton({click_function = "onBoard", function_owner = getObjectFromGUID("a1b2c3")})
+self.createButton({click_function = "onOther", function_owner = owner})
+self.createButton({click_function = fn})`,
+			want: []NamedCall{
+				{Func: "onClick", Target: Target{Kind: Self}, Button: true, Pos: Pos{Offset: 36, Line: 1, Col: 37}},
+				{Func: "onGlobal", Target: Target{Kind: Global}, Button: true, Pos: Pos{Offset: 123, Line: 3, Col: 20}},
+				{Func: "onBoard", Target: Target{Kind: GUID, GUID: "a1b2c3"}, Button: true, Pos: Pos{Offset: 210, Line: 6, Col: 38}},
+				{Func: "onOther", Target: Target{}, Button: true, Pos: Pos{Offset: 304, Line: 7, Col: 37}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FindNamedCalls(tc.input)
+			if err != nil {
+				t.Fatalf("FindNamedCalls(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
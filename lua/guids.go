@@ -0,0 +1,31 @@
+package lua
+
+// GUIDLookup is a call to getObjectFromGUID with a string literal.
+type GUIDLookup struct {
+	GUID string
+	Pos  Pos
+}
+
+// FindGUIDLookups lists every call to getObjectFromGUID in src whose argument
+// is a single string literal, in order, in any of Lua's call forms. Calls
+// whose GUID is only known when the script runs are left out.
+func FindGUIDLookups(src string) ([]GUIDLookup, error) {
+	toks, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	lookups := []GUIDLookup{}
+	for i, t := range toks {
+		if t.Kind != Name || t.Text != "getObjectFromGUID" {
+			continue
+		}
+		next := toks[i+1]
+		switch {
+		case next.Kind == String:
+			lookups = append(lookups, GUIDLookup{GUID: next.Value, Pos: t.Pos})
+		case isOp(next, "(") && i+3 < len(toks) && toks[i+2].Kind == String && isOp(toks[i+3], ")"):
+			lookups = append(lookups, GUIDLookup{GUID: toks[i+2].Value, Pos: t.Pos})
+		}
+	}
+	return lookups, nil
+}
@@ -0,0 +1,42 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindGUIDLookups(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		want        []GUIDLookup
+	}{
+		{
+			name:  "call forms",
+			input: "local a = getObjectFromGUID(\"a1b2c3\")\nlocal b = getObjectFromGUID 'd4e5f6'\n_G.getObjectFromGUID([[0f0f0f]])",
+			want: []GUIDLookup{
+				{GUID: "a1b2c3", Pos: Pos{Offset: 10, Line: 1, Col: 11}},
+				{GUID: "d4e5f6", Pos: Pos{Offset: 48, Line: 2, Col: 11}},
+				{GUID: "0f0f0f", Pos: Pos{Offset: 78, Line: 3, Col: 4}},
+			},
+		},
+		{
+			name: "comments, strings and dynamic GUIDs are ignored",
+			input: `-- getObjectFromGUID("commented")
+local s = "getObjectFromGUID('in a string')"
+getObjectFromGUID(guid)
+getObjectFromGUID("ab" .. "cdef")`,
+			want: []GUIDLookup{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FindGUIDLookups(tc.input)
+			if err != nil {
+				t.Fatalf("FindGUIDLookups(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+// Package lua understands enough of the Lua language as accepted by Tabletop
+// Simulator (Lua 5.2 via MoonSharp) to find requires and check scripts, without
+// running them.
+package lua
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind classifies a Token.
+type Kind int
+
+// Token kinds. Comments and whitespace are skipped by the lexer.
+const (
+	EOF Kind = iota
+	Name
+	Keyword
+	String
+	Number
+	Op
+)
+
+func (k Kind) String() string {
+	switch k {
+	case EOF:
+		return "end of file"
+	case Name:
+		return "name"
+	case Keyword:
+		return "keyword"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Op:
+		return "operator"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Pos is a position in a script. Line and Col count from 1; Col counts bytes.
+type Pos struct {
+	Offset, Line, Col int
+}
+
+// Token is a single lexical element of a script.
+type Token struct {
+	Kind Kind
+	// Text is the token exactly as written in the source.
+	Text string
+	// Value is the decoded contents of a String token.
+	Value string
+	Pos   Pos
+}
+
+// SyntaxError describes a script that is not valid Lua.
+type SyntaxError struct {
+	Line, Col int
+	Msg       string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+// Operators longest first, so that the first match is the right one. "!=" is
+// MoonSharp's alias for "~=".
+var operators = []string{
+	"...", "..", "==", "~=", "!=", "<=", ">=", "::",
+	"+", "-", "*", "/", "%", "^", "#", "&", "~", "|", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+// Lex splits a script into tokens, ending with a single EOF token.
+func Lex(src string) ([]Token, error) {
+	l := &lexer{src: src, line: 1, lineStart: 0}
+	if strings.HasPrefix(src, "\xEF\xBB\xBF") {
+		l.off = 3
+		l.lineStart = 3
+	}
+	// a leading #! line is allowed and ignored, as by the reference interpreter
+	if strings.HasPrefix(src[l.off:], "#") {
+		l.skipLine()
+	}
+	toks := []Token{}
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, t)
+		if t.Kind == EOF {
+			return toks, nil
+		}
+	}
+}
+
+type lexer struct {
+	src       string
+	off       int
+	line      int
+	lineStart int
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Offset: l.off, Line: l.line, Col: l.off - l.lineStart + 1}
+}
+
+func (l *lexer) errorf(p Pos, format string, a ...interface{}) error {
+	return &SyntaxError{Line: p.Line, Col: p.Col, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (l *lexer) peek(i int) byte {
+	if l.off+i < len(l.src) {
+		return l.src[l.off+i]
+	}
+	return 0
+}
+
+// newline consumes a line break of any style, counting it once.
+func (l *lexer) newline() {
+	c := l.src[l.off]
+	l.off++
+	if l.off < len(l.src) && (l.src[l.off] == '\r' || l.src[l.off] == '\n') && l.src[l.off] != c {
+		l.off++
+	}
+	l.line++
+	l.lineStart = l.off
+}
+
+func (l *lexer) skipLine() {
+	for l.off < len(l.src) && l.src[l.off] != '\n' && l.src[l.off] != '\r' {
+		l.off++
+	}
+}
+
+func (l *lexer) next() (Token, error) {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == '\n' || c == '\r':
+			l.newline()
+		case c == ' ' || c == '\t' || c == '\f' || c == '\v':
+			l.off++
+		case c == '-' && l.peek(1) == '-':
+			start := l.pos()
+			l.off += 2
+			if level := l.longBracketLevel(); level >= 0 {
+				if _, err := l.longBracket(start, level, "comment"); err != nil {
+					return Token{}, err
+				}
+				continue
+			}
+			l.skipLine()
+		default:
+			return l.token()
+		}
+	}
+	return Token{Kind: EOF, Pos: l.pos()}, nil
+}
+
+func (l *lexer) token() (Token, error) {
+	start := l.pos()
+	c := l.src[l.off]
+	switch {
+	case isNameStart(c):
+		for l.off < len(l.src) && isNameChar(l.src[l.off]) {
+			l.off++
+		}
+		text := l.src[start.Offset:l.off]
+		kind := Name
+		if keywords[text] {
+			kind = Keyword
+		}
+		return Token{Kind: kind, Text: text, Pos: start}, nil
+	case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+		return l.number(start)
+	case c == '"' || c == '\'':
+		return l.shortString(start)
+	case c == '[':
+		if level := l.longBracketLevel(); level >= 0 {
+			v, err := l.longBracket(start, level, "string")
+			if err != nil {
+				return Token{}, err
+			}
+			return Token{Kind: String, Text: l.src[start.Offset:l.off], Value: v, Pos: start}, nil
+		}
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.off:], op) {
+			l.off += len(op)
+			return Token{Kind: Op, Text: op, Pos: start}, nil
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return Token{}, l.errorf(start, "unexpected symbol %q", r)
+}
+
+// longBracketLevel reports the level of a long bracket opening at the current
+// offset, [[ being 0 and [==[ being 2, or -1 if there isn't one. It does not
+// consume anything.
+func (l *lexer) longBracketLevel() int {
+	if l.peek(0) != '[' {
+		return -1
+	}
+	level := 0
+	for l.peek(level+1) == '=' {
+		level++
+	}
+	if l.peek(level+1) != '[' {
+		return -1
+	}
+	return level
+}
+
+// longBracket consumes a long bracket of the given level and returns its
+// contents. A newline directly after the opening bracket is not part of them.
+func (l *lexer) longBracket(start Pos, level int, what string) (string, error) {
+	l.off += level + 2
+	if l.off < len(l.src) && (l.src[l.off] == '\n' || l.src[l.off] == '\r') {
+		l.newline()
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	var b strings.Builder
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		if c == ']' && strings.HasPrefix(l.src[l.off:], closing) {
+			l.off += len(closing)
+			return b.String(), nil
+		}
+		if c == '\n' || c == '\r' {
+			l.newline()
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteByte(c)
+		l.off++
+	}
+	return "", l.errorf(start, "unfinished long %s", what)
+}
+
+func (l *lexer) shortString(start Pos) (Token, error) {
+	quote := l.src[l.off]
+	l.off++
+	var b strings.Builder
+	for {
+		if l.off >= len(l.src) {
+			return Token{}, l.errorf(start, "unfinished string")
+		}
+		c := l.src[l.off]
+		switch c {
+		case quote:
+			l.off++
+			return Token{Kind: String, Text: l.src[start.Offset:l.off], Value: b.String(), Pos: start}, nil
+		case '\n', '\r':
+			return Token{}, l.errorf(start, "unfinished string")
+		case '\\':
+			if err := l.escape(&b); err != nil {
+				return Token{}, err
+			}
+		default:
+			b.WriteByte(c)
+			l.off++
+		}
+	}
+}
+
+var simpleEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t',
+	'v': '\v', '\\': '\\', '"': '"', '\'': '\'',
+}
+
+// escape decodes the escape sequence at the current offset into b.
+func (l *lexer) escape(b *strings.Builder) error {
+	at := l.pos()
+	l.off++
+	if l.off >= len(l.src) {
+		return l.errorf(at, "unfinished string")
+	}
+	c := l.src[l.off]
+	if v, ok := simpleEscapes[c]; ok {
+		b.WriteByte(v)
+		l.off++
+		return nil
+	}
+	switch {
+	case c == '\n' || c == '\r':
+		b.WriteByte('\n')
+		l.newline()
+	case c == 'z':
+		l.off++
+		for l.off < len(l.src) {
+			switch l.src[l.off] {
+			case '\n', '\r':
+				l.newline()
+			case ' ', '\t', '\f', '\v':
+				l.off++
+			default:
+				return nil
+			}
+		}
+	case c == 'x':
+		hex := l.src[l.off+1 : min(l.off+3, len(l.src))]
+		v, err := strconv.ParseUint(hex, 16, 8)
+		if len(hex) != 2 || err != nil {
+			return l.errorf(at, "hexadecimal digit expected")
+		}
+		b.WriteByte(byte(v))
+		l.off += 3
+	case c == 'u' && l.peek(1) == '{':
+		end := strings.IndexByte(l.src[l.off:], '}')
+		if end < 0 {
+			return l.errorf(at, "missing '}' in \\u{xxxx}")
+		}
+		v, err := strconv.ParseUint(l.src[l.off+2:l.off+end], 16, 32)
+		if err != nil || v > utf8.MaxRune {
+			return l.errorf(at, "UTF-8 value too large")
+		}
+		b.WriteRune(rune(v))
+		l.off += end + 1
+	case isDigit(c):
+		n := 0
+		for i := 0; i < 3 && l.off < len(l.src) && isDigit(l.src[l.off]); i++ {
+			n = n*10 + int(l.src[l.off]-'0')
+			l.off++
+		}
+		if n > 255 {
+			return l.errorf(at, "decimal escape too large")
+		}
+		b.WriteByte(byte(n))
+	default:
+		return l.errorf(at, "invalid escape sequence '\\%c'", c)
+	}
+	return nil
+}
+
+func (l *lexer) number(start Pos) (Token, error) {
+	isHex := l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X')
+	exp := "eE"
+	if isHex {
+		l.off += 2
+		exp = "pP"
+	}
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case strings.IndexByte(exp, c) >= 0:
+			l.off++
+			if l.peek(0) == '+' || l.peek(0) == '-' {
+				l.off++
+			}
+		case isDigit(c) || c == '.' || (isHex && isHexDigit(c)):
+			l.off++
+		default:
+			goto done
+		}
+	}
+done:
+	// a number running straight into a name, like 3x, is malformed
+	if l.off < len(l.src) && isNameChar(l.src[l.off]) {
+		for l.off < len(l.src) && isNameChar(l.src[l.off]) {
+			l.off++
+		}
+		return Token{}, l.errorf(start, "malformed number near '%s'", l.src[start.Offset:l.off])
+	}
+	text := l.src[start.Offset:l.off]
+	if !validNumber(text) {
+		return Token{}, l.errorf(start, "malformed number near '%s'", text)
+	}
+	return Token{Kind: Number, Text: text, Pos: start}, nil
+}
+
+func validNumber(text string) bool {
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		body := text[2:]
+		if i := strings.IndexAny(body, "pP"); i >= 0 {
+			if _, err := strconv.Atoi(strings.TrimPrefix(body[i+1:], "+")); err != nil {
+				return false
+			}
+			body = body[:i]
+		}
+		digits := strings.Replace(body, ".", "", 1)
+		if digits == "" || strings.Contains(digits, ".") {
+			return false
+		}
+		for i := 0; i < len(digits); i++ {
+			if !isHexDigit(digits[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	_, err := strconv.ParseFloat(text, 64)
+	return err == nil || err.(*strconv.NumError).Err == strconv.ErrRange
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
@@ -0,0 +1,119 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLex(t *testing.T) {
+	type tok struct {
+		Kind        Kind
+		Text, Value string
+		Line, Col   int
+	}
+	for _, tc := range []struct {
+		name, input string
+		want        []tok
+	}{
+		{
+			name:  "names keywords and operators",
+			input: "local a = b.c ~= nil",
+			want: []tok{
+				{Keyword, "local", "", 1, 1},
+				{Name, "a", "", 1, 7},
+				{Op, "=", "", 1, 9},
+				{Name, "b", "", 1, 11},
+				{Op, ".", "", 1, 12},
+				{Name, "c", "", 1, 13},
+				{Op, "~=", "", 1, 15},
+				{Keyword, "nil", "", 1, 18},
+				{EOF, "", "", 1, 21},
+			},
+		},
+		{
+			name:  "comments are skipped",
+			input: "-- one\n--[==[ two\n]] still ]==] x --[[ three ]] y",
+			want: []tok{
+				{Name, "x", "", 3, 15},
+				{Name, "y", "", 3, 31},
+				{EOF, "", "", 3, 32},
+			},
+		},
+		{
+			name: "strings",
+			input: `'a\'b' "c\n\65\x42\z
+			   d" [[
+e]] [=[f]]g]=]`,
+			want: []tok{
+				{String, `'a\'b'`, "a'b", 1, 1},
+				{String, "\"c\\n\\65\\x42\\z\n\t\t\t   d\"", "c\nABd", 1, 8},
+				{String, "[[\ne]]", "e", 2, 10},
+				{String, "[=[f]]g]=]", "f]]g", 3, 5},
+				{EOF, "", "", 3, 15},
+			},
+		},
+		{
+			name:  "numbers",
+			input: "3 3.0 .5 3e2 0xff 0x1p4 5e-1",
+			want: []tok{
+				{Number, "3", "", 1, 1},
+				{Number, "3.0", "", 1, 3},
+				{Number, ".5", "", 1, 7},
+				{Number, "3e2", "", 1, 10},
+				{Number, "0xff", "", 1, 14},
+				{Number, "0x1p4", "", 1, 19},
+				{Number, "5e-1", "", 1, 25},
+				{EOF, "", "", 1, 29},
+			},
+		},
+		{
+			name:  "crlf counts as one line",
+			input: "a\r\nb\n\rc",
+			want: []tok{
+				{Name, "a", "", 1, 1},
+				{Name, "b", "", 2, 1},
+				{Name, "c", "", 3, 1},
+				{EOF, "", "", 3, 2},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			toks, err := Lex(tc.input)
+			if err != nil {
+				t.Fatalf("Lex(): %v", err)
+			}
+			got := []tok{}
+			for _, tk := range toks {
+				got = append(got, tok{tk.Kind, tk.Text, tk.Value, tk.Pos.Line, tk.Pos.Col})
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input, want string
+	}{
+		{input: "x = 'abc", want: "1:5: unfinished string"},
+		{input: "x = \"a\nb\"", want: "1:5: unfinished string"},
+		{input: "\n  --[[ never closed", want: "2:3: unfinished long comment"},
+		{input: "x = [==[ a ]=]", want: "1:5: unfinished long string"},
+		{input: "x = 3x", want: "1:5: malformed number near '3x'"},
+		{input: "x = 1..2", want: "1:5: malformed number near '1..2'"},
+		{input: "x = $", want: "1:5: unexpected symbol '$'"},
+		{input: `x = "\q"`, want: `1:6: invalid escape sequence '\q'`},
+	} {
+		_, err := Lex(tc.input)
+		if err == nil {
+			t.Errorf("Lex(%q): wanted error, got none", tc.input)
+			continue
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("Lex(%q): want error %q, got %q", tc.input, tc.want, got)
+		}
+	}
+}
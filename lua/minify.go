@@ -0,0 +1,62 @@
+package lua
+
+import (
+	"strings"
+)
+
+// Minify strips the comments and redundant whitespace from src. Identifiers
+// are left alone, and every token stays on the line it was on, so line numbers
+// in errors (and source maps) still point at the right source line; only
+// columns change.
+func Minify(src string) (string, error) {
+	toks, err := Lex(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.Grow(len(src))
+	line := 1
+	var prev *Token
+	for i := range toks {
+		t := &toks[i]
+		if t.Kind == EOF {
+			break
+		}
+		switch {
+		case t.Pos.Line > line:
+			b.WriteString(strings.Repeat("\n", t.Pos.Line-line))
+		case prev != nil && needsSpace(prev, t):
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.Text)
+		line = t.Pos.Line + lineBreaks(t.Text)
+		prev = t
+	}
+	return b.String(), nil
+}
+
+// needsSpace reports whether a and b would lex differently if written with
+// nothing between them, such as two names running together or "- -" turning
+// into a comment.
+func needsSpace(a, b *Token) bool {
+	// the leading space keeps a "#" from being taken for a #! line
+	toks, err := Lex(" " + a.Text + b.Text)
+	return err != nil || len(toks) != 3 || toks[0].Text != a.Text || toks[1].Text != b.Text
+}
+
+// lineBreaks counts the line breaks in s the way the lexer does, so that
+// "\r\n" and "\n\r" count once.
+func lineBreaks(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\n' && c != '\r' {
+			continue
+		}
+		n++
+		if i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r') && s[i+1] != c {
+			i++
+		}
+	}
+	return n
+}
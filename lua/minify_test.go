@@ -0,0 +1,77 @@
+package lua
+
+import (
+	"testing"
+)
+
+func TestMinify(t *testing.T) {
+	for _, tc := range []struct {
+		name, input, want string
+	}{
+		{
+			name:  "comments and indentation",
+			input: "-- header\nlocal a = 1 -- one\n  --[[ long\n  comment ]]\n  if a  ==  1 then\n    print( a )\n  end\n",
+			want:  "\nlocal a=1\n\n\nif a==1 then\nprint(a)\nend",
+		},
+		{
+			name:  "spaces that matter are kept",
+			input: "x = a - -b\ny = 1 .. 2\nz = a .. ..b\nw = #t",
+			want:  "x=a- -b\ny=1 ..2\nz=a.. ..b\nw=#t",
+		},
+		{
+			name:  "strings are untouched",
+			input: "s = 'a  -- b'\nt = [[\n  keep   this\n]] u = 1",
+			want:  "s='a  -- b'\nt=[[\n  keep   this\n]]u=1",
+		},
+		{
+			name:  "long brackets inside an index",
+			input: "t[ [[k]] ] = 1",
+			want:  "t[ [[k]]]=1",
+		},
+		{
+			name:  "crlf",
+			input: "a = 1\r\n\r\nb = 2\r\n",
+			want:  "a=1\n\nb=2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Minify(tc.input)
+			if err != nil {
+				t.Fatalf("Minify(): %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Minify(%q)\nwant %q\ngot  %q", tc.input, tc.want, got)
+			}
+			assertSameTokens(t, tc.input, got)
+		})
+	}
+
+	if _, err := Minify("x = 'unfinished"); err == nil {
+		t.Errorf("Minify(<unfinished string>): wanted error, got none")
+	}
+}
+
+// assertSameTokens checks that minifying changed nothing but the layout: the
+// same tokens on the same lines.
+func assertSameTokens(t *testing.T, src, minified string) {
+	t.Helper()
+	want, err := Lex(src)
+	if err != nil {
+		t.Fatalf("Lex(src): %v", err)
+	}
+	got, err := Lex(minified)
+	if err != nil {
+		t.Fatalf("Lex(minified): %v", err)
+	}
+	if len(want) != len(got) {
+		t.Fatalf("minifying changed the number of tokens from %d to %d", len(want), len(got))
+	}
+	for i := range want {
+		if want[i].Kind == EOF {
+			continue
+		}
+		if want[i].Text != got[i].Text || want[i].Pos.Line != got[i].Pos.Line {
+			t.Errorf("token %d: want %q on line %d, got %q on line %d", i, want[i].Text, want[i].Pos.Line, got[i].Text, got[i].Pos.Line)
+		}
+	}
+}
@@ -0,0 +1,599 @@
+package lua
+
+import (
+	"fmt"
+)
+
+// Check reports the first syntax error in src, as a *SyntaxError, or nil if it
+// is a valid chunk. It follows the Lua 5.2 grammar with the extensions
+// MoonSharp accepts: "!=" for "~=" and short anonymous functions written as
+// |a, b| a + b.
+func Check(src string) error {
+	toks, err := Lex(src)
+	if err != nil {
+		return err
+	}
+	p := &parser{toks: toks}
+	// the main chunk is a vararg function, outside any loop
+	p.varargs = []bool{true}
+	p.loops = []int{0}
+	if err := p.block(); err != nil {
+		return err
+	}
+	if p.tok().Kind != EOF {
+		return p.errorf("'<eof>' expected")
+	}
+	return nil
+}
+
+type parser struct {
+	toks []Token
+	i    int
+	// varargs tracks, per enclosing function, whether it accepts ...
+	varargs []bool
+	// loops counts enclosing loops of the current function
+	loops []int
+}
+
+func (p *parser) tok() Token {
+	return p.toks[p.i]
+}
+
+func (p *parser) peekTok(n int) Token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) advance() Token {
+	t := p.toks[p.i]
+	if t.Kind != EOF {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the current token is the keyword or operator s.
+func (p *parser) is(s string) bool {
+	t := p.tok()
+	return (t.Kind == Keyword || t.Kind == Op) && t.Text == s
+}
+
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func near(t Token) string {
+	if t.Kind == EOF {
+		return "<eof>"
+	}
+	return t.Text
+}
+
+// errorf reports an error at the current token, in the style of luac.
+func (p *parser) errorf(format string, a ...interface{}) error {
+	t := p.tok()
+	msg := fmt.Sprintf(format, a...)
+	return &SyntaxError{Line: t.Pos.Line, Col: t.Pos.Col, Msg: fmt.Sprintf("%s near '%s'", msg, near(t))}
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("'%s' expected", s)
+	}
+	return nil
+}
+
+// expectMatch consumes the token closing what was opened by open at line.
+func (p *parser) expectMatch(closing, open string, line int) error {
+	if p.accept(closing) {
+		return nil
+	}
+	if line == p.tok().Pos.Line {
+		return p.errorf("'%s' expected", closing)
+	}
+	return p.errorf("'%s' expected (to close '%s' at line %d)", closing, open, line)
+}
+
+func (p *parser) name() error {
+	if p.tok().Kind != Name {
+		return p.errorf("<name> expected")
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) blockFollows() bool {
+	t := p.tok()
+	if t.Kind == EOF {
+		return true
+	}
+	if t.Kind != Keyword {
+		return false
+	}
+	switch t.Text {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+func (p *parser) block() error {
+	for !p.blockFollows() {
+		if p.is("return") {
+			return p.retstat()
+		}
+		if err := p.statement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) retstat() error {
+	p.advance()
+	if !p.blockFollows() && !p.is(";") {
+		if err := p.exprList(); err != nil {
+			return err
+		}
+	}
+	// anything after this is reported by whatever the block belongs to, as it
+	// knows what should close it
+	p.accept(";")
+	return nil
+}
+
+func (p *parser) loopBody(body func() error) error {
+	p.loops[len(p.loops)-1]++
+	err := body()
+	p.loops[len(p.loops)-1]--
+	return err
+}
+
+func (p *parser) statement() error {
+	t := p.tok()
+	line := t.Pos.Line
+	switch {
+	case p.accept(";"):
+		return nil
+	case p.is("::"):
+		p.advance()
+		if err := p.name(); err != nil {
+			return err
+		}
+		return p.expect("::")
+	case p.is("break"):
+		if len(p.loops) == 0 || p.loops[len(p.loops)-1] == 0 {
+			return p.errorf("<break> not inside a loop")
+		}
+		p.advance()
+		return nil
+	case p.is("goto"):
+		p.advance()
+		return p.name()
+	case p.is("do"):
+		p.advance()
+		if err := p.block(); err != nil {
+			return err
+		}
+		return p.expectMatch("end", "do", line)
+	case p.is("while"):
+		p.advance()
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if err := p.expect("do"); err != nil {
+			return err
+		}
+		if err := p.loopBody(p.block); err != nil {
+			return err
+		}
+		return p.expectMatch("end", "while", line)
+	case p.is("repeat"):
+		p.advance()
+		if err := p.loopBody(p.block); err != nil {
+			return err
+		}
+		if err := p.expectMatch("until", "repeat", line); err != nil {
+			return err
+		}
+		return p.expr()
+	case p.is("if"):
+		return p.ifStat(line)
+	case p.is("for"):
+		return p.forStat(line)
+	case p.is("function"):
+		p.advance()
+		if err := p.name(); err != nil {
+			return err
+		}
+		for p.accept(".") {
+			if err := p.name(); err != nil {
+				return err
+			}
+		}
+		if p.accept(":") {
+			if err := p.name(); err != nil {
+				return err
+			}
+		}
+		return p.funcBody(line)
+	case p.is("local"):
+		p.advance()
+		if p.accept("function") {
+			if err := p.name(); err != nil {
+				return err
+			}
+			return p.funcBody(line)
+		}
+		if err := p.nameList(); err != nil {
+			return err
+		}
+		if p.accept("=") {
+			return p.exprList()
+		}
+		return nil
+	}
+	return p.exprStat()
+}
+
+func (p *parser) ifStat(line int) error {
+	p.advance()
+	if err := p.expr(); err != nil {
+		return err
+	}
+	if err := p.expect("then"); err != nil {
+		return err
+	}
+	if err := p.block(); err != nil {
+		return err
+	}
+	for p.accept("elseif") {
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if err := p.expect("then"); err != nil {
+			return err
+		}
+		if err := p.block(); err != nil {
+			return err
+		}
+	}
+	if p.accept("else") {
+		if err := p.block(); err != nil {
+			return err
+		}
+	}
+	return p.expectMatch("end", "if", line)
+}
+
+func (p *parser) forStat(line int) error {
+	p.advance()
+	if err := p.name(); err != nil {
+		return err
+	}
+	switch {
+	case p.accept("="):
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if p.accept(",") {
+			if err := p.expr(); err != nil {
+				return err
+			}
+		}
+	case p.is(",") || p.is("in"):
+		for p.accept(",") {
+			if err := p.name(); err != nil {
+				return err
+			}
+		}
+		if err := p.expect("in"); err != nil {
+			return err
+		}
+		if err := p.exprList(); err != nil {
+			return err
+		}
+	default:
+		return p.errorf("'=' or 'in' expected")
+	}
+	if err := p.expect("do"); err != nil {
+		return err
+	}
+	if err := p.loopBody(p.block); err != nil {
+		return err
+	}
+	return p.expectMatch("end", "for", line)
+}
+
+// exprStat is an assignment or a function call.
+func (p *parser) exprStat() error {
+	call, err := p.suffixedExpr()
+	if err != nil {
+		return err
+	}
+	if p.is("=") || p.is(",") {
+		if call != assignable {
+			return p.errorf("syntax error")
+		}
+		for p.accept(",") {
+			kind, err := p.suffixedExpr()
+			if err != nil {
+				return err
+			}
+			if kind != assignable {
+				return p.errorf("syntax error")
+			}
+		}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		return p.exprList()
+	}
+	if call != isCall {
+		return p.errorf("syntax error")
+	}
+	return nil
+}
+
+// exprKind is what a suffixed expression ended up being, which decides
+// whether it can stand as a statement.
+type exprKind int
+
+const (
+	other exprKind = iota
+	assignable
+	isCall
+)
+
+func (p *parser) primaryExpr() (exprKind, error) {
+	t := p.tok()
+	switch {
+	case t.Kind == Name:
+		p.advance()
+		return assignable, nil
+	case p.is("("):
+		p.advance()
+		if err := p.expr(); err != nil {
+			return other, err
+		}
+		return other, p.expectMatch(")", "(", t.Pos.Line)
+	}
+	return other, p.errorf("unexpected symbol")
+}
+
+func (p *parser) suffixedExpr() (exprKind, error) {
+	kind, err := p.primaryExpr()
+	if err != nil {
+		return other, err
+	}
+	for {
+		t := p.tok()
+		switch {
+		case p.is("."):
+			p.advance()
+			if err := p.name(); err != nil {
+				return other, err
+			}
+			kind = assignable
+		case p.is("["):
+			p.advance()
+			if err := p.expr(); err != nil {
+				return other, err
+			}
+			if err := p.expect("]"); err != nil {
+				return other, err
+			}
+			kind = assignable
+		case p.is(":"):
+			p.advance()
+			if err := p.name(); err != nil {
+				return other, err
+			}
+			if err := p.args(); err != nil {
+				return other, err
+			}
+			kind = isCall
+		case p.is("(") || p.is("{") || t.Kind == String:
+			if err := p.args(); err != nil {
+				return other, err
+			}
+			kind = isCall
+		default:
+			return kind, nil
+		}
+	}
+}
+
+func (p *parser) args() error {
+	t := p.tok()
+	switch {
+	case t.Kind == String:
+		p.advance()
+		return nil
+	case p.is("{"):
+		return p.table()
+	case p.is("("):
+		p.advance()
+		if !p.is(")") {
+			if err := p.exprList(); err != nil {
+				return err
+			}
+		}
+		return p.expectMatch(")", "(", t.Pos.Line)
+	}
+	return p.errorf("function arguments expected")
+}
+
+func (p *parser) table() error {
+	line := p.tok().Pos.Line
+	p.advance()
+	for !p.is("}") {
+		switch {
+		case p.is("["):
+			p.advance()
+			if err := p.expr(); err != nil {
+				return err
+			}
+			if err := p.expect("]"); err != nil {
+				return err
+			}
+			if err := p.expect("="); err != nil {
+				return err
+			}
+		case p.tok().Kind == Name && p.peekTok(1).Kind == Op && p.peekTok(1).Text == "=":
+			p.advance()
+			p.advance()
+		}
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if !p.accept(",") && !p.accept(";") {
+			break
+		}
+	}
+	return p.expectMatch("}", "{", line)
+}
+
+func (p *parser) funcBody(line int) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	vararg := false
+	if !p.is(")") {
+		for {
+			if p.accept("...") {
+				vararg = true
+				break
+			}
+			if err := p.name(); err != nil {
+				return err
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+	p.varargs = append(p.varargs, vararg)
+	p.loops = append(p.loops, 0)
+	err := p.block()
+	p.varargs = p.varargs[:len(p.varargs)-1]
+	p.loops = p.loops[:len(p.loops)-1]
+	if err != nil {
+		return err
+	}
+	return p.expectMatch("end", "function", line)
+}
+
+// lambda is MoonSharp's |a, b| expr shorthand for function(a, b) return expr end.
+func (p *parser) lambda() error {
+	p.advance()
+	if !p.is("|") {
+		for {
+			if err := p.name(); err != nil {
+				return err
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect("|"); err != nil {
+		return err
+	}
+	p.varargs = append(p.varargs, false)
+	p.loops = append(p.loops, 0)
+	err := p.expr()
+	p.varargs = p.varargs[:len(p.varargs)-1]
+	p.loops = p.loops[:len(p.loops)-1]
+	return err
+}
+
+func (p *parser) nameList() error {
+	if err := p.name(); err != nil {
+		return err
+	}
+	for p.accept(",") {
+		if err := p.name(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) exprList() error {
+	if err := p.expr(); err != nil {
+		return err
+	}
+	for p.accept(",") {
+		if err := p.expr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var binaryOps = map[string]bool{
+	"or": true, "and": true, "<": true, ">": true, "<=": true, ">=": true,
+	"~=": true, "!=": true, "==": true, "..": true, "+": true, "-": true,
+	"*": true, "/": true, "%": true, "^": true,
+}
+
+// expr parses an expression. Precedence doesn't change whether an expression
+// is well formed, so operators are simply chained.
+func (p *parser) expr() error {
+	for {
+		for p.is("not") || p.is("-") || p.is("#") {
+			p.advance()
+		}
+		if err := p.simpleExpr(); err != nil {
+			return err
+		}
+		t := p.tok()
+		if (t.Kind != Op && t.Kind != Keyword) || !binaryOps[t.Text] {
+			return nil
+		}
+		p.advance()
+	}
+}
+
+func (p *parser) simpleExpr() error {
+	t := p.tok()
+	switch {
+	case t.Kind == Number || t.Kind == String:
+		p.advance()
+		return nil
+	case p.is("nil") || p.is("true") || p.is("false"):
+		p.advance()
+		return nil
+	case p.is("..."):
+		if !p.varargs[len(p.varargs)-1] {
+			return p.errorf("cannot use '...' outside a vararg function")
+		}
+		p.advance()
+		return nil
+	case p.is("{"):
+		return p.table()
+	case p.is("function"):
+		p.advance()
+		return p.funcBody(t.Pos.Line)
+	case p.is("|"):
+		return p.lambda()
+	}
+	_, err := p.suffixedExpr()
+	return err
+}
@@ -0,0 +1,73 @@
+package lua
+
+import (
+	"testing"
+)
+
+func TestCheckValid(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"local a, b = 1, 2",
+		"a.b[c]:d(e)('f'){g}.h = nil",
+		"t = {1, 2; x = 3, ['y'] = 4, [5] = {},}",
+		"function a.b.c:d(x, ...) return ... end",
+		"local function f() end f()",
+		"local f = function(...) local t = {...} end",
+		"if a then elseif b then else end",
+		"for i = 1, 10, 2 do break end",
+		"for k, v in pairs(t) do end",
+		"while true do if x then break end end",
+		"repeat local x = 1 until x",
+		"do ::top:: goto top end",
+		"x = not a == b and -c or #d .. e ^ f % g",
+		"return",
+		"return 1, 2;",
+		"local s = [==[ ]] ]==] .. \"\\z\n  x\"",
+		"print 'a' print [[b]] print {c}",
+		"(f)()",
+		"a = b ~= c; d = e != f",
+		"local double = |x| x * 2",
+		"local pick = |a, b| a or b",
+		"#!/usr/bin/lua\nprint(1)",
+	} {
+		if err := Check(src); err != nil {
+			t.Errorf("Check(%q): %v", src, err)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{src: "x = ", want: "1:5: unexpected symbol near '<eof>'"},
+		{src: "var x = 1", want: "1:5: syntax error near 'x'"},
+		{src: "f() = 1", want: "1:5: syntax error near '='"},
+		{src: "x", want: "1:2: syntax error near '<eof>'"},
+		{src: "function f()\n  return 1\n", want: "3:1: 'end' expected (to close 'function' at line 1) near '<eof>'"},
+		{src: "if x then y() end end", want: "1:19: '<eof>' expected near 'end'"},
+		{src: "if x y() end", want: "1:6: 'then' expected near 'y'"},
+		{src: "for i do end", want: "1:7: '=' or 'in' expected near 'do'"},
+		{src: "local 1 = 2", want: "1:7: <name> expected near '1'"},
+		{src: "return 1\nx = 2", want: "2:1: '<eof>' expected near 'x'"},
+		{src: "function f()\n  return 1\n  x = 2\nend", want: "3:3: 'end' expected (to close 'function' at line 1) near 'x'"},
+		{src: "break", want: "1:1: <break> not inside a loop near 'break'"},
+		{src: "while x do local f = function() break end end", want: "1:33: <break> not inside a loop near 'break'"},
+		{src: "function f() return ... end", want: "1:21: cannot use '...' outside a vararg function near '...'"},
+		{src: "t = {1 2}", want: "1:8: '}' expected near '2'"},
+		{src: "print(1", want: "1:8: ')' expected near '<eof>'"},
+		{src: "x = 'unfinished", want: "1:5: unfinished string"},
+	} {
+		err := Check(tc.src)
+		if err == nil {
+			t.Errorf("Check(%q): wanted error, got none", tc.src)
+			continue
+		}
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("Check(%q): want *SyntaxError, got %T", tc.src, err)
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("Check(%q): want error %q, got %q", tc.src, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package lua
+
+// Require is a call to the global require function.
+type Require struct {
+	// Name is the module required, empty when Dynamic.
+	Name string
+	// Dynamic is set when the argument isn't a single string literal, so the
+	// module can't be known until the script runs.
+	Dynamic bool
+	// Deferred is set when the call is inside a function, so it runs when the
+	// function is called rather than when the script is loaded.
+	Deferred bool
+	Pos      Pos
+}
+
+// FindRequires lists every call to require in src, in order. All of Lua's call
+// forms are recognised: require("x"), require 'x', require [[x]] and so on.
+// Mentions of require inside comments or strings, method or field calls such as
+// t.require("x"), and uses of require as a value are not calls to it.
+func FindRequires(src string) ([]Require, error) {
+	toks, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	reqs := []Require{}
+	// blocks are the blocks open at each token, closed by "end" or, for
+	// repeat, by "until"; functions counts those that are function bodies
+	blocks := []string{}
+	functions := 0
+	for i, t := range toks {
+		if t.Kind == Keyword {
+			switch t.Text {
+			case "function", "do", "if", "repeat":
+				// while and for are opened by their do
+				blocks = append(blocks, t.Text)
+				if t.Text == "function" {
+					functions++
+				}
+			case "end", "until":
+				if len(blocks) > 0 {
+					if blocks[len(blocks)-1] == "function" {
+						functions--
+					}
+					blocks = blocks[:len(blocks)-1]
+				}
+			}
+			continue
+		}
+		if t.Kind != Name || t.Text != "require" {
+			continue
+		}
+		if i > 0 && isOp(toks[i-1], ".", ":") {
+			continue
+		}
+		r := Require{Deferred: functions > 0, Pos: t.Pos}
+		next := toks[i+1]
+		switch {
+		case next.Kind == String:
+			r.Name = next.Value
+		case isOp(next, "{"):
+			r.Dynamic = true
+		case isOp(next, "("):
+			if i+3 < len(toks) && toks[i+2].Kind == String && isOp(toks[i+3], ")") {
+				r.Name = toks[i+2].Value
+			} else {
+				r.Dynamic = true
+			}
+		default:
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, nil
+}
+
+func isOp(t Token, ops ...string) bool {
+	if t.Kind != Op {
+		return false
+	}
+	for _, op := range ops {
+		if t.Text == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindRequires(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		want        []Require
+	}{
+		{
+			name:  "call forms",
+			input: "local a = require(\"a\")\nlocal b = require 'b'\nlocal c = require [[c.d]]\nrequire('e@f/g-h')",
+			want: []Require{
+				{Name: "a", Pos: Pos{Offset: 10, Line: 1, Col: 11}},
+				{Name: "b", Pos: Pos{Offset: 33, Line: 2, Col: 11}},
+				{Name: "c.d", Pos: Pos{Offset: 55, Line: 3, Col: 11}},
+				{Name: "e@f/g-h", Pos: Pos{Offset: 71, Line: 4, Col: 1}},
+			},
+		},
+		{
+			name: "comments and strings are ignored",
+			input: `-- require("commented")
+--[[ require("block") ]]
+local s = "require('in a string')"
+local t = [[require("long string")]]`,
+			want: []Require{},
+		},
+		{
+			name:  "not the global require",
+			input: `t.require("a") t:require("b") local r = require`,
+			want:  []Require{},
+		},
+		{
+			name:  "dynamic",
+			input: "require(name)\nrequire(\"lib/\" .. name)\nrequire{\"x\"}",
+			want: []Require{
+				{Dynamic: true, Pos: Pos{Offset: 0, Line: 1, Col: 1}},
+				{Dynamic: true, Pos: Pos{Offset: 14, Line: 2, Col: 1}},
+				{Dynamic: true, Pos: Pos{Offset: 38, Line: 3, Col: 1}},
+			},
+		},
+		{
+			name: "deferred",
+			input: `if x then require("a") end
+local function f()
+  while true do require("b") end
+end
+repeat until require("c")
+local g = function() return require("d") end
+require("e")`,
+			want: []Require{
+				{Name: "a", Pos: Pos{Offset: 10, Line: 1, Col: 11}},
+				{Name: "b", Deferred: true, Pos: Pos{Offset: 62, Line: 3, Col: 17}},
+				{Name: "c", Pos: Pos{Offset: 96, Line: 5, Col: 14}},
+				{Name: "d", Deferred: true, Pos: Pos{Offset: 137, Line: 6, Col: 29}},
+				{Name: "e", Pos: Pos{Offset: 154, Line: 7, Col: 1}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FindRequires(tc.input)
+			if err != nil {
+				t.Fatalf("FindRequires(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
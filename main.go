@@ -1,202 +1,506 @@
 package main
 
 import (
-	file "ModCreator/file"
-	"ModCreator/mod"
-	"ModCreator/types"
-	"encoding/json"
+	"ModCreator/moddiff"
+	"ModCreator/project"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 )
 
-var (
-	moddir     = flag.String("moddir", "testdata/simple", "a directory containing tts mod configs")
-	bonusdir   = flag.String("bonusdir", "", "additional folder to check for Lua and XML require/include")
-	rev        = flag.Bool("reverse", false, "instead of building a json from file structure, build file structure from json.")
-	writeToSrc = flag.Bool("writesrc", false, "when unbundling Lua, save the included 'require' files to the src/ directory.")
-	modfile    = flag.String("modfile", "", "where to read from when reversing.")
-	objin      = flag.String("objin", "", "if non-empty, don't build/reverse a full mod, only an object state array")
-	objout     = flag.String("objout", "", "if building only object state list, output to this filename")
-	savedobj   = flag.Bool("savedobj", false, "if present, will add the boiler plate for TTS to recognize as saved object.")
-)
+const progName = "TTSModManager"
 
-var (
-	luasrcSubdir   = "src"
-	xmlsrcSubdir   = "xml"
-	modsettingsDir = "modsettings"
-	objectsSubdir  = "objects"
+// Exit codes shared by every subcommand.
+const (
+	exitOK = 0
+	// exitFailure means the command ran but did not succeed: a build error,
+	// differences found by diff, conflicts found by merge, or problems found by
+	// validate, check-xml, check-calls or verify-assets.
+	exitFailure = 1
+	// exitUsage means the command line itself was wrong.
+	exitUsage = 2
 )
 
+// command is a single subcommand. run receives the arguments after the
+// subcommand name and returns the process exit code.
+type command struct {
+	summary string
+	run     func(name string, args []string) int
+}
+
+var commands = map[string]command{
+	"build": {
+		summary: "generate a mod json file from a mod directory",
+		run:     runBuild,
+	},
+	"reverse": {
+		summary: "split a mod json file into a mod directory",
+		run:     runReverse,
+	},
+	"merge": {
+		summary: "merge the changes a TTS save made to a build into the mod directory",
+		run:     runMerge,
+	},
+	"build-object": {
+		summary: "generate a single downloadable object from its json file",
+		run:     runBuildObject,
+	},
+	"reverse-object": {
+		summary: "split a single downloadable object into files",
+		run:     runReverseObject,
+	},
+	"diff": {
+		summary: "compare two mods, each a json file or a mod directory",
+		run: func(name string, args []string) int {
+			return moddiff.Run(progName+" "+name, args, os.Stdout, os.Stderr)
+		},
+	},
+	"validate": {
+		summary: "build a mod in memory and report any errors without writing output",
+		run:     runValidate,
+	},
+	"check-xml": {
+		summary: "check every XmlUI of a mod against the elements and attributes TTS understands",
+		run:     runCheckXML,
+	},
+	"assets": {
+		summary: "list every asset URL of a mod and what uses it, or rewrite them by a mapping",
+		run:     runAssets,
+	},
+	"verify-assets": {
+		summary: "check that every asset URL of a mod has its file in a local mirror of TTS's Mods directory",
+		run:     runVerifyAssets,
+	},
+	"check-calls": {
+		summary: "report functions called by name across scripts, or by buttons, that their script doesn't define",
+		run:     runCheckCalls,
+	},
+	"resolve": {
+		summary: "show which search path a require or Include is read from",
+		run:     runResolve,
+	},
+	"locate": {
+		summary: "map a line of a built script back to its source file",
+		run:     runLocate,
+	},
+}
+
 func main() {
-	flag.Parse()
+	os.Exit(run(os.Args[1:]))
+}
 
-	if (*objin == "") != (*objout == "") {
-		log.Fatalln("Must set either both or neither of {objin,objout}.")
+func run(args []string) int {
+	// Anything that doesn't start with a subcommand name is the original flag
+	// based interface, kept working for existing scripts and CI.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runLegacy(args)
 	}
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			if c, ok := commands[args[1]]; ok {
+				return c.run(args[1], []string{"-h"})
+			}
+		}
+		usage(os.Stdout)
+		return exitOK
+	}
+	c, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage(os.Stderr)
+		return exitUsage
+	}
+	return c.run(name, args[1:])
+}
 
-	lua := file.NewTextOpsMulti(
-		[]string{
-			filepath.Join(*moddir, luasrcSubdir),
-			filepath.Join(*moddir, objectsSubdir),
-			filepath.Join(*bonusdir, luasrcSubdir),
-		},
-		filepath.Join(*moddir, objectsSubdir),
-	)
-	xml := file.NewTextOpsMulti(
-		[]string{
-			filepath.Join(*moddir, xmlsrcSubdir),
-			filepath.Join(*moddir, objectsSubdir),
-			filepath.Join(*bonusdir, xmlsrcSubdir),
-		},
-		filepath.Join(*moddir, objectsSubdir),
-	)
-	xmlSrc := file.NewTextOps(filepath.Join(*moddir, xmlsrcSubdir))
-	luaSrc := file.NewTextOps(filepath.Join(*moddir, luasrcSubdir))
-	ms := file.NewJSONOps(filepath.Join(*moddir, modsettingsDir))
-	objs := file.NewJSONOps(filepath.Join(*moddir, objectsSubdir))
-	objdir := file.NewDirOps(filepath.Join(*moddir, objectsSubdir))
-	rootops := file.NewJSONOps(*moddir)
-
-	// When generating a full mod with no explicit --modfile, default the output
-	// path to <moddir>/output.json. This must happen before basename/outputOps
-	// are derived, otherwise they are computed from an empty path (issue #93).
-	// Reverse mode uses --modfile as an input to read and must not be defaulted.
-	if !*rev && *modfile == "" {
-		*modfile = filepath.Join(*moddir, "output.json")
-	}
-
-	basename := filepath.Base(*modfile)
-	outputOps := file.NewJSONOps(filepath.Dir(*modfile))
-
-	// handling for saved objects instead of a full savegame
-	if *objin != "" {
-		objs = file.NewJSONOps(filepath.Dir(*objin))
-		objdir = file.NewDirOps(filepath.Dir(*objin))
-		lua = file.NewTextOpsMulti(
-			[]string{
-				filepath.Join(*moddir, luasrcSubdir),
-				filepath.Join(*bonusdir, luasrcSubdir),
-				filepath.Dir(*objin),
-			},
-			filepath.Dir(*objout),
-		)
-		xml = file.NewTextOpsMulti(
-			[]string{
-				filepath.Join(*moddir, xmlsrcSubdir),
-				filepath.Join(*bonusdir, xmlsrcSubdir),
-				filepath.Dir(*objin),
-			},
-			filepath.Dir(*objout),
-		)
-		basename = filepath.Base(*objout)
-		outputOps = file.NewJSONOps(filepath.Dir(*objout))
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", progName)
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
 	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(w, "  %-15s %s\n", n, commands[n].summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", progName)
+}
 
-	if *rev {
-		if *objin != "" {
-			*modfile = *objin
-			objs = file.NewJSONOps(filepath.Dir(*objout))
-		}
+// newFlagSet creates the flag set for a subcommand with a usage message that
+// describes it.
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n\n%s\n\nFlags:\n", progName, name, args, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
-		raw, err := prepForReverse(*moddir, *modfile)
-		if err != nil {
-			log.Fatalf("prepForReverse (%s) failed : %v", *modfile, err)
+// parseFlags parses args into fs. ok is false when the caller should exit with
+// code: exitOK after -h, exitUsage on a bad flag or stray argument.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
 		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
 
-		// Clear the objects directory to avoid orphaned files (by removing and
-		// recreating). This must run only after prepForReverse has successfully
-		// read and parsed the mod file, so a bad --modfile path can't wipe
-		// objects/ before failing (issue #90).
-		if *objin == "" {
-			if err := objdir.Clear(); err != nil {
-				log.Fatalf("Failed to clear objects directory before writing: %v", err)
-			}
-		}
+// usageError reports a missing or conflicting flag.
+func usageError(fs *flag.FlagSet, format string, a ...interface{}) int {
+	fmt.Fprintf(fs.Output(), format+"\n", a...)
+	fs.Usage()
+	return exitUsage
+}
 
-		r := mod.Reverser{
-			ModSettingsWriter: ms,
-			LuaWriter:         lua,
-			XMLWriter:         xml,
-			ObjWriter:         objs,
-			ObjDirCreator:     objdir,
-			RootWrite:         rootops,
-			OnlyObjState:      *objin,
-		}
-		if *writeToSrc {
-			r.LuaSrcWriter = luaSrc
-			r.XMLSrcWriter = xmlSrc
-		}
-		err = r.Write(raw)
-		if err != nil {
-			log.Fatalf("reverse.Write(<%s>) failed : %v", *modfile, err)
-		}
-		return
-	}
-	// setting this to empty instead of default return value (".") if not found
-	OnlyObjStates := filepath.Base(*objin)
-	if OnlyObjStates == "." {
-		OnlyObjStates = ""
-	}
-
-	m := &mod.Mod{
-		Lua:           lua,
-		XML:           xml,
-		Modsettings:   ms,
-		Objs:          objs,
-		Objdirs:       objdir,
-		RootRead:      rootops,
-		RootWrite:     outputOps,
-		OnlyObjStates: OnlyObjStates,
-		SavedObj:      *savedobj,
-	}
-	err := m.GenerateFromConfig()
+// finish turns the result of a command into an exit code.
+func finish(err error) int {
 	if err != nil {
-		log.Fatalf("generateMod(<config>) : %v", err)
+		log.Print(err)
+		return exitFailure
 	}
-	err = m.Print(basename)
-	if err != nil {
-		log.Fatalf("printMod(...) : %v", err)
+	return exitOK
+}
+
+func addModFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.moddir, "moddir", ".", "a directory containing tts mod configs and, optionally, a "+project.Filename)
+	fs.StringVar(&o.bonusdir, "bonusdir", "", "additional folder to check for Lua and XML require/include (replaces bonusDirs from "+project.Filename+")")
+	fs.Var(&o.libpaths, "libpath", "a directory to search for Lua and XML require/include, may be repeated (searched before libPaths from "+project.Filename+")")
+	fs.IntVar(&o.jobs, "jobs", runtime.NumCPU(), "how many objects to process concurrently")
+}
+
+// addCheckFlags adds the flags of every command that bundles Lua.
+func addCheckFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.skipLuaCheck, "skip-lua-check", false, "don't check the syntax of each bundled Lua script")
+	fs.BoolVar(&o.allowCycles, "allow-require-cycles", false, "accept Lua modules requiring each other in a circle when one of the requires is inside a function (overrides allowRequireCycles from "+project.Filename+")")
+}
+
+// addSkipXMLCheckFlag adds the flag of every command that checks XmlUI along
+// the way.
+func addSkipXMLCheckFlag(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.skipXMLCheck, "skip-xml-check", false, "don't warn about elements, attributes and values of XmlUI that TTS won't understand")
+}
+
+func addBuildFlags(fs *flag.FlagSet, o *options) {
+	addCheckFlags(fs, o)
+	addSkipXMLCheckFlag(fs, o)
+	fs.BoolVar(&o.minifyLua, "minify-lua", false, "strip comments and redundant whitespace from every bundled Lua module (overrides minifyLua from "+project.Filename+")")
+	fs.Var(&o.shared, "shared-module", "a Lua module (or path.Match pattern) to bundle once into Global for every script requiring it, may be repeated (added to sharedModules from "+project.Filename+")")
+	fs.BoolVar(&o.noCache, "no-cache", false, "ignore and overwrite the build cache, forcing every object to be rebuilt")
+	fs.StringVar(&o.reportBundles, "report-bundles", "", "write the modules bundled into each script and their sizes to this file, as JSON if it ends in .json and as text otherwise ('-' for standard output)")
+	fs.BoolVar(&o.watch, "watch", false, "after building, keep watching the sources and rebuild whenever a file changes")
+}
+
+func runBuild(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "Generate a mod json file from the config.json, objects/, src/, xml/ and modsettings/ of a mod directory.")
+	addModFlags(fs, &o)
+	addBuildFlags(fs, &o)
+	fs.StringVar(&o.modfile, "out", "", "where to write the mod json (default <moddir>/output.json)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := loadProject(&o, fs, "out"); err != nil {
+		return finish(err)
 	}
+	return finish(build(o))
 }
 
-// prepForReverse creates the expected subdirectories in config path
-func prepForReverse(cPath, modfile string) (types.J, error) {
-	subDirs := []string{luasrcSubdir, modsettingsDir, objectsSubdir, xmlsrcSubdir}
+func runReverse(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "--modfile <file> [flags]", "Split a mod json file into a mod directory. The objects/ directory is cleared first, unless --merge is given.")
+	addModFlags(fs, &o)
+	fs.StringVar(&o.modfile, "modfile", "", "the mod json file to read")
+	fs.BoolVar(&o.writeToSrc, "writesrc", false, "when unbundling Lua, save the included 'require' files to the src/ directory")
+	fs.BoolVar(&o.merge, "merge", false, "update the files of objects already in objects/ in place, matching them by GUID, instead of clearing it; files of removed objects are deleted and every change is listed")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if o.modfile == "" {
+		return usageError(fs, "--modfile is required")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	return finish(reverse(o))
+}
 
-	for _, s := range subDirs {
-		p := filepath.Join(cPath, s)
-		if _, err := os.Stat(p); err == nil {
-			// directory already exists
-		} else if os.IsNotExist(err) {
-			err = os.Mkdir(p, 0777)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, fmt.Errorf("undefined error checking for subdirectory %s : %v", s, err)
+func runMerge(name string, args []string) int {
+	o := options{}
+	var base, save, report string
+	fs := newFlagSet(name, "--base <file> --save <file> [flags]", "Merge the changes a TTS save made to the mod built as --base into the mod directory, object by object by GUID and field by field. Fields that the mod directory changed too keep its values and are reported as conflicts. Files are updated in place like reverse --merge.")
+	addModFlags(fs, &o)
+	fs.StringVar(&base, "base", "", "the mod json file built from the mod directory and loaded into TTS")
+	fs.StringVar(&save, "save", "", "the mod json file TTS saved after editing it")
+	fs.StringVar(&report, "report", "", "also write the changes applied and the conflicts as json to this file")
+	fs.BoolVar(&o.writeToSrc, "writesrc", false, "when unbundling Lua, save the included 'require' files to the src/ directory")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if base == "" || save == "" {
+		return usageError(fs, "--base and --save are both required")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	return finish(mergeSave(o, base, save, report, os.Stdout))
+}
+
+func runBuildObject(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "--objin <file> --objout <file> [flags]", "Generate a single downloadable object from an object json file and its contained objects.")
+	addModFlags(fs, &o)
+	addBuildFlags(fs, &o)
+	fs.StringVar(&o.objin, "objin", "", "the root object json file to build from")
+	fs.StringVar(&o.objout, "objout", "", "where to write the built object")
+	fs.BoolVar(&o.savedobj, "savedobj", false, "add the boiler plate for TTS to recognize the output as a saved object")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if o.objin == "" || o.objout == "" {
+		return usageError(fs, "--objin and --objout are both required")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	return finish(build(o))
+}
+
+func runReverseObject(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "--objin <file> --objout <dir>/ [flags]", "Split a single downloadable object into an object json file, contained objects and scripts.")
+	addModFlags(fs, &o)
+	fs.StringVar(&o.objin, "objin", "", "the downloadable object json file to read")
+	fs.StringVar(&o.objout, "objout", "", "the directory to write to, with a trailing slash")
+	fs.BoolVar(&o.writeToSrc, "writesrc", false, "when unbundling Lua, save the included 'require' files to the src/ directory")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if o.objin == "" || o.objout == "" {
+		return usageError(fs, "--objin and --objout are both required")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	return finish(reverse(o))
+}
+
+func runValidate(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "Build a mod in memory and report any errors, without writing output, then report GUIDs shared by more than one object and getObjectFromGUID calls whose GUID no object has.")
+	addModFlags(fs, &o)
+	addCheckFlags(fs, &o)
+	addSkipXMLCheckFlag(fs, &o)
+	fs.BoolVar(&o.skipGUIDCheck, "skip-guid-check", false, "don't report GUIDs shared by objects, or getObjectFromGUID calls whose GUID no object has")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	if err := validate(o, os.Stdout); err != nil {
+		return finish(err)
+	}
+	log.Printf("%s: no problems found", o.moddir)
+	return exitOK
+}
+
+func runCheckXML(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "Build a mod in memory and report every element, attribute and attribute value of its XmlUI that TTS won't understand, by the xml file and line it is on.")
+	addModFlags(fs, &o)
+	addCheckFlags(fs, &o)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	if err := checkXML(o, os.Stdout); err != nil {
+		return finish(err)
+	}
+	log.Printf("%s: no problems found in XmlUI", o.moddir)
+	return exitOK
+}
+
+func runAssets(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "List every asset URL of a mod's objects and settings with the GUIDs, files and fields using it, and the CustomUIAssets no XmlUI or script seems to refer to. With --rewrite, replace the URLs a mapping file maps instead, writing the changed source files back.")
+	addModFlags(fs, &o)
+	asJSON := fs.Bool("json", false, "list the assets as JSON")
+	rewrite := fs.String("rewrite", "", "a json file mapping URLs, or the start of URLs, to what replaces them, e.g. {\"http://old.host/\": \"https://new.host/\"}")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *asJSON && *rewrite != "" {
+		return usageError(fs, "--json only applies to listing assets, not to --rewrite")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	if *rewrite != "" {
+		return finish(rewriteAssets(o, *rewrite, os.Stdout))
+	}
+	return finish(listAssets(o, *asJSON, os.Stdout))
+}
+
+func runVerifyAssets(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "Build a mod in memory and look up the file of each of its asset URLs in a local mirror of TTS's Mods directory, named as TTS names what it downloads to Images, Models and so on. Every missing or empty file is reported with the GUID and field using its URL.")
+	addModFlags(fs, &o)
+	mirror := fs.String("mirror", "", "the mirror of TTS's Mods directory, holding Images, Models, ... (overrides assetMirror from "+project.Filename+")")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	if *mirror == "" {
+		*mirror = o.project.AssetMirror
+	}
+	if *mirror == "" {
+		return usageError(fs, "--mirror is required unless %s sets assetMirror", project.Filename)
+	}
+	return finish(verifyAssets(o, *mirror, *asJSON, os.Stdout))
+}
+
+func runCheckCalls(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags]", "Build a mod in memory and report every Global.call, obj.call and button click_function naming a function that the script it is called in doesn't define, by the source file and line of the call and the GUIDs of the scripts making it.")
+	addModFlags(fs, &o)
+	addCheckFlags(fs, &o)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	if err := checkCalls(o, os.Stdout); err != nil {
+		return finish(err)
+	}
+	log.Printf("%s: every function called by name is defined", o.moddir)
+	return exitOK
+}
+
+func runResolve(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags] <name>...", "Show which search path each Lua require (or, with --xml, each XML Include) is read from, and any later paths it shadows.")
+	addModFlags(fs, &o)
+	isXML := fs.Bool("xml", false, "resolve XML Include names instead of Lua requires")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
+		return exitUsage
 	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "at least one name is required")
+	}
+	if err := loadProject(&o, fs, ""); err != nil {
+		return finish(err)
+	}
+	return finish(resolveNames(o, fs.Args(), *isXML, os.Stdout))
+}
 
-	mFile, err := os.Open(modfile)
-	if err != nil {
-		return nil, fmt.Errorf("os.Open(%s) : %v", modfile, err)
+func runLocate(name string, args []string) int {
+	o := options{}
+	fs := newFlagSet(name, "[flags] <guid|Global> <line>", "Map a line of a built object's script (or Global's) back to the source file and line it came from, using the source maps written beside the build output. The line may be given as TTS reports it, e.g. 'chunk_3:(1204,5-20)'.")
+	fs.StringVar(&o.moddir, "moddir", ".", "a directory containing tts mod configs and, optionally, a "+project.Filename)
+	mapPath := fs.String("map", "", "the source map file to read (default: beside the project's output)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		return usageError(fs, "a guid (or Global) and a line are required")
+	}
+	line, col, ok := parseScriptPos(fs.Arg(1))
+	if !ok {
+		return usageError(fs, "can't read a line number from %q", fs.Arg(1))
 	}
+	if err := loadProject(&o, fs, "map"); err != nil {
+		return finish(err)
+	}
+	return finish(locate(o, *mapPath, fs.Arg(0), line, col, os.Stdout))
+}
 
-	defer mFile.Close()
+// runLegacy is the original interface, where a single flag set multiplexes
+// every mode. It is deprecated in favour of the subcommands.
+func runLegacy(args []string) int {
+	o := options{}
+	fs := flag.NewFlagSet(progName, flag.ContinueOnError)
+	fs.StringVar(&o.moddir, "moddir", "testdata/simple", "a directory containing tts mod configs")
+	fs.StringVar(&o.bonusdir, "bonusdir", "", "additional folder to check for Lua and XML require/include")
+	fs.Var(&o.libpaths, "libpath", "a directory to search for Lua and XML require/include, may be repeated.")
+	rev := fs.Bool("reverse", false, "instead of building a json from file structure, build file structure from json.")
+	fs.BoolVar(&o.writeToSrc, "writesrc", false, "when unbundling Lua, save the included 'require' files to the src/ directory.")
+	fs.StringVar(&o.modfile, "modfile", "", "where to read from when reversing.")
+	fs.StringVar(&o.objin, "objin", "", "if non-empty, don't build/reverse a full mod, only an object state array")
+	fs.StringVar(&o.objout, "objout", "", "if building only object state list, output to this filename")
+	fs.BoolVar(&o.savedobj, "savedobj", false, "if present, will add the boiler plate for TTS to recognize as saved object.")
+	fs.IntVar(&o.jobs, "jobs", runtime.NumCPU(), "how many objects to parse, bundle and write concurrently.")
+	fs.BoolVar(&o.noCache, "no-cache", false, "ignore and overwrite the build cache, forcing every object to be rebuilt.")
+	fs.BoolVar(&o.watch, "watch", false, "after building, keep watching the source directories and rebuild whenever a file changes.")
+	fs.BoolVar(&o.skipLuaCheck, "skip-lua-check", false, "don't check the syntax of each bundled Lua script.")
+	fs.BoolVar(&o.allowCycles, "allow-require-cycles", false, "accept Lua modules requiring each other in a circle when one of the requires is inside a function.")
+	fs.BoolVar(&o.skipXMLCheck, "skip-xml-check", false, "don't warn about elements, attributes and values of XmlUI that TTS won't understand.")
+	fs.BoolVar(&o.minifyLua, "minify-lua", false, "strip comments and redundant whitespace from every bundled Lua module.")
+	fs.Var(&o.shared, "shared-module", "a Lua module to bundle once into Global for every script requiring it, may be repeated.")
+	fs.StringVar(&o.reportBundles, "report-bundles", "", "write the modules bundled into each script and their sizes to this file (.json for JSON, '-' for standard output).")
+	fs.Usage = func() {
+		usage(fs.Output())
+		fmt.Fprintf(fs.Output(), "\nDeprecated flags, still accepted without a command:\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
 
-	b, err := io.ReadAll(mFile)
-	if err != nil {
-		return nil, err
+	if (o.objin == "") != (o.objout == "") {
+		return usageError(fs, "Must set either both or neither of {objin,objout}.")
 	}
-	var o types.J
-	err = json.Unmarshal(b, &o)
-	if err != nil {
-		return nil, err
+
+	log.Printf("Running without a command is deprecated; use '%s %s' instead.", progName, legacyEquivalent(*rev, o.objin != ""))
+	outputFlag := "modfile"
+	if *rev {
+		outputFlag = ""
+	}
+	if err := loadProject(&o, fs, outputFlag); err != nil {
+		return finish(err)
+	}
+	if *rev {
+		return finish(reverse(o))
 	}
+	return finish(build(o))
+}
 
-	return o, nil
+// legacyEquivalent names the subcommand that replaces a legacy invocation.
+func legacyEquivalent(rev, object bool) string {
+	switch {
+	case rev && object:
+		return "reverse-object"
+	case rev:
+		return "reverse"
+	case object:
+		return "build-object"
+	}
+	return "build"
 }
@@ -0,0 +1,122 @@
+package mod
+
+import (
+	"ModCreator/assets"
+	"ModCreator/file"
+	"fmt"
+)
+
+// settingsFile is config.json or a modsettings file it points to, as read.
+type settingsFile struct {
+	// path is how the file is named to its reader, and name how it is shown
+	path, name string
+	// key is the config.json key the file is read into, empty for config.json
+	key string
+	v   interface{}
+}
+
+// readSettingsFiles reads config.json and every modsettings file it points to
+// for a json value.
+func (m *Mod) readSettingsFiles() ([]settingsFile, error) {
+	raw, err := m.RootRead.ReadObj("config.json")
+	if err != nil {
+		return nil, fmt.Errorf("RootRead.ReadObj(%s): %v", "config.json", err)
+	}
+	files := []settingsFile{{path: "config.json", name: resolved(m.RootRead, "config.json"), v: raw}}
+	add := func(key string, read func(string) (interface{}, error)) error {
+		p, ok := raw[key+"_path"].(string)
+		if !ok || p == "" {
+			return nil
+		}
+		v, err := read(p)
+		if err != nil {
+			return fmt.Errorf("could not resolve %q for key %q: %v", p, key, err)
+		}
+		files = append(files, settingsFile{path: p, name: resolved(m.Modsettings, p), key: key, v: v})
+		return nil
+	}
+	for _, key := range ExpectedObj {
+		if err := add(key, func(p string) (interface{}, error) { return m.Modsettings.ReadObj(p) }); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range ExpectedObjArr {
+		if err := add(key, func(p string) (interface{}, error) { return m.Modsettings.ReadObjArray(p) }); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func resolved(r interface{}, name string) string {
+	if pr, ok := r.(file.PathResolver); ok {
+		if full, err := pr.Resolve(name); err == nil {
+			return full
+		}
+	}
+	return name
+}
+
+// Assets lists the asset URLs of the mod: those of every object, and of its
+// own settings in config.json and the modsettings files it points to, like
+// CustomUIAssets.json and Decals.json.
+func (m *Mod) Assets() (assets.Inventory, error) {
+	inv, err := m.objectParser().Assets()
+	if err != nil {
+		return nil, fmt.Errorf("objects.Assets(): %v", err)
+	}
+	files, err := m.readSettingsFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		assets.Walk(f.v, f.key, func(field, url string) (string, bool) {
+			inv.Add(url, assets.Use{File: f.name, Field: field})
+			return url, false
+		})
+	}
+	return inv, nil
+}
+
+// RewriteAssets replaces every asset URL of the mod that rewrite maps to
+// another, writing config.json back through root, the modsettings files
+// through settings and the object files through objs. It returns how many
+// URLs changed in each file.
+func (m *Mod) RewriteAssets(root, settings, objs file.JSONWriter, rewrite func(url string) (string, bool)) (map[string]int, error) {
+	changed, err := m.objectParser().RewriteAssets(objs, rewrite)
+	if err != nil {
+		return changed, fmt.Errorf("objects.RewriteAssets(): %v", err)
+	}
+	files, err := m.readSettingsFiles()
+	if err != nil {
+		return changed, err
+	}
+	for _, f := range files {
+		n := 0
+		assets.Walk(f.v, f.key, func(field, url string) (string, bool) {
+			to, ok := rewrite(url)
+			if ok && to != url {
+				n++
+			}
+			return to, ok
+		})
+		if n == 0 {
+			continue
+		}
+		switch v := f.v.(type) {
+		case map[string]interface{}:
+			w := settings
+			if f.key == "" {
+				w = root
+			}
+			err = w.WriteObj(v, f.path)
+		case []map[string]interface{}:
+			err = settings.WriteObjArray(v, f.path)
+		}
+		if err != nil {
+			return changed, fmt.Errorf("writing %s: %v", f.path, err)
+		}
+		changed[f.name] = n
+	}
+	return changed, nil
+}
@@ -0,0 +1,87 @@
+package mod
+
+import (
+	"ModCreator/assets"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func assetsMod() *Mod {
+	root, settings, objs := tests.NewFF(), tests.NewFF(), tests.NewFF()
+	root.Data["config.json"] = types.J{
+		"SkyURL":              "http://old.host/sky.png",
+		"Grid_path":           "Grid.json",
+		"CustomUIAssets_path": "CustomUIAssets.json",
+	}
+	settings.Data["Grid.json"] = types.J{"Lines": true}
+	settings.Data["CustomUIAssets.json"] = types.J{
+		"testarray": []map[string]interface{}{ // implementation detail of fake files
+			{"Name": "logo", "URL": "http://old.host/logo.png"},
+		},
+	}
+	objs.Data["Die.111111.json"] = types.J{
+		"GUID":       "111111",
+		"CustomMesh": map[string]interface{}{"MeshURL": "http://other.host/die.obj"},
+	}
+	return &Mod{RootRead: root, Modsettings: settings, Objs: objs, Objdirs: objs}
+}
+
+func TestAssets(t *testing.T) {
+	m := assetsMod()
+	got, err := m.Assets()
+	if err != nil {
+		t.Fatalf("Assets(): %v", err)
+	}
+	want := assets.Inventory{
+		"http://old.host/sky.png":   {{File: "config.json", Field: "SkyURL"}},
+		"http://old.host/logo.png":  {{File: "CustomUIAssets.json", Field: "CustomUIAssets.0.URL"}},
+		"http://other.host/die.obj": {{GUID: "111111", File: "Die.111111.json", Field: "CustomMesh.MeshURL"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestRewriteAssets(t *testing.T) {
+	m := assetsMod()
+	root, settings, objs := tests.NewFF(), tests.NewFF(), tests.NewFF()
+	got, err := m.RewriteAssets(root, settings, objs, func(url string) (string, bool) {
+		if strings.HasPrefix(url, "http://old.host/") {
+			return strings.Replace(url, "http://old.host/", "https://new.host/", 1), true
+		}
+		return url, false
+	})
+	if err != nil {
+		t.Fatalf("RewriteAssets(): %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"config.json": 1, "CustomUIAssets.json": 1}, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantRoot := map[string]types.J{
+		"config.json": {
+			"SkyURL":              "https://new.host/sky.png",
+			"Grid_path":           "Grid.json",
+			"CustomUIAssets_path": "CustomUIAssets.json",
+		},
+	}
+	if diff := cmp.Diff(wantRoot, root.Data); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantSettings := map[string]types.J{
+		"CustomUIAssets.json": {
+			"testarray": []map[string]interface{}{
+				{"Name": "logo", "URL": "https://new.host/logo.png"},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantSettings, settings.Data); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if len(objs.Data) != 0 {
+		t.Errorf("objects written although unchanged: %v", objs.Data)
+	}
+}
@@ -0,0 +1,174 @@
+package mod
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/lua"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CallProblem is a function called by name, through call or as a button's
+// click_function, that the script it is called in doesn't define.
+type CallProblem struct {
+	// File and Line are where the call is. Inline is set when File is the
+	// json file holding the script.
+	File   string
+	Inline bool
+	Line   int
+	Msg    string
+	// Owners are the GUIDs, or Global, of the scripts making the call, more
+	// than one when it is in a module they all bundle.
+	Owners []string
+}
+
+func (p CallProblem) String() string {
+	where := p.File
+	if p.Inline {
+		where += " (inline LuaScript)"
+	}
+	return fmt.Sprintf("%s:%d: %s (in the script of %s)", where, p.Line, p.Msg, strings.Join(p.Owners, ", "))
+}
+
+// scriptUnit is a piece of Lua run by an object: its own script, or a shared
+// module it fetches from Global. sm locates its lines, offset by offset.
+type scriptUnit struct {
+	src    string
+	sm     *bundler.SourceMap
+	offset int
+}
+
+// CheckCalls reports every call to Global.call, obj.call and every button
+// click_function, named by a string literal in a script of the built mod,
+// whose function isn't defined by the script it is called in. When the
+// script can't be worked out, the function must be defined by at least one
+// script. It is called once GenerateFromConfig has filled in Data; calls are
+// found in their source files when SourceMaps was set for it.
+func (m *Mod) CheckCalls() ([]CallProblem, error) {
+	global, _ := m.Data["LuaScript"].(string)
+	shared := map[string]bundler.SharedSource{}
+	srcs, err := bundler.SharedSources(global)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range srcs {
+		shared[s.Name] = s
+	}
+	globalMap, _ := m.sourceMap(bundler.GlobalScript)
+
+	owners := []string{}
+	units := map[string][]scriptUnit{}
+	nicknames := map[string]string{}
+	err = bundler.WalkScripts(m.Data, func(owner, nickname, script string) error {
+		if _, ok := units[owner]; !ok {
+			owners = append(owners, owner)
+			units[owner] = []scriptUnit{}
+		}
+		if nickname != "" {
+			nicknames[owner] = nickname
+		}
+		if script == "" {
+			return nil
+		}
+		sm, _ := m.sourceMap(owner)
+		units[owner] = append(units[owner], scriptUnit{src: script, sm: sm})
+		for _, name := range bundler.SharedStubs(script) {
+			if s, ok := shared[name]; ok {
+				units[owner] = append(units[owner], scriptUnit{src: s.Source, sm: globalMap, offset: s.Line - 1})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defs := map[string]map[string]bool{}
+	anyDefs := map[string]bool{}
+	for _, owner := range owners {
+		defs[owner] = map[string]bool{}
+		for _, u := range units[owner] {
+			found, err := lua.FindGlobalFunctions(u.src)
+			if err != nil {
+				// not Lua: the syntax check reports it
+				continue
+			}
+			for f := range found {
+				defs[owner][f] = true
+				anyDefs[f] = true
+			}
+		}
+	}
+
+	problems := []*CallProblem{}
+	byPlace := map[string]*CallProblem{}
+	for _, owner := range owners {
+		for _, u := range units[owner] {
+			calls, err := lua.FindNamedCalls(u.src)
+			if err != nil {
+				continue
+			}
+			for _, c := range calls {
+				in, where := owner, ""
+				switch c.Target.Kind {
+				case lua.Self:
+					where = "its own script"
+				case lua.Global:
+					in, where = bundler.GlobalScript, "Global's script"
+				case lua.GUID:
+					if _, ok := defs[c.Target.GUID]; !ok {
+						// no object has the GUID, which validate reports
+						continue
+					}
+					in, where = c.Target.GUID, "the script of "+c.Target.GUID
+					if n := nicknames[in]; n != "" {
+						where += " (" + n + ")"
+					}
+				default:
+					if anyDefs[c.Func] {
+						continue
+					}
+					in, where = "", "any script"
+				}
+				if in != "" && defs[in][c.Func] {
+					continue
+				}
+				what := fmt.Sprintf("call(%q)", c.Func)
+				if c.Button {
+					what = fmt.Sprintf("click_function %q", c.Func)
+				}
+				p := CallProblem{
+					File: "LuaScript of " + owner,
+					Line: c.Pos.Line + u.offset,
+					Msg:  fmt.Sprintf("%s: no function %s in %s", what, c.Func, where),
+				}
+				if u.sm != nil {
+					if seg, line, ok := u.sm.Locate(p.Line); ok {
+						p.File, p.Inline, p.Line = seg.File, seg.Inline, line
+					}
+				}
+				key := fmt.Sprintf("%s:%v:%d:%s", p.File, p.Inline, p.Line, p.Msg)
+				if prev, ok := byPlace[key]; ok {
+					if prev.Owners[len(prev.Owners)-1] != owner {
+						prev.Owners = append(prev.Owners, owner)
+					}
+					continue
+				}
+				p.Owners = []string{owner}
+				byPlace[key] = &p
+				problems = append(problems, &p)
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	out := make([]CallProblem, len(problems))
+	for i, p := range problems {
+		out[i] = *p
+	}
+	return out, nil
+}
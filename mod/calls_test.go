@@ -0,0 +1,59 @@
+package mod
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckCalls(t *testing.T) {
+	root := tests.NewFF()
+	root.Data["config.json"] = types.J{
+		"LuaScript_path":     "Global.ttslua",
+		"ObjectStates_order": []string{"Board.111111", "Bag.222222"},
+	}
+	lua := tests.NewFF()
+	lua.Fs["Global.ttslua"] = "function setup() end\ngetObjectFromGUID(\"111111\").call(\"deal\")\ngetObjectFromGUID(\"999999\").call(\"deal\")"
+	lua.Fs["lib/buttons.ttslua"] = "function addButton()\n  self.createButton({click_function = \"onPress\", function_owner = self})\nend"
+	lua.Fs["board.ttslua"] = "require(\"lib/buttons\")\nfunction onPress() end\nfunction deal() end\nGlobal.call(\"setup\")\nGlobal.call(\"setpu\")"
+	lua.Fs["bag.ttslua"] = "require(\"lib/buttons\")\nobjs[1].call(\"deal\")\nobjs[1].call(\"nowhere\")"
+	objs := tests.NewFF()
+	objs.Data["Board.111111.json"] = types.J{
+		"GUID":           "111111",
+		"Nickname":       "Board",
+		"LuaScript_path": "board.ttslua",
+	}
+	objs.Data["Bag.222222.json"] = types.J{
+		"GUID":           "222222",
+		"Nickname":       "Bag",
+		"LuaScript_path": "bag.ttslua",
+	}
+	m := Mod{
+		RootRead:    root,
+		Lua:         lua,
+		XML:         lua,
+		Modsettings: tests.NewFF(),
+		Objs:        objs,
+		Objdirs:     objs,
+		SourceMaps:  bundler.NewSourceMaps(),
+	}
+	if err := m.GenerateFromConfig(); err != nil {
+		t.Fatalf("GenerateFromConfig(): %v", err)
+	}
+	got, err := m.CheckCalls()
+	if err != nil {
+		t.Fatalf("CheckCalls(): %v", err)
+	}
+	want := []CallProblem{
+		{File: "bag.ttslua", Line: 3, Msg: `call("nowhere"): no function nowhere in any script`, Owners: []string{"222222"}},
+		{File: "board.ttslua", Line: 5, Msg: `call("setpu"): no function setpu in Global's script`, Owners: []string{"111111"}},
+		// the board defines onPress, the bag doesn't
+		{File: "lib/buttons.ttslua", Line: 2, Msg: `click_function "onPress": no function onPress in its own script`, Owners: []string{"222222"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -1,10 +1,13 @@
 package mod
 
 import (
+	"ModCreator/bundler"
 	"ModCreator/file"
 	"ModCreator/handler"
+	"ModCreator/lua"
 	"ModCreator/objects"
 	"ModCreator/types"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -39,6 +42,18 @@ type Mod struct {
 	Objdirs     file.DirExplorer
 	SavedObj    bool
 
+	// If set: root objects unchanged since the previous build are reused
+	Cache *objects.BuildCache
+	// How many objects may be parsed and printed concurrently
+	Jobs int
+	// LuaOptions apply to Global's and every object's LuaScript
+	LuaOptions handler.LuaOptions
+	// XMLOptions apply to Global's and every object's XmlUI
+	XMLOptions handler.XMLOptions
+	// If set: receives the source map of Global's and every object's
+	// LuaScript
+	SourceMaps *bundler.SourceMaps
+
 	// If not-empty: this holds the root filename for the object state json object
 	OnlyObjStates string
 }
@@ -57,7 +72,7 @@ func (m *Mod) GenerateFromConfig() error {
 
 func (m *Mod) generateOnlyObjStates() error {
 	nameAndGuid := strings.TrimSuffix(m.OnlyObjStates, ".json")
-	allObjs, err := objects.ParseAllObjectStates(m.Lua, m.XML, m.Objs, m.Objdirs, []string{nameAndGuid})
+	allObjs, err := m.objectParser().ParseAllObjectStates([]string{nameAndGuid})
 	if err != nil {
 		return fmt.Errorf("objects.ParseAllObjectStates(%s) : %v", m.OnlyObjStates, err)
 	}
@@ -70,8 +85,26 @@ func (m *Mod) generateOnlyObjStates() error {
 	return nil
 }
 
+func (m *Mod) objectParser() *objects.Parser {
+	return &objects.Parser{
+		Lua:        m.Lua,
+		XML:        m.XML,
+		J:          m.Objs,
+		Dir:        m.Objdirs,
+		Cache:      m.Cache,
+		Jobs:       m.Jobs,
+		LuaOptions: m.LuaOptions,
+		XMLOptions: m.XMLOptions,
+		SourceMaps: m.SourceMaps,
+	}
+}
+
 func (m *Mod) generate(raw types.J) error {
 	m.Data = raw
+	// Global's script is read in below along with the other string fields, so
+	// remember where it came from for errors and source maps
+	scriptFile, fromFile := m.globalFile("LuaScript")
+	xmlFile, xmlFromFile := m.globalFile("XmlUI")
 
 	plainObj := func(s string) (interface{}, error) {
 		return m.Modsettings.ReadObj(s)
@@ -104,11 +137,31 @@ func (m *Mod) generate(raw types.J) error {
 
 	lh := handler.NewLuaHandler()
 	lh.Reader = m.Lua
+	lh.Lua = m.LuaOptions
+	lh.SourceMap = m.SourceMaps != nil
 
 	act, err := lh.WhileReadingFromFile(m.Data)
 	if err != nil {
+		var se *handler.ScriptError
+		if errors.As(err, &se) && se.Inline {
+			se.File, se.Inline = scriptFile, !fromFile
+		}
+		var ce *bundler.CycleError
+		if errors.As(err, &ce) {
+			ce.Owner = bundler.GlobalScript
+		}
 		return fmt.Errorf("WhileReadingFromFile(): %v", err)
 	}
+	// Global's map is added once the shared modules, if any, are in place
+	globalMap := act.SourceMap
+	if globalMap != nil {
+		for i, seg := range globalMap.Segments {
+			if seg.Inline {
+				globalMap.Segments[i].File, globalMap.Segments[i].Inline = scriptFile, !fromFile
+			}
+		}
+	}
+	handler.LogMinified(bundler.GlobalScript, act)
 	if !act.Noop {
 		delete(m.Data, "LuaScript")
 		delete(m.Data, "LuaScript_path")
@@ -117,11 +170,22 @@ func (m *Mod) generate(raw types.J) error {
 
 	xh := handler.NewXMLHandler()
 	xh.Reader = m.XML
+	xh.XML = m.XMLOptions
 
 	act, err = xh.WhileReadingFromFile(m.Data)
 	if err != nil {
+		var ce *bundler.CycleError
+		if errors.As(err, &ce) {
+			ce.Owner = bundler.GlobalScript
+		}
 		return fmt.Errorf("WhileReadingFromFile(): %v", err)
 	}
+	for i, w := range act.Warnings {
+		if w.Inline {
+			act.Warnings[i].File, act.Warnings[i].Inline = xmlFile, !xmlFromFile
+		}
+	}
+	m.XMLOptions.Report(act.Warnings)
 	if !act.Noop {
 		delete(m.Data, "XmlUI")
 		delete(m.Data, "XmlUI_path")
@@ -140,7 +204,7 @@ func (m *Mod) generate(raw types.J) error {
 		return fmt.Errorf("Has Objects, but can't discern their order: %v", err)
 	}
 
-	allObjs, err := objects.ParseAllObjectStates(m.Lua, m.XML, m.Objs, m.Objdirs, objOrder)
+	allObjs, err := m.objectParser().ParseAllObjectStates(objOrder)
 	if err != nil {
 		return fmt.Errorf("objects.ParseAllObjectStates(%s) : %v", "", err)
 	}
@@ -149,6 +213,15 @@ func (m *Mod) generate(raw types.J) error {
 	}
 	m.Data[ExpectedObjStates] = allObjs
 
+	if len(m.LuaOptions.Shared) > 0 {
+		if globalMap, err = m.addSharedModules(globalMap); err != nil {
+			return err
+		}
+	}
+	if m.SourceMaps != nil && globalMap != nil {
+		m.SourceMaps.Add(bundler.GlobalScript, globalMap)
+	}
+
 	now := time.Now()
 	m.Data[DateKey] = fmt.Sprint(now.Format(time.UnixDate))
 	m.Data[EpochKey] = now.Unix()
@@ -165,6 +238,68 @@ func (m *Mod) Print(basename string) error {
 	}
 }
 
+// addSharedModules bundles every shared module fetched by a script of the mod
+// into Global's script. globalMap is the source map of Global's script, which
+// is returned updated.
+func (m *Mod) addSharedModules(globalMap *bundler.SourceMap) (*bundler.SourceMap, error) {
+	names, err := bundler.SharedModulesUsed(m.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return globalMap, nil
+	}
+	mods := []bundler.SharedModule{}
+	for _, name := range names {
+		fname := name + ".ttslua"
+		src, err := m.Lua.EncodeFromFile(fname)
+		if err != nil {
+			return nil, fmt.Errorf("EncodeFromFile(%s) : %v", fname, err)
+		}
+		if src, err = bundler.ExpandIncludes(src, name, m.Lua); err != nil {
+			return nil, fmt.Errorf("ExpandIncludes(%s): %v", fname, err)
+		}
+		if r, ok := m.Lua.(file.PathResolver); ok {
+			if full, err := r.Resolve(fname); err == nil {
+				fname = full
+			}
+		}
+		if !m.LuaOptions.SkipCheck {
+			var se *lua.SyntaxError
+			if errors.As(lua.Check(src), &se) {
+				return nil, &handler.ScriptError{File: fname, Line: se.Line, Col: se.Col, Msg: se.Msg}
+			}
+		}
+		if m.LuaOptions.Minify {
+			if src, err = lua.Minify(src); err != nil {
+				return nil, fmt.Errorf("Minify(%s): %v", fname, err)
+			}
+		}
+		mods = append(mods, bundler.SharedModule{Name: name, Source: src, File: fname})
+	}
+	global, _ := m.Data["LuaScript"].(string)
+	global, globalMap = bundler.AddSharedModules(global, mods, globalMap)
+	delete(m.Data, "LuaScript_path")
+	m.Data["LuaScript"] = global
+	return globalMap, nil
+}
+
+// globalFile names the file Global's key, LuaScript or XmlUI, is read from,
+// and whether it is a file of its own rather than a field of config.json.
+func (m *Mod) globalFile(key string) (string, bool) {
+	name, fromFile := "config.json", false
+	var r interface{} = m.RootRead
+	if p, ok := m.Data[key+"_path"].(string); ok && p != "" {
+		name, fromFile, r = p, true, m.Lua
+	}
+	if pr, ok := r.(file.PathResolver); ok {
+		if full, err := pr.Resolve(name); err == nil {
+			return full, fromFile
+		}
+	}
+	return name, fromFile
+}
+
 func tryPut(d *types.J, from, to string, fun func(string) (interface{}, error)) error {
 	if d == nil {
 		log.Println("Nil objects")
@@ -1,6 +1,8 @@
 package mod
 
 import (
+	"ModCreator/bundler"
+	"ModCreator/handler"
 	"ModCreator/tests"
 	"ModCreator/types"
 	"strings"
@@ -27,10 +29,10 @@ func TestGenerate(t *testing.T) {
 				"LuaScript": "require(\"core/Global\")",
 			},
 			inputLuaSrc: map[string]string{
-				"core/Global.ttslua": "var a = 42",
+				"core/Global.ttslua": "var_a = 42",
 			},
 			want: map[string]interface{}{
-				"LuaScript":      "-- Bundled by luabundle {\"version\":\"1.6.0\"}\nlocal __bundle_require, __bundle_loaded, __bundle_register, __bundle_modules = (function(superRequire)\n\tlocal loadingPlaceholder = {[{}] = true}\n\n\tlocal register\n\tlocal modules = {}\n\n\tlocal require\n\tlocal loaded = {}\n\n\tregister = function(name, body)\n\t\tif not modules[name] then\n\t\t\tmodules[name] = body\n\t\tend\n\tend\n\n\trequire = function(name)\n\t\tlocal loadedModule = loaded[name]\n\n\t\tif loadedModule then\n\t\t\tif loadedModule == loadingPlaceholder then\n\t\t\t\treturn nil\n\t\t\tend\n\t\telse\n\t\t\tif not modules[name] then\n\t\t\t\tif not superRequire then\n\t\t\t\t\tlocal identifier = type(name) == 'string' and '\\\"' .. name .. '\\\"' or tostring(name)\n\t\t\t\t\terror('Tried to require ' .. identifier .. ', but no such module has been registered')\n\t\t\t\telse\n\t\t\t\t\treturn superRequire(name)\n\t\t\t\tend\n\t\t\tend\n\n\t\t\tloaded[name] = loadingPlaceholder\n\t\t\tloadedModule = modules[name](require, loaded, register, modules)\n\t\t\tloaded[name] = loadedModule\n\t\tend\n\n\t\treturn loadedModule\n\tend\n\n\treturn require, loaded, register, modules\nend)(nil)\n__bundle_register(\"__root\", function(require, _LOADED, __bundle_register, __bundle_modules)\nrequire(\"core/Global\")\nend)\n__bundle_register(\"core/Global\", function(require, _LOADED, __bundle_register, __bundle_modules)\nvar a = 42\nend)\nreturn __bundle_require(\"__root\")",
+				"LuaScript":      "-- Bundled by luabundle {\"version\":\"1.6.0\"}\nlocal __bundle_require, __bundle_loaded, __bundle_register, __bundle_modules = (function(superRequire)\n\tlocal loadingPlaceholder = {[{}] = true}\n\n\tlocal register\n\tlocal modules = {}\n\n\tlocal require\n\tlocal loaded = {}\n\n\tregister = function(name, body)\n\t\tif not modules[name] then\n\t\t\tmodules[name] = body\n\t\tend\n\tend\n\n\trequire = function(name)\n\t\tlocal loadedModule = loaded[name]\n\n\t\tif loadedModule then\n\t\t\tif loadedModule == loadingPlaceholder then\n\t\t\t\treturn nil\n\t\t\tend\n\t\telse\n\t\t\tif not modules[name] then\n\t\t\t\tif not superRequire then\n\t\t\t\t\tlocal identifier = type(name) == 'string' and '\\\"' .. name .. '\\\"' or tostring(name)\n\t\t\t\t\terror('Tried to require ' .. identifier .. ', but no such module has been registered')\n\t\t\t\telse\n\t\t\t\t\treturn superRequire(name)\n\t\t\t\tend\n\t\t\tend\n\n\t\t\tloaded[name] = loadingPlaceholder\n\t\t\tloadedModule = modules[name](require, loaded, register, modules)\n\t\t\tloaded[name] = loadedModule\n\t\tend\n\n\t\treturn loadedModule\n\tend\n\n\treturn require, loaded, register, modules\nend)(nil)\n__bundle_register(\"__root\", function(require, _LOADED, __bundle_register, __bundle_modules)\nrequire(\"core/Global\")\nend)\n__bundle_register(\"core/Global\", function(require, _LOADED, __bundle_register, __bundle_modules)\nvar_a = 42\nend)\nreturn __bundle_require(\"__root\")",
 				"CameraStates":   nil,
 				"ComponentTags":  map[string]interface{}{},
 				"MusicPlayer":    map[string]interface{}{},
@@ -63,7 +65,7 @@ func TestGenerate(t *testing.T) {
 				"ObjectStates_order": []interface{}{"parent"},
 			},
 			inputLuaSrc: map[string]string{
-				"parent/eda22b/childstate2.ttslua": "var foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\n",
+				"parent/eda22b/childstate2.ttslua": "var_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\n",
 			},
 			inputObjs: map[string]types.J{
 				"parent.json": map[string]interface{}{
@@ -98,7 +100,7 @@ func TestGenerate(t *testing.T) {
 									map[string]any{
 										"Description": "child of state 2",
 										"GUID":        "childstate2",
-										"LuaScript":   "var foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\nvar foo = 42\n",
+										"LuaScript":   "var_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\nvar_foo = 42\n",
 									},
 								},
 							},
@@ -348,3 +350,42 @@ func TestGenerateAbsentOptionalKey(t *testing.T) {
 		t.Errorf("absent optional key LuaScriptState should be zero-valued, got %v (present=%v)", got, ok)
 	}
 }
+
+// TestGenerateSharedModules covers shared modules being bundled once into
+// Global's script, with Global's own bundle fetching them like any object.
+func TestGenerateSharedModules(t *testing.T) {
+	rootff := &tests.FakeFiles{
+		Data: map[string]types.J{
+			"config.json": map[string]interface{}{
+				"LuaScript": "require('lib/util')",
+			},
+		},
+	}
+	m := Mod{
+		RootRead:    rootff,
+		RootWrite:   rootff,
+		Lua:         &tests.FakeFiles{Fs: map[string]string{"lib/util.ttslua": "function util() end"}},
+		Modsettings: &tests.FakeFiles{},
+		Objs:        &tests.FakeFiles{},
+		Objdirs:     &tests.FakeFiles{},
+		LuaOptions:  handler.LuaOptions{Shared: []string{"lib/util"}},
+	}
+	if err := m.GenerateFromConfig(); err != nil {
+		t.Fatalf("GenerateFromConfig(): %v", err)
+	}
+	global, _ := m.Data["LuaScript"].(string)
+	rest, shared, err := bundler.SplitShared(global)
+	if err != nil {
+		t.Fatalf("SplitShared(): %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{"lib/util": "function util() end"}, shared); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	modules, _, err := bundler.UnbundleAll(rest)
+	if err != nil {
+		t.Fatalf("UnbundleAll(): %v", err)
+	}
+	if _, ok := bundler.IsSharedStub(modules["lib/util"]); !ok {
+		t.Errorf("Global's own bundle should fetch lib/util like any script, got %q", modules["lib/util"])
+	}
+}
@@ -0,0 +1,107 @@
+package mod
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/lua"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GUIDProblem is a GUID shared by more than one object, which TTS replaces on
+// all but one of them when loading, or one a script looks up that no object
+// has.
+type GUIDProblem struct {
+	// File and Line are where a script looks the GUID up, empty for a shared
+	// GUID. Inline is set when File is the json file holding the script.
+	File   string
+	Inline bool
+	Line   int
+	Msg    string
+}
+
+func (p GUIDProblem) String() string {
+	if p.File == "" {
+		return p.Msg
+	}
+	where := p.File
+	if p.Inline {
+		where += " (inline LuaScript)"
+	}
+	return fmt.Sprintf("%s:%d: %s", where, p.Line, p.Msg)
+}
+
+// CheckGUIDs reports every GUID used by more than one object, with the files
+// they are read from, and every getObjectFromGUID of a string literal in a
+// script of the built mod whose GUID no object has. It is called once
+// GenerateFromConfig has filled in Data; lookups are found in their source
+// files when SourceMaps was set for it.
+func (m *Mod) CheckGUIDs() ([]GUIDProblem, error) {
+	guids, err := m.objectParser().GUIDs()
+	if err != nil {
+		return nil, fmt.Errorf("objects.GUIDs(): %v", err)
+	}
+	problems := []GUIDProblem{}
+	uses := map[string][]string{}
+	order := []string{}
+	for _, g := range guids {
+		if _, ok := uses[g.GUID]; !ok {
+			order = append(order, g.GUID)
+		}
+		uses[g.GUID] = append(uses[g.GUID], fmt.Sprintf("%s (%s)", g.File, g.Name))
+	}
+	sort.Strings(order)
+	for _, g := range order {
+		if len(uses[g]) > 1 {
+			problems = append(problems, GUIDProblem{Msg: fmt.Sprintf("GUID %s is used by %d objects: %s", g, len(uses[g]), strings.Join(uses[g], ", "))})
+		}
+	}
+
+	lookups := []GUIDProblem{}
+	seen := map[GUIDProblem]bool{}
+	err = bundler.WalkScripts(m.Data, func(owner, _, script string) error {
+		if script == "" {
+			return nil
+		}
+		found, err := lua.FindGUIDLookups(script)
+		if err != nil {
+			// not Lua: the syntax check reports it
+			return nil
+		}
+		for _, l := range found {
+			// -1 is Global's
+			if _, ok := uses[l.GUID]; ok || l.GUID == "-1" {
+				continue
+			}
+			p := GUIDProblem{File: "LuaScript of " + owner, Line: l.Pos.Line, Msg: fmt.Sprintf("getObjectFromGUID(%q): no object has GUID %s", l.GUID, l.GUID)}
+			if sm, ok := m.sourceMap(owner); ok {
+				if seg, line, ok := sm.Locate(l.Pos.Line); ok {
+					p.File, p.Inline, p.Line = seg.File, seg.Inline, line
+				}
+			}
+			// a module bundled into many scripts is reported once
+			if !seen[p] {
+				seen[p] = true
+				lookups = append(lookups, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lookups, func(i, j int) bool {
+		if lookups[i].File != lookups[j].File {
+			return lookups[i].File < lookups[j].File
+		}
+		return lookups[i].Line < lookups[j].Line
+	})
+	return append(problems, lookups...), nil
+}
+
+func (m *Mod) sourceMap(owner string) (*bundler.SourceMap, bool) {
+	if m.SourceMaps == nil {
+		return nil, false
+	}
+	return m.SourceMaps.Get(owner)
+}
@@ -0,0 +1,65 @@
+package mod
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckGUIDs(t *testing.T) {
+	root := tests.NewFF()
+	root.Data["config.json"] = types.J{
+		"LuaScript_path":     "Global.ttslua",
+		"ObjectStates_order": []string{"Bag.111111", "Die.111111"},
+	}
+	lua := tests.NewFF()
+	lua.Fs["Global.ttslua"] = "require(\"lib/find\")\nlocal self = getObjectFromGUID(\"-1\")\nlocal b = getObjectFromGUID(\"111111\")"
+	lua.Fs["lib/find.ttslua"] = "function find()\n  return getObjectFromGUID(\"999999\")\nend"
+	lua.Fs["bag.ttslua"] = "require(\"lib/find\")\ngetObjectFromGUID('222222')"
+	objs := tests.NewFF()
+	objs.Data["Bag.111111.json"] = types.J{
+		"GUID":                   "111111",
+		"Nickname":               "Bag",
+		"LuaScript_path":         "bag.ttslua",
+		"ContainedObjects_path":  "Bag.111111",
+		"ContainedObjects_order": []string{"Card.222222"},
+	}
+	objs.Data["Bag.111111/Card.222222.json"] = types.J{
+		"GUID":      "222222",
+		"Name":      "Card",
+		"LuaScript": "x = 1\ngetObjectFromGUID(\"888888\")",
+	}
+	objs.Data["Die.111111.json"] = types.J{
+		"GUID":     "111111",
+		"Name":     "Die_6",
+		"Nickname": "Die",
+	}
+	m := Mod{
+		RootRead:    root,
+		Lua:         lua,
+		XML:         lua,
+		Modsettings: tests.NewFF(),
+		Objs:        objs,
+		Objdirs:     objs,
+		SourceMaps:  bundler.NewSourceMaps(),
+	}
+	if err := m.GenerateFromConfig(); err != nil {
+		t.Fatalf("GenerateFromConfig(): %v", err)
+	}
+	got, err := m.CheckGUIDs()
+	if err != nil {
+		t.Fatalf("CheckGUIDs(): %v", err)
+	}
+	want := []GUIDProblem{
+		{Msg: "GUID 111111 is used by 2 objects: Bag.111111.json (Bag), Die.111111.json (Die)"},
+		{File: "Bag.111111/Card.222222.json", Inline: true, Line: 2, Msg: `getObjectFromGUID("888888"): no object has GUID 888888`},
+		// once, although both Global and the bag bundle it
+		{File: "lib/find.ttslua", Line: 2, Msg: `getObjectFromGUID("999999"): no object has GUID 999999`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,78 @@
+package mod
+
+import (
+	"ModCreator/file"
+	"ModCreator/objects"
+	"ModCreator/types"
+	"fmt"
+)
+
+// Merge writes raw like Write, but over the objects directory described by
+// r.Layout instead of a cleared one: every object keeps the json file name,
+// and the directory of its contained objects, of the object with its GUID in
+// it, new objects get files of their own, and rm deletes the files of objects
+// that are gone or have moved. It returns what became of every object.
+func (r *Reverser) Merge(raw map[string]interface{}, rm file.FileRemover) ([]objects.Change, error) {
+	if r.Layout == nil {
+		r.Layout = &objects.Layout{}
+	}
+	r.written = &objects.Layout{}
+	if err := r.Write(raw); err != nil {
+		return nil, err
+	}
+	for _, f := range r.Layout.Stale(r.written) {
+		if err := rm.Remove(f); err != nil {
+			return nil, fmt.Errorf("Remove(%s): %v", f, err)
+		}
+	}
+	return r.Layout.Changes(r.written), nil
+}
+
+// MergeMods merges the changes theirs, a save of the mod built as base, made
+// into ours, the mod built from the source tree: the fields of the mod like
+// objects.MergeFields, and its objects like objects.MergeObjectStates. The
+// times of the saves are left out.
+func MergeMods(base, ours, theirs map[string]interface{}) (map[string]interface{}, []objects.Applied, []objects.Conflict, error) {
+	objs := make([][]map[string]interface{}, 3)
+	fields := make([]types.J, 3)
+	for i, raw := range []map[string]interface{}{base, ours, theirs} {
+		// a built mod holds values of other types than one read from a file
+		m, err := types.Normalize(raw)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if sp, ok := m["SnapPoints"]; ok {
+			// like reverse does, so that the jitter of a save isn't a change
+			if m["SnapPoints"], err = objects.SmoothSnapPoints(sp); err != nil {
+				return nil, nil, nil, fmt.Errorf("SmoothSnapPoints(): %v", err)
+			}
+		}
+		fields[i] = types.J{}
+		for k, v := range m {
+			fields[i][k] = v
+		}
+		arr, err := types.ConvertToObjArray(m["ObjectStates"])
+		if err != nil && m["ObjectStates"] != nil {
+			return nil, nil, nil, fmt.Errorf("mismatch type expectations for ObjectStates : %v", err)
+		}
+		objs[i] = arr
+		for _, k := range []string{"ObjectStates", DateKey, EpochKey} {
+			delete(fields[i], k)
+		}
+	}
+	merged, applied, conflicts, err := objects.MergeObjectStates(objs[0], objs[1], objs[2])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("MergeObjectStates(): %v", err)
+	}
+	out, changed, clashes := objects.MergeFields(fields[0], fields[1], fields[2])
+	modApplied := []objects.Applied{}
+	for _, f := range changed {
+		modApplied = append(modApplied, objects.Applied{Field: f, Msg: "changed"})
+	}
+	modConflicts := []objects.Conflict{}
+	for _, f := range clashes {
+		modConflicts = append(modConflicts, objects.Conflict{Field: f, Base: fields[0][f], Ours: fields[1][f], Theirs: fields[2][f], Msg: "changed in both ours and theirs; kept ours"})
+	}
+	out["ObjectStates"] = merged
+	return out, append(modApplied, applied...), append(modConflicts, conflicts...), nil
+}
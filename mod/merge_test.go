@@ -0,0 +1,160 @@
+package mod
+
+import (
+	"ModCreator/objects"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMerge(t *testing.T) {
+	objs := tests.NewFF()
+	objs.Data["Board.json"] = types.J{
+		"GUID":                   "111111",
+		"Nickname":               "Board",
+		"ContainedObjects_path":  "pieces",
+		"ContainedObjects_order": []string{"king"},
+	}
+	objs.Data["pieces/king.json"] = types.J{
+		"GUID":           "222222",
+		"Nickname":       "King",
+		"LuaScript_path": "pieces/king.ttslua",
+	}
+	objs.Fs["pieces/king.ttslua"] = "function onLoad() end"
+	objs.Data["Old.333333.json"] = types.J{
+		"GUID": "333333",
+	}
+	p := &objects.Parser{J: objs, Dir: objs}
+	layout, err := p.Layout()
+	if err != nil {
+		t.Fatalf("Layout(): %v", err)
+	}
+
+	root := tests.NewFF()
+	r := Reverser{
+		ModSettingsWriter: tests.NewFF(),
+		LuaWriter:         objs,
+		XMLWriter:         objs,
+		ObjWriter:         objs,
+		ObjDirCreator:     objs,
+		RootWrite:         root,
+		Layout:            layout,
+	}
+	raw := map[string]interface{}{
+		"ObjectStates": []interface{}{
+			map[string]interface{}{
+				"GUID":     "111111",
+				"Nickname": "Board",
+				"ContainedObjects": []interface{}{
+					map[string]interface{}{
+						"GUID":      "222222",
+						"Nickname":  "King",
+						"LuaScript": "x = 1",
+					},
+				},
+			},
+			map[string]interface{}{
+				"GUID":     "444444",
+				"Nickname": "Die",
+			},
+		},
+	}
+	got, err := r.Merge(raw, objs)
+	if err != nil {
+		t.Fatalf("Merge(): %v", err)
+	}
+	want := []objects.Change{
+		{Kind: objects.Updated, GUID: "111111", From: "Board.json", To: "Board.json"},
+		{Kind: objects.Updated, GUID: "222222", From: "pieces/king.json", To: "pieces/king.json"},
+		{Kind: objects.Added, GUID: "444444", To: "Die.444444.json"},
+		{Kind: objects.Removed, GUID: "333333", From: "Old.333333.json"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	files := []string{}
+	for f := range objs.Data {
+		files = append(files, f)
+	}
+	for f := range objs.Fs {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	// the king's script is short enough to be kept inline now
+	if diff := cmp.Diff([]string{"Board.json", "Die.444444.json", "pieces/king.json"}, files); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	board := objs.Data["Board.json"]
+	if diff := cmp.Diff("pieces", board["ContainedObjects_path"]); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff([]string{"king"}, board["ContainedObjects_order"]); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff([]string{"Board.111111", "Die.444444"}, root.Data["config.json"]["ObjectStates_order"]); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestMergeMods(t *testing.T) {
+	base := map[string]interface{}{
+		"SaveName":   "base",
+		"Note":       "base",
+		"Date":       "1/1/2020",
+		"SnapPoints": []interface{}{map[string]interface{}{"Position": map[string]interface{}{"x": 1.0, "y": 2.0, "z": 3.0}}},
+		"ObjectStates": []interface{}{
+			map[string]interface{}{"GUID": "111111", "Nickname": "Die"},
+		},
+	}
+	// built from the source tree
+	ours := map[string]interface{}{
+		"SaveName":   "base",
+		"Note":       "ours",
+		"Date":       "1/2/2020",
+		"SnapPoints": []map[string]interface{}{{"Position": types.J{"x": 1.0, "y": 2.0, "z": 3.0}}},
+		"ObjectStates": []map[string]interface{}{
+			{"GUID": "111111", "Nickname": "Die"},
+		},
+	}
+	theirs := map[string]interface{}{
+		"SaveName":   "theirs",
+		"Note":       "theirs",
+		"Date":       "1/3/2020",
+		"SnapPoints": []interface{}{map[string]interface{}{"Position": map[string]interface{}{"x": 1.000432, "y": 2.0, "z": 3.0}}},
+		"ObjectStates": []interface{}{
+			map[string]interface{}{"GUID": "111111", "Nickname": "Die", "Locked": true},
+		},
+	}
+	got, applied, conflicts, err := MergeMods(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("MergeMods(): %v", err)
+	}
+	want := map[string]interface{}{
+		"SaveName":   "theirs",
+		"Note":       "ours",
+		"SnapPoints": []map[string]interface{}{{"Position": types.J{"x": 1.0, "y": 2.0, "z": 3.0}}},
+		"ObjectStates": []map[string]interface{}{
+			{"GUID": "111111", "Nickname": "Die", "Locked": true},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantApplied := []objects.Applied{
+		{Field: "SaveName", Msg: "changed"},
+		{GUID: "111111", Name: "Die", Field: "Locked", Msg: "changed"},
+	}
+	if diff := cmp.Diff(wantApplied, applied); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantConflicts := []objects.Conflict{
+		{Field: "Note", Base: "base", Ours: "ours", Theirs: "theirs", Msg: "changed in both ours and theirs; kept ours"},
+	}
+	if diff := cmp.Diff(wantConflicts, conflicts); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,16 @@
+package mod
+
+import "ModCreator/project"
+
+// New sets a Mod up to read the mod directory ops are for. How it checks and
+// bundles scripts, and where it writes, are left to the caller.
+func New(ops project.Ops) *Mod {
+	return &Mod{
+		Lua:         ops.Lua,
+		XML:         ops.XML,
+		Modsettings: ops.ModSettings,
+		Objs:        ops.Objs,
+		Objdirs:     ops.ObjDir,
+		RootRead:    ops.Root,
+	}
+}
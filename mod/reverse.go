@@ -22,15 +22,54 @@ type Reverser struct {
 
 	// If not empty: holds the entire filename (C:...) of the json to read
 	OnlyObjState string
+	// How many objects may be written concurrently
+	Jobs int
+
+	// Sizes above which values get their own file; zero means the default
+	InlineLimit        int
+	SettingsObjLimit   int
+	SettingsArrayLimit int
+
+	// If set: objects keep the files of the objects with the same GUID in it,
+	// see Merge
+	Layout *objects.Layout
+
+	// written is where the last Write put every object
+	written *objects.Layout
 }
 
-func (r *Reverser) writeOnlyObjStates(raw map[string]interface{}) error {
-	printer := &objects.Printer{
-		Lua:    r.LuaWriter,
-		LuaSrc: r.LuaSrcWriter,
-		J:      r.ObjWriter,
-		Dir:    r.ObjDirCreator,
+var (
+	// DefaultSettingsObjLimit is the printed size above which a top level json
+	// object is written to modsettings
+	DefaultSettingsObjLimit = 100
+	// DefaultSettingsArrayLimit is the printed size above which a top level
+	// json array is written to modsettings
+	DefaultSettingsArrayLimit = 200
+)
+
+func orDefault(v, def int) int {
+	if v != 0 {
+		return v
 	}
+	return def
+}
+
+func (r *Reverser) printer() *objects.Printer {
+	return &objects.Printer{
+		Lua:         r.LuaWriter,
+		LuaSrc:      r.LuaSrcWriter,
+		XML:         r.XMLWriter,
+		XMLSrc:      r.XMLSrcWriter,
+		J:           r.ObjWriter,
+		Dir:         r.ObjDirCreator,
+		Jobs:        r.Jobs,
+		InlineLimit: r.InlineLimit,
+		Keep:        r.Layout,
+	}
+}
+
+func (r *Reverser) writeOnlyObjStates(raw map[string]interface{}) error {
+	printer := r.printer()
 	arraywrap := []map[string]interface{}{raw}
 	_, err := printer.PrintObjectStates("", arraywrap)
 	if err != nil {
@@ -62,7 +101,7 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 		}
 		ext := ".luascriptstate"
 		// decide if creating a separate file is worth it
-		if len(strVal) < 80 {
+		if len(strVal) < orDefault(r.InlineLimit, handler.DefaultInlineLimit) {
 			raw[strKey] = strVal
 			continue
 		}
@@ -92,6 +131,7 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 	lh := handler.NewLuaHandler()
 	lh.DefaultWriter = r.LuaWriter
 	lh.SrcWriter = r.LuaSrcWriter
+	lh.InlineLimit = r.InlineLimit
 
 	act, err := lh.WhileWritingToFile(raw, "LuaScript.ttslua")
 	if err != nil {
@@ -105,6 +145,7 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 	xh := handler.NewXMLHandler()
 	xh.DefaultWriter = r.XMLWriter
 	xh.SrcWriter = r.XMLSrcWriter
+	xh.InlineLimit = r.InlineLimit
 
 	act, err = xh.WhileWritingToFile(raw, "Root.xml")
 	if err != nil {
@@ -124,7 +165,7 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 			}
 
 			// decide if creating a separate file is worth it
-			if len(fmt.Sprint(objVal)) < 100 {
+			if len(fmt.Sprint(objVal)) < orDefault(r.SettingsObjLimit, DefaultSettingsObjLimit) {
 				continue
 			}
 
@@ -153,7 +194,7 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 				arr = smoothed
 			}
 			// decide if creating a separate file is worth it
-			if len(fmt.Sprint(arr)) < 200 {
+			if len(fmt.Sprint(arr)) < orDefault(r.SettingsArrayLimit, DefaultSettingsArrayLimit) {
 				raw[objKey] = arr
 				continue
 			}
@@ -173,18 +214,12 @@ func (r *Reverser) Write(raw map[string]interface{}) error {
 		if err != nil {
 			return fmt.Errorf("mismatch type expectations for ObjectStates : %v", err)
 		}
-		printer := &objects.Printer{
-			Lua:    r.LuaWriter,
-			LuaSrc: r.LuaSrcWriter,
-			XML:    r.XMLWriter,
-			XMLSrc: r.XMLSrcWriter,
-			J:      r.ObjWriter,
-			Dir:    r.ObjDirCreator,
-		}
+		printer := r.printer()
 		order, err := printer.PrintObjectStates("", objStates)
 		if err != nil {
 			return fmt.Errorf("PrintObjectStates('', <%v objects>): %v", len(objStates), err)
 		}
+		r.written = printer.Layout()
 		raw["ObjectStates_order"] = order
 		delete(raw, "ObjectStates")
 	}
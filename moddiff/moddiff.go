@@ -0,0 +1,147 @@
+// Package moddiff compares two Tabletop Simulator mod files and reports the
+// meaningful differences between them, ignoring values that are expected to
+// vary between otherwise-equivalent savegames (timestamps and numeric jitter).
+// Objects are matched by GUID, so the report tells objects added, removed,
+// moved or reordered apart from the fields that changed in them. Either mod
+// can be a mod directory, built in memory, so that a source tree is compared
+// with a save directly. It backs both the standalone moddiff command and the
+// diff subcommand.
+package moddiff
+
+import (
+	"ModCreator/project"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// ignoreUnpredictable reports whether a map entry should be skipped when
+// diffing. Numeric values and the Date/EpochTime fields drift between
+// otherwise-equivalent savegames, so comparing them only produces noise.
+func ignoreUnpredictable(k string, v interface{}) bool {
+	// Date and EpochTime are regenerated on every build by design.
+	if k == "Date" || k == "EpochTime" {
+		return true
+	}
+
+	return false
+}
+
+// Compare reads two mods, each a mod file or a mod directory built with src,
+// and reports every difference found between them.
+func Compare(patha, pathb string, src Sources) (*Report, error) {
+	a, err := Load(patha, src)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Load(pathb, src)
+	if err != nil {
+		return nil, err
+	}
+	return CompareMods(a, b)
+}
+
+// CompareMods is Compare for mods that are already in memory.
+func CompareMods(a, b map[string]interface{}) (*Report, error) {
+	osKey := "ObjectStates"
+	objs := make([][]map[string]interface{}, 2)
+	fields := make([]map[string]interface{}, 2)
+	for i, m := range []map[string]interface{}{a, b} {
+		raw, ok := m[osKey]
+		if !ok {
+			return nil, fmt.Errorf("Expected key %s in map", osKey)
+		}
+		arr, err := toObjArray(raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot cast to obj array %v", err)
+		}
+		objs[i] = arr
+		fields[i] = map[string]interface{}{}
+		for k, v := range m {
+			if k != osKey {
+				fields[i][k] = v
+			}
+		}
+	}
+	r := &Report{Fields: compareFields(fields[0], fields[1])}
+	if err := compareObjects(r, objs[0], objs[1]); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func toObjArray(i interface{}) ([]map[string]interface{}, error) {
+	if arr, ok := i.([]map[string]interface{}); ok {
+		return arr, nil
+	}
+	arr := []map[string]interface{}{}
+
+	ir, ok := i.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Could not cast input as array")
+	}
+	for _, rawo := range ir {
+		o, ok := rawo.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("could not cast element to json obj: \n%v", rawo)
+		}
+		arr = append(arr, o)
+	}
+	return arr, nil
+}
+
+// Run implements the moddiff command line: it parses args, compares the two
+// mods and prints the result. It returns the process exit code: 0 when the mods
+// are equivalent, 1 when they differ or cannot be read, and 2 for bad usage.
+func Run(name string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	modfilea := fs.String("a", "", "path to the first mod file, or mod directory, to compare")
+	modfileb := fs.String("b", "", "path to the second mod file, or mod directory, to compare")
+	asJSON := fs.Bool("json", false, "print the differences as json")
+	src := Sources{}
+	fs.StringVar(&src.BonusDir, "bonusdir", "", "additional folder to check for Lua and XML require/include when building a mod directory (replaces bonusDirs from "+project.Filename+")")
+	fs.Var((*project.StringList)(&src.LibPaths), "libpath", "a directory to search for Lua and XML require/include when building a mod directory, may be repeated (searched before libPaths from "+project.Filename+")")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s -a path/to/first.json|moddir -b path/to/second.json|moddir [--json] [--libpath dir]...\n\n", name)
+		fmt.Fprintln(stderr, "Compare two mods object by object, by GUID, ignoring timestamps and numeric jitter. Either mod may be a mod directory, which is built in memory first. Differences are printed as a markdown report, or as json.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if *modfilea == "" || *modfileb == "" {
+		fmt.Fprintln(stderr, "both -a and -b must be set to mod file or directory paths")
+		fs.Usage()
+		return 2
+	}
+
+	report, err := Compare(*modfilea, *modfileb, src)
+	if err != nil {
+		fmt.Fprintf(stderr, "Compare(%s,%s) : %v\n", *modfilea, *modfileb, err)
+		return 1
+	}
+
+	if *asJSON {
+		if err := report.WriteJSON(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	} else if !report.Empty() {
+		report.WriteText(stdout)
+	}
+	if !report.Empty() {
+		fmt.Fprintf(stderr, "mods differ: found %d difference(s)\n", report.Count())
+		return 1
+	}
+
+	if !*asJSON {
+		fmt.Fprintln(stdout, "no differences")
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package moddiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMod(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", p, err)
+	}
+	return p
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	a := writeMod(t, dir, "a.json", `{"SaveName":"x","Date":"1","ObjectStates":[{"GUID":"123456","Name":"Card"}]}`)
+	same := writeMod(t, dir, "same.json", `{"SaveName":"x","Date":"2","ObjectStates":[{"GUID":"123456","Name":"Card"}]}`)
+	jitter := writeMod(t, dir, "jitter.json", `{"SaveName":"x","ObjectStates":[{"GUID":"123456","Name":"Card","Date":"3"}]}`)
+	changed := writeMod(t, dir, "changed.json", `{"SaveName":"x","ObjectStates":[{"GUID":"123456","Name":"Deck"}]}`)
+	moddir := filepath.Join(dir, "mod")
+	if err := os.MkdirAll(filepath.Join(moddir, "objects"), 0755); err != nil {
+		t.Fatalf("MkdirAll(): %v", err)
+	}
+	writeMod(t, moddir, "config.json", `{"SaveName":"x","ObjectStates_order":["Card.123456"]}`)
+	writeMod(t, filepath.Join(moddir, "objects"), "Card.123456.json", `{"GUID":"123456","Name":"Card","LuaScript":"require(\"util\")"}`)
+	// the module is only found with --libpath
+	libdir := filepath.Join(dir, "lib")
+	if err := os.MkdirAll(libdir, 0755); err != nil {
+		t.Fatalf("MkdirAll(): %v", err)
+	}
+	writeMod(t, libdir, "util.ttslua", "function util() end")
+
+	for _, tc := range []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "equal", args: []string{"-a", a, "-b", same}, wantCode: 0, wantOut: "no differences"},
+		{name: "ignored keys", args: []string{"-a", a, "-b", jitter}, wantCode: 0, wantOut: "no differences"},
+		{name: "differ", args: []string{"-a", a, "-b", changed}, wantCode: 1, wantOut: "Deck"},
+		{name: "moddir", args: []string{"--libpath", libdir, "-a", moddir, "-b", moddir}, wantCode: 0, wantOut: "no differences"},
+		{name: "moddir differs", args: []string{"--libpath", libdir, "-a", changed, "-b", moddir}, wantCode: 1, wantOut: "Card"},
+		{name: "moddir without libpath", args: []string{"-a", changed, "-b", moddir}, wantCode: 1},
+		{name: "json", args: []string{"--json", "-a", a, "-b", changed}, wantCode: 1, wantOut: `"field": "Name"`},
+		{name: "missing file", args: []string{"-a", a, "-b", filepath.Join(dir, "nope.json")}, wantCode: 1},
+		{name: "missing flag", args: []string{"-a", a}, wantCode: 2},
+		{name: "help", args: []string{"-h"}, wantCode: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			got := Run("moddiff", tc.args, &stdout, &stderr)
+			if got != tc.wantCode {
+				t.Errorf("Run() = %d, want %d; stderr:\n%s", got, tc.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.wantOut) {
+				t.Errorf("stdout %q does not contain %q", stdout.String(), tc.wantOut)
+			}
+		})
+	}
+}
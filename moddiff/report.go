@@ -0,0 +1,313 @@
+package moddiff
+
+import (
+	"ModCreator/objects"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Report is every difference between two mods, a and b, object by object.
+type Report struct {
+	// Fields are the fields of the mods themselves that differ
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Added are the objects only b has, Removed those only a has
+	Added   []Object `json:"added,omitempty"`
+	Removed []Object `json:"removed,omitempty"`
+	// Moved are the objects in another container in b
+	Moved []Move `json:"moved,omitempty"`
+	// Changed are the objects in both whose fields differ
+	Changed []ObjectChange `json:"changed,omitempty"`
+	// Reordered are the containers, and the mod itself, whose contained
+	// objects are in another order in b
+	Reordered []Reorder `json:"reordered,omitempty"`
+}
+
+// Object is an object of one of the mods compared.
+type Object struct {
+	GUID string `json:"guid"`
+	// Name is its Nickname, or its Name when it has none
+	Name string `json:"name,omitempty"`
+	// In is the container it is in, empty for a root object
+	In string `json:"in,omitempty"`
+}
+
+func (o Object) String() string {
+	s := describe(o.GUID, o.Name)
+	if o.In != "" {
+		s += " in " + o.In
+	}
+	return s
+}
+
+func describe(guid, name string) string {
+	if name == "" {
+		return guid
+	}
+	return fmt.Sprintf("%s (%s)", name, guid)
+}
+
+// FieldChange is a field that differs. Text fields, like scripts, are given as
+// a unified diff of their lines instead of their values.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+	Diff  string      `json:"diff,omitempty"`
+}
+
+// ObjectChange is an object whose fields differ.
+type ObjectChange struct {
+	Object
+	Fields []FieldChange `json:"fields"`
+}
+
+// Move is an object contained in another container in b.
+type Move struct {
+	Object
+	// From and To are the containers, empty for the root of the mod
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Reorder is a container whose contained objects, by GUID, are in another
+// order in b.
+type Reorder struct {
+	// Container is empty for the root objects of the mod
+	Container string   `json:"container,omitempty"`
+	Old       []string `json:"old"`
+	New       []string `json:"new"`
+}
+
+// Empty tells whether the mods compared are equivalent.
+func (r *Report) Empty() bool {
+	return len(r.Fields)+len(r.Added)+len(r.Removed)+len(r.Moved)+len(r.Changed)+len(r.Reordered) == 0
+}
+
+// Count is the number of differences in r.
+func (r *Report) Count() int {
+	n := len(r.Fields) + len(r.Added) + len(r.Removed) + len(r.Moved) + len(r.Reordered)
+	for _, c := range r.Changed {
+		n += len(c.Fields)
+	}
+	return n
+}
+
+// textFields are compared as text, line by line
+var textFields = map[string]bool{
+	"LuaScript":      true,
+	"LuaScriptState": true,
+	"XmlUI":          true,
+	"GMNotes":        true,
+	"Description":    true,
+	"Note":           true,
+}
+
+var cmpOpts = []cmp.Option{cmpopts.IgnoreMapEntries(ignoreUnpredictable), objects.ApproxFloats}
+
+// compareFields lists the fields of a and b that differ, by name.
+func compareFields(a, b map[string]interface{}) []FieldChange {
+	keys := map[string]bool{}
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if !ignoreUnpredictable(k, nil) {
+				keys[k] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	changes := []FieldChange{}
+	for _, k := range names {
+		av, aok := a[k]
+		bv, bok := b[k]
+		if aok == bok && cmp.Equal(av, bv, cmpOpts...) {
+			continue
+		}
+		as, aText := av.(string)
+		bs, bText := bv.(string)
+		if (aText || !aok) && (bText || !bok) && (textFields[k] || strings.Contains(as+bs, "\n")) {
+			// texts differing only in their line endings, or in being empty
+			// or none at all, have no diff and are told apart by their values
+			if d := UnifiedDiff(as, bs); d != "" {
+				changes = append(changes, FieldChange{Field: k, Diff: d})
+				continue
+			}
+		}
+		changes = append(changes, FieldChange{Field: k, Old: av, New: bv})
+	}
+	return changes
+}
+
+// where describes the container of n, empty for the root of the mod.
+func where(t *objects.Tree, n *objects.Node) string {
+	if n.Parent == "" {
+		return ""
+	}
+	p := t.Nodes[n.Parent]
+	s := describe(p.GUID, p.Name())
+	if n.State != "" {
+		s = fmt.Sprintf("state %s of %s", n.State, s)
+	}
+	return s
+}
+
+func object(t *objects.Tree, key string) Object {
+	n := t.Nodes[key]
+	return Object{GUID: n.GUID, Name: n.Name(), In: where(t, n)}
+}
+
+func guids(t *objects.Tree, keys []string) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = t.Nodes[k].GUID
+	}
+	return out
+}
+
+// compareObjects adds the differences between the objects of a and b to r.
+func compareObjects(r *Report, a, b []map[string]interface{}) error {
+	at, err := objects.NewTree(a)
+	if err != nil {
+		return err
+	}
+	bt, err := objects.NewTree(b)
+	if err != nil {
+		return err
+	}
+	for _, k := range at.Keys {
+		if _, ok := bt.Nodes[k]; !ok {
+			r.Removed = append(r.Removed, object(at, k))
+		}
+	}
+	for _, k := range bt.Keys {
+		bn := bt.Nodes[k]
+		an, ok := at.Nodes[k]
+		if !ok {
+			r.Added = append(r.Added, object(bt, k))
+			continue
+		}
+		if an.Parent != bn.Parent || an.State != bn.State {
+			r.Moved = append(r.Moved, Move{Object: Object{GUID: bn.GUID, Name: bn.Name()}, From: where(at, an), To: where(bt, bn)})
+		}
+		if fields := compareFields(an.Data, bn.Data); len(fields) > 0 {
+			r.Changed = append(r.Changed, ObjectChange{Object: object(bt, k), Fields: fields})
+		}
+	}
+	containers := append([]string{""}, bt.Keys...)
+	for _, k := range containers {
+		if _, ok := at.Nodes[k]; k != "" && !ok {
+			continue
+		}
+		if !objects.SameOrder(at.Order[k], bt.Order[k]) {
+			re := Reorder{Old: guids(at, at.Order[k]), New: guids(bt, bt.Order[k])}
+			if k != "" {
+				re.Container = describe(bt.Nodes[k].GUID, bt.Nodes[k].Name())
+			}
+			r.Reordered = append(r.Reordered, re)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes r as indented json.
+func (r *Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent(): %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// maxValue is how much of a value WriteText shows
+const maxValue = 200
+
+func showValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(b) > maxValue {
+		return string(b[:maxValue]) + "…"
+	}
+	return string(b)
+}
+
+func writeFields(w io.Writer, fields []FieldChange) {
+	for i, f := range fields {
+		if f.Diff != "" {
+			fmt.Fprintf(w, "- %s:\n\n```diff\n%s```\n", f.Field, f.Diff)
+			if i < len(fields)-1 {
+				fmt.Fprintln(w)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "- %s: %s → %s\n", f.Field, showValue(f.Old), showValue(f.New))
+	}
+}
+
+// WriteText writes r as a markdown report, to be read as it is or posted as a
+// comment.
+func (r *Report) WriteText(w io.Writer) {
+	section := func(title string, n int) bool {
+		if n == 0 {
+			return false
+		}
+		fmt.Fprintf(w, "## %s (%d)\n\n", title, n)
+		return true
+	}
+	if section("Mod fields", len(r.Fields)) {
+		writeFields(w, r.Fields)
+		fmt.Fprintln(w)
+	}
+	if section("Added objects", len(r.Added)) {
+		for _, o := range r.Added {
+			fmt.Fprintf(w, "- %s\n", o)
+		}
+		fmt.Fprintln(w)
+	}
+	if section("Removed objects", len(r.Removed)) {
+		for _, o := range r.Removed {
+			fmt.Fprintf(w, "- %s\n", o)
+		}
+		fmt.Fprintln(w)
+	}
+	if section("Moved objects", len(r.Moved)) {
+		for _, m := range r.Moved {
+			fmt.Fprintf(w, "- %s: %s → %s\n", m.Object, container(m.From), container(m.To))
+		}
+		fmt.Fprintln(w)
+	}
+	if section("Changed objects", len(r.Changed)) {
+		for _, c := range r.Changed {
+			fmt.Fprintf(w, "### %s\n\n", c.Object)
+			writeFields(w, c.Fields)
+			fmt.Fprintln(w)
+		}
+	}
+	if section("Reordered contained objects", len(r.Reordered)) {
+		for _, re := range r.Reordered {
+			fmt.Fprintf(w, "- %s: %s → %s\n", container(re.Container), strings.Join(re.Old, ", "), strings.Join(re.New, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func container(s string) string {
+	if s == "" {
+		return "the mod"
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package moddiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareMods(t *testing.T) {
+	a := map[string]interface{}{
+		"SaveName": "x",
+		"Note":     "",
+		"Date":     "1/1/2020",
+		"ObjectStates": []interface{}{
+			map[string]interface{}{
+				"GUID":     "deck01",
+				"Nickname": "Deck",
+				"ContainedObjects": []interface{}{
+					map[string]interface{}{"GUID": "card01", "Nickname": "Ace"},
+					map[string]interface{}{"GUID": "card02", "Nickname": "King"},
+				},
+			},
+			map[string]interface{}{"GUID": "die001", "Name": "Die_6", "LuaScript": "a = 1\nb = 2\n"},
+			map[string]interface{}{"GUID": "gone01", "Nickname": "Gone"},
+			map[string]interface{}{"GUID": "crlf01", "LuaScript": "a = 1\r\nb = 2\r\n", "XmlUI": "<Text/>\n"},
+		},
+	}
+	b := map[string]interface{}{
+		"SaveName": "y",
+		"Date":     "1/2/2020",
+		"ObjectStates": []map[string]interface{}{
+			{"GUID": "die001", "Name": "Die_6", "LuaScript": "a = 1\nb = 3\n", "Locked": true},
+			{
+				"GUID":     "deck01",
+				"Nickname": "Deck",
+				"ContainedObjects": []interface{}{
+					map[string]interface{}{"GUID": "card01", "Nickname": "Ace"},
+				},
+			},
+			{"GUID": "card02", "Nickname": "King"},
+			{"GUID": "new001", "Nickname": "New"},
+			{"GUID": "crlf01", "LuaScript": "a = 1\nb = 2\n", "XmlUI": "<Text/>"},
+		},
+	}
+	got, err := CompareMods(a, b)
+	if err != nil {
+		t.Fatalf("CompareMods(): %v", err)
+	}
+	want := &Report{
+		Fields:  []FieldChange{{Field: "Note", Old: ""}, {Field: "SaveName", Old: "x", New: "y"}},
+		Added:   []Object{{GUID: "new001", Name: "New"}},
+		Removed: []Object{{GUID: "gone01", Name: "Gone"}},
+		Moved:   []Move{{Object: Object{GUID: "card02", Name: "King"}, From: "Deck (deck01)"}},
+		Changed: []ObjectChange{{
+			Object: Object{GUID: "die001", Name: "Die_6"},
+			Fields: []FieldChange{
+				{Field: "Locked", New: true},
+				{Field: "LuaScript", Diff: "@@ -1,2 +1,2 @@\n a = 1\n-b = 2\n+b = 3\n"},
+			},
+		}, {
+			// no line differs, but the texts do
+			Object: Object{GUID: "crlf01"},
+			Fields: []FieldChange{
+				{Field: "LuaScript", Old: "a = 1\r\nb = 2\r\n", New: "a = 1\nb = 2\n"},
+				{Field: "XmlUI", Old: "<Text/>\n", New: "<Text/>"},
+			},
+		}},
+		Reordered: []Reorder{{Old: []string{"deck01", "die001", "gone01", "crlf01"}, New: []string{"die001", "deck01", "card02", "new001", "crlf01"}}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if got.Count() != 10 {
+		t.Errorf("Count() = %d, want 10", got.Count())
+	}
+
+	var text bytes.Buffer
+	got.WriteText(&text)
+	for _, s := range []string{"King (card02): Deck (deck01) → the mod", "```diff\n@@ -1,2 +1,2 @@"} {
+		if !strings.Contains(text.String(), s) {
+			t.Errorf("WriteText() %q does not contain %q", text.String(), s)
+		}
+	}
+}
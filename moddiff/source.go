@@ -0,0 +1,55 @@
+package moddiff
+
+import (
+	"ModCreator/file"
+	"ModCreator/handler"
+	"ModCreator/mod"
+	"ModCreator/project"
+	"ModCreator/types"
+	"fmt"
+	"os"
+)
+
+// Sources are where the requires and Includes of a mod directory are looked
+// for besides its own, like the --bonusdir and --libpath of build.
+type Sources struct {
+	// BonusDir replaces the bonusDirs of the project file, if set
+	BonusDir string
+	// LibPaths are searched before the libPaths of the project file
+	LibPaths []string
+}
+
+// Load reads the mod at path: a mod json file, or a mod directory, which is
+// built in memory like build would, with the settings of its project file and
+// src. The Lua and XmlUI checks are skipped, since they don't change what is
+// built.
+func Load(path string, src Sources) (map[string]interface{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return file.ReadRawFile(path)
+	}
+	p, err := project.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	p.AddSources(src.BonusDir, src.LibPaths)
+	m := mod.New(p.Ops(path))
+	m.SavedObj = p.SavedObject
+	m.Jobs = p.Jobs
+	m.LuaOptions = handler.LuaOptions{
+		SkipCheck:   true,
+		Minify:      p.MinifyLua,
+		AllowCycles: p.AllowRequireCycles,
+		Shared:      p.SharedModules,
+	}
+	m.XMLOptions = handler.XMLOptions{SkipCheck: true}
+	if err := m.GenerateFromConfig(); err != nil {
+		return nil, fmt.Errorf("GenerateFromConfig(%s): %v", path, err)
+	}
+	// a built mod holds values of other types than one read from a file, such
+	// as types.J for the States of an object
+	return types.Normalize(m.Data)
+}
@@ -0,0 +1,191 @@
+package moddiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround each change of a unified
+// diff.
+const contextLines = 3
+
+// editKind is what an edit does to a line.
+type editKind byte
+
+const (
+	keep   editKind = ' '
+	remove editKind = '-'
+	insert editKind = '+'
+)
+
+type edit struct {
+	kind editKind
+	line string
+	// a and b are the indices of the line in the old and new text; only the
+	// one of the side it is on is meaningful for deletes and inserts
+	a, b int
+}
+
+// UnifiedDiff renders the changes from a to b as a unified diff of their lines,
+// without file headers. It is empty when a and b are equal.
+func UnifiedDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+	var sb strings.Builder
+	for start := 0; start < len(edits); {
+		// find the next change and the hunk around it
+		for start < len(edits) && edits[start].kind == keep {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		lo := start - contextLines
+		if lo < 0 {
+			lo = 0
+		}
+		hi, kept := start, 0
+		for hi < len(edits) && kept <= 2*contextLines {
+			if edits[hi].kind == keep {
+				kept++
+			} else {
+				kept = 0
+			}
+			hi++
+		}
+		// trim the context after the last change
+		if kept > contextLines {
+			hi -= kept - contextLines
+		}
+		writeHunk(&sb, edits[lo:hi])
+		start = hi
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, hunk []edit) {
+	aStart, bStart, aLen, bLen := -1, -1, 0, 0
+	for _, e := range hunk {
+		if e.kind != insert {
+			if aStart < 0 {
+				aStart = e.a
+			}
+			aLen++
+		}
+		if e.kind != remove {
+			if bStart < 0 {
+				bStart = e.b
+			}
+			bLen++
+		}
+	}
+	// an empty side is numbered after the line before it, like diff -u does
+	if aStart < 0 {
+		aStart = hunk[0].a - 1
+	}
+	if bStart < 0 {
+		bStart = hunk[0].b - 1
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, e := range hunk {
+		fmt.Fprintf(sb, "%c%s\n", e.kind, e.line)
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// maxEdits bounds the work of diffLines on texts with little in common, which
+// are then shown as replaced as a whole
+const maxEdits = 1000
+
+// diffLines finds a shortest edit script from a to b with Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset, d)
+			}
+		}
+	}
+	edits := []edit{}
+	for i, line := range a {
+		edits = append(edits, edit{kind: remove, line: line, a: i, b: 0})
+	}
+	for i, line := range b {
+		edits = append(edits, edit{kind: insert, line: line, a: n, b: i})
+	}
+	return edits
+}
+
+// backtrack walks the furthest reaching paths of every d back from the end,
+// collecting the edits in order.
+func backtrack(a, b []string, trace [][]int, offset, d int) []edit {
+	x, y := len(a), len(b)
+	rev := []edit{}
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			rev = append(rev, edit{kind: keep, line: a[x], a: x, b: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, edit{kind: insert, line: b[y], a: x, b: y})
+		} else {
+			x--
+			rev = append(rev, edit{kind: remove, line: a[x], a: x, b: y})
+		}
+	}
+	edits := make([]edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
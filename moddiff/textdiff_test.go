@@ -0,0 +1,36 @@
+package moddiff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name, a, b, want string
+	}{
+		{name: "equal", a: "x\ny\n", b: "x\ny\n", want: ""},
+		{name: "added", a: "", b: "x\n", want: "@@ -0,0 +1 @@\n+x\n"},
+		{name: "removed", a: "x\ny", b: "y", want: "@@ -1,2 +1 @@\n-x\n y\n"},
+		{
+			name: "two hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n",
+			b:    "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n",
+			want: "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -11,3 +11,4 @@\n k\n l\n m\n+n\n",
+		},
+		{
+			name: "one hunk",
+			a:    "a\nb\nc\nd\ne\nf\n",
+			b:    "a\nB\nc\nd\ne\nF\n",
+			want: "@@ -1,6 +1,6 @@\n a\n-b\n+B\n c\n d\n e\n-f\n+F\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := UnifiedDiff(tc.a, tc.b)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package objects
+
+import (
+	"ModCreator/assets"
+	"ModCreator/file"
+	"fmt"
+	"sort"
+)
+
+// Assets lists the asset URLs of every object, with the object and file using
+// each.
+func (p *Parser) Assets() (assets.Inventory, error) {
+	roots, err := p.parseTree()
+	if err != nil {
+		return nil, err
+	}
+	inv := assets.Inventory{}
+	for _, r := range roots {
+		r.walk(func(o *objConfig) {
+			assets.Walk(map[string]interface{}(o.data), "", func(field, url string) (string, bool) {
+				inv.Add(url, assets.Use{GUID: o.guid, File: o.srcFile, Field: field})
+				return url, false
+			})
+		})
+	}
+	return inv, nil
+}
+
+// RewriteAssets replaces every asset URL in the files of the objects that
+// rewrite maps to another, writing each changed file back through w. It
+// returns how many URLs changed in each file, by the name srcFile gives it.
+func (p *Parser) RewriteAssets(w file.JSONWriter, rewrite func(url string) (string, bool)) (map[string]int, error) {
+	roots, err := p.parseTree()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, r := range roots {
+		r.walk(func(o *objConfig) { files[o.file] = o.srcFile })
+	}
+	names := make([]string, 0, len(files))
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	changed := map[string]int{}
+	for _, f := range names {
+		// read again, as parsing smooths numbers and takes contained
+		// objects out
+		raw, err := p.J.ReadObj(f)
+		if err != nil {
+			return changed, fmt.Errorf("ReadObj(%s): %v", f, err)
+		}
+		n := 0
+		assets.Walk(raw, "", func(field, url string) (string, bool) {
+			to, ok := rewrite(url)
+			if ok && to != url {
+				n++
+			}
+			return to, ok
+		})
+		if n == 0 {
+			continue
+		}
+		if err := w.WriteObj(raw, f); err != nil {
+			return changed, fmt.Errorf("WriteObj(<>, %s): %v", f, err)
+		}
+		changed[files[f]] = n
+	}
+	return changed, nil
+}
@@ -0,0 +1,116 @@
+package objects
+
+import (
+	"ModCreator/assets"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func assetsFixture() *tests.FakeFiles {
+	j := tests.NewFF()
+	j.Data["Deck.111111.json"] = types.J{
+		"GUID":     "111111",
+		"Nickname": "Deck",
+		"CustomDeck": map[string]interface{}{
+			"1": map[string]interface{}{"FaceURL": "http://old.host/face.png", "BackURL": "http://old.host/back.png"},
+		},
+		"ContainedObjects_path":  "Deck.111111",
+		"ContainedObjects_order": []string{"Card.222222"},
+	}
+	j.Data["Deck.111111/Card.222222.json"] = types.J{
+		"GUID":     "222222",
+		"Nickname": "Card",
+		"CustomDeck": map[string]interface{}{
+			"1": map[string]interface{}{"FaceURL": "http://old.host/face.png", "BackURL": "http://old.host/back.png"},
+		},
+	}
+	j.Data["Model.333333.json"] = types.J{
+		"GUID": "333333",
+		"CustomMesh": map[string]interface{}{
+			"MeshURL":    "http://other.host/model.obj",
+			"DiffuseURL": "",
+		},
+		"States": map[string]interface{}{
+			"2": map[string]interface{}{
+				"GUID":        "444444",
+				"CustomImage": map[string]interface{}{"ImageURL": "http://old.host/tile.png"},
+			},
+		},
+	}
+	return j
+}
+
+func TestParserAssets(t *testing.T) {
+	j := assetsFixture()
+	p := &Parser{J: j, Dir: j}
+	got, err := p.Assets()
+	if err != nil {
+		t.Fatalf("Assets(): %v", err)
+	}
+	want := assets.Inventory{
+		"http://old.host/face.png": {
+			{GUID: "111111", File: "Deck.111111.json", Field: "CustomDeck.1.FaceURL"},
+			{GUID: "222222", File: "Deck.111111/Card.222222.json", Field: "CustomDeck.1.FaceURL"},
+		},
+		"http://old.host/back.png": {
+			{GUID: "111111", File: "Deck.111111.json", Field: "CustomDeck.1.BackURL"},
+			{GUID: "222222", File: "Deck.111111/Card.222222.json", Field: "CustomDeck.1.BackURL"},
+		},
+		"http://other.host/model.obj": {
+			{GUID: "333333", File: "Model.333333.json", Field: "CustomMesh.MeshURL"},
+		},
+		"http://old.host/tile.png": {
+			{GUID: "444444", File: "Model.333333.json", Field: "CustomImage.ImageURL"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestParserRewriteAssets(t *testing.T) {
+	j := assetsFixture()
+	p := &Parser{J: j, Dir: j}
+	rewrite := func(url string) (string, bool) {
+		if strings.HasPrefix(url, "http://old.host/") {
+			return strings.Replace(url, "http://old.host/", "https://new.host/", 1), true
+		}
+		return url, false
+	}
+	out := tests.NewFF()
+	got, err := p.RewriteAssets(out, rewrite)
+	if err != nil {
+		t.Fatalf("RewriteAssets(): %v", err)
+	}
+	want := map[string]int{
+		"Deck.111111.json":             2,
+		"Deck.111111/Card.222222.json": 2,
+		"Model.333333.json":            1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantDeck := types.J{
+		"GUID":     "111111",
+		"Nickname": "Deck",
+		"CustomDeck": map[string]interface{}{
+			"1": map[string]interface{}{"FaceURL": "https://new.host/face.png", "BackURL": "https://new.host/back.png"},
+		},
+		"ContainedObjects_path":  "Deck.111111",
+		"ContainedObjects_order": []interface{}{"Card.222222"},
+	}
+	if diff := cmp.Diff(wantDeck, out.Data["Deck.111111.json"]); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantState := map[string]interface{}{
+		"GUID":        "444444",
+		"CustomImage": map[string]interface{}{"ImageURL": "https://new.host/tile.png"},
+	}
+	if diff := cmp.Diff(wantState, out.Data["Model.333333.json"]["States"].(map[string]interface{})["2"]); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
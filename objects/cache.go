@@ -0,0 +1,309 @@
+package objects
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/file"
+	"ModCreator/handler"
+	. "ModCreator/types"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cacheVersion is bumped whenever the cache layout or the meaning of a cached
+// output changes, so stale caches from older binaries are discarded.
+const cacheVersion = 4
+
+// dependency kinds, used to prefix the recorded file names so the same name
+// read through two different readers is tracked separately.
+const (
+	luaDep  = "lua"
+	xmlDep  = "xml"
+	jsonDep = "json"
+)
+
+// BuildCache remembers the printed output of every root object along with a
+// hash of each file that was read to produce it: the object's own JSON, its
+// contained objects and states, and every Lua and XML file pulled in through
+// require or Include. Dependencies are re-read through the same readers when
+// checking an entry, so a file that now resolves from a different search path
+// invalidates the entry just like an edited one does.
+type BuildCache struct {
+	mu       sync.Mutex
+	settings string
+	entries  map[string]*cacheEntry
+	used     map[string]bool
+	hits     int
+	misses   int
+}
+
+type cacheEntry struct {
+	Name   string            `json:"name"`
+	Deps   map[string]string `json:"deps"`
+	Output json.RawMessage   `json:"output"`
+	// Maps are the source maps of the object's scripts and those it contains
+	Maps map[string]*bundler.SourceMap `json:"maps,omitempty"`
+	// Warnings and Minified are what printing it reported, to be reported
+	// again when it is reused
+	Warnings []handler.XMLWarning `json:"warnings,omitempty"`
+	Minified []handler.Minified   `json:"minified,omitempty"`
+}
+
+type cacheFile struct {
+	Version  int                    `json:"version"`
+	Settings string                 `json:"settings"`
+	Entries  map[string]*cacheEntry `json:"entries"`
+}
+
+// NewBuildCache creates an empty cache. settings describes every option that
+// affects printed output; entries saved under different settings are never
+// reused.
+func NewBuildCache(settings string) *BuildCache {
+	return &BuildCache{
+		settings: settings,
+		entries:  map[string]*cacheEntry{},
+		used:     map[string]bool{},
+	}
+}
+
+// LoadBuildCache reads a cache previously written by Save. A missing, corrupt
+// or incompatible cache file is not an error: the build simply starts cold.
+func LoadBuildCache(p, settings string) *BuildCache {
+	c := NewBuildCache(settings)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return c
+	}
+	var cf cacheFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		return c
+	}
+	if cf.Version != cacheVersion || cf.Settings != settings || cf.Entries == nil {
+		return c
+	}
+	c.entries = cf.Entries
+	return c
+}
+
+// Save writes the entries used since the last Save to p and forgets the rest,
+// so objects that no longer exist don't accumulate. It also resets Stats.
+func (c *BuildCache) Save(p string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.entries {
+		if !c.used[k] {
+			delete(c.entries, k)
+		}
+	}
+	c.used = map[string]bool{}
+	c.hits, c.misses = 0, 0
+
+	b, err := json.Marshal(cacheFile{
+		Version:  cacheVersion,
+		Settings: c.settings,
+		Entries:  c.entries,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("MkdirAll(%s): %v", filepath.Dir(p), err)
+	}
+	return os.WriteFile(p, b, 0644)
+}
+
+// Stats reports how many root objects were reused and rebuilt since the last
+// Save.
+func (c *BuildCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// lookup returns the cached output, and the entry it is from, for the root
+// object stored in file, if every file it depended on is unchanged.
+func (c *BuildCache) lookup(file string, l, x file.TextReader, j file.JSONReader) (J, *cacheEntry, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[file]
+	c.mu.Unlock()
+	if !ok || !e.fresh(l, x, j) {
+		c.mu.Lock()
+		c.misses++
+		c.mu.Unlock()
+		return nil, nil, false
+	}
+	var out J
+	if err := json.Unmarshal(e.Output, &out); err != nil {
+		c.mu.Lock()
+		c.misses++
+		c.mu.Unlock()
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	c.used[file] = true
+	c.hits++
+	c.mu.Unlock()
+	return out, e, true
+}
+
+// store records the printed output of a freshly built root object. The output
+// is serialized immediately so later changes to the map can't leak in.
+func (c *BuildCache) store(file, name string, rec *depRecorder, out J, maps map[string]*bundler.SourceMap, pl *printLog) error {
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[file] = &cacheEntry{
+		Name:     name,
+		Deps:     rec.snapshot(),
+		Output:   b,
+		Maps:     maps,
+		Warnings: pl.warnings,
+		Minified: pl.minified,
+	}
+	c.used[file] = true
+	return nil
+}
+
+func (e *cacheEntry) fresh(l, x file.TextReader, j file.JSONReader) bool {
+	for key, want := range e.Deps {
+		kind, name := splitDepKey(key)
+		var got string
+		switch kind {
+		case luaDep:
+			got = hashText(l.EncodeFromFile(name))
+		case xmlDep:
+			got = hashText(x.EncodeFromFile(name))
+		case jsonDep:
+			got = hashJSON(j.ReadObj(name))
+		default:
+			return false
+		}
+		if got != want {
+			return false
+		}
+	}
+	return true
+}
+
+func depKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+func splitDepKey(key string) (string, string) {
+	kind, name, _ := strings.Cut(key, ":")
+	return kind, name
+}
+
+// hashText hashes the result of a read. A failed read hashes to the empty
+// string, so a file that later appears invalidates the entry.
+func hashText(s string, err error) string {
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashJSON(m map[string]interface{}, err error) string {
+	if err != nil {
+		return ""
+	}
+	// encoding/json sorts map keys, so equal objects hash equally
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return hashText(string(b), nil)
+}
+
+// depRecorder wraps readers and remembers the hash of everything read through
+// them while building a single root object.
+type depRecorder struct {
+	mu   sync.Mutex
+	deps map[string]string
+}
+
+func newDepRecorder() *depRecorder {
+	return &depRecorder{deps: map[string]string{}}
+}
+
+func (r *depRecorder) record(key, hash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deps[key] = hash
+}
+
+func (r *depRecorder) snapshot() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deps := make(map[string]string, len(r.deps))
+	for k, v := range r.deps {
+		deps[k] = v
+	}
+	return deps
+}
+
+func (r *depRecorder) text(kind string, t file.TextReader) file.TextReader {
+	return &recordingText{rec: r, kind: kind, r: t}
+}
+
+func (r *depRecorder) json(j file.JSONReader) file.JSONReader {
+	return &recordingJSON{rec: r, r: j}
+}
+
+type recordingText struct {
+	rec  *depRecorder
+	kind string
+	r    file.TextReader
+}
+
+// EncodeFromFile satisfies file.TextReader
+func (t *recordingText) EncodeFromFile(name string) (string, error) {
+	s, err := t.r.EncodeFromFile(name)
+	t.rec.record(depKey(t.kind, name), hashText(s, err))
+	return s, err
+}
+
+// Resolve forwards to the wrapped reader, so errors can still name files.
+func (t *recordingText) Resolve(name string) (string, error) {
+	if r, ok := t.r.(file.PathResolver); ok {
+		return r.Resolve(name)
+	}
+	return name, nil
+}
+
+type recordingJSON struct {
+	rec *depRecorder
+	r   file.JSONReader
+}
+
+// Resolve forwards to the wrapped reader, so errors can still name files.
+func (j *recordingJSON) Resolve(name string) (string, error) {
+	if r, ok := j.r.(file.PathResolver); ok {
+		return r.Resolve(name)
+	}
+	return name, nil
+}
+
+// ReadObj satisfies file.JSONReader. The hash is taken before returning since
+// callers are free to mutate the map.
+func (t *recordingJSON) ReadObj(name string) (map[string]interface{}, error) {
+	m, err := t.r.ReadObj(name)
+	t.rec.record(depKey(jsonDep, name), hashJSON(m, err))
+	return m, err
+}
+
+// ReadObjArray satisfies file.JSONReader. Object files are never arrays, so
+// these reads are passed through unrecorded.
+func (t *recordingJSON) ReadObjArray(name string) ([]map[string]interface{}, error) {
+	return t.r.ReadObjArray(name)
+}
@@ -0,0 +1,222 @@
+package objects
+
+import (
+	"ModCreator/bundler"
+	"ModCreator/handler"
+	"ModCreator/tests"
+	"ModCreator/types"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func cacheFixture() (*tests.FakeFiles, *tests.FakeFiles) {
+	lua := tests.NewFF()
+	lua.Fs["lib/helper.ttslua"] = "function help() end"
+	lua.Fs["bag.ttslua"] = `require("lib/helper")`
+
+	j := tests.NewFF()
+	j.Data["Bag.111111.json"] = types.J{
+		"GUID":                   "111111",
+		"Nickname":               "Bag",
+		"LuaScript_path":         "bag.ttslua",
+		"ContainedObjects_path":  "Bag.111111",
+		"ContainedObjects_order": []string{"Card.222222"},
+	}
+	j.Data["Bag.111111/Card.222222.json"] = types.J{
+		"GUID":     "222222",
+		"Nickname": "Card",
+	}
+	j.Data["Die.333333.json"] = types.J{
+		"GUID":     "333333",
+		"Nickname": "Die",
+	}
+	return lua, j
+}
+
+func parseWithCache(t *testing.T, c *BuildCache, lua, j *tests.FakeFiles) string {
+	t.Helper()
+	p := &Parser{Lua: lua, XML: lua, J: j, Dir: j, Cache: c}
+	got, err := p.ParseAllObjectStates([]string{"Bag.111111", "Die.333333"})
+	if err != nil {
+		t.Fatalf("ParseAllObjectStates(): %v", err)
+	}
+	b, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent(): %v", err)
+	}
+	return string(b)
+}
+
+func TestBuildCacheReuse(t *testing.T) {
+	lua, j := cacheFixture()
+	cold := parseWithCache(t, nil, lua, j)
+
+	c := NewBuildCache("test")
+	if got := parseWithCache(t, c, lua, j); got != cold {
+		t.Errorf("first cached build differs from cold build:\n%v", cmp.Diff(cold, got))
+	}
+	if hits, misses := c.Stats(); hits != 0 || misses != 2 {
+		t.Errorf("first build Stats() = %v hits, %v misses; want 0, 2", hits, misses)
+	}
+
+	if got := parseWithCache(t, c, lua, j); got != cold {
+		t.Errorf("warm build differs from cold build:\n%v", cmp.Diff(cold, got))
+	}
+	if hits, misses := c.Stats(); hits != 2 || misses != 2 {
+		t.Errorf("second build Stats() = %v hits, %v misses; want 2, 2", hits, misses)
+	}
+}
+
+func TestBuildCacheInvalidation(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(lua, j *tests.FakeFiles)
+	}{
+		{
+			name: "required lua",
+			change: func(lua, j *tests.FakeFiles) {
+				lua.Fs["lib/helper.ttslua"] = "function help() return 1 end"
+			},
+		},
+		{
+			name: "contained object json",
+			change: func(lua, j *tests.FakeFiles) {
+				j.Data["Bag.111111/Card.222222.json"]["Description"] = "new"
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lua, j := cacheFixture()
+			c := NewBuildCache("test")
+			parseWithCache(t, c, lua, j)
+			if err := c.Save(filepath.Join(t.TempDir(), "cache.json")); err != nil {
+				t.Fatalf("Save(): %v", err)
+			}
+
+			tc.change(lua, j)
+			got := parseWithCache(t, c, lua, j)
+			want := parseWithCache(t, nil, lua, j)
+			if got != want {
+				t.Errorf("cached build differs from cold build:\n%v", cmp.Diff(want, got))
+			}
+			// only the bag depends on the changed file
+			if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+				t.Errorf("Stats() = %v hits, %v misses; want 1, 1", hits, misses)
+			}
+		})
+	}
+}
+
+func TestBuildCacheSaveLoad(t *testing.T) {
+	lua, j := cacheFixture()
+	p := filepath.Join(t.TempDir(), "sub", "cache.json")
+
+	c := NewBuildCache("test")
+	want := parseWithCache(t, c, lua, j)
+	if err := c.Save(p); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+
+	loaded := LoadBuildCache(p, "test")
+	if got := parseWithCache(t, loaded, lua, j); got != want {
+		t.Errorf("loaded cache build differs:\n%v", cmp.Diff(want, got))
+	}
+	if hits, _ := loaded.Stats(); hits != 2 {
+		t.Errorf("loaded cache hits = %v, want 2", hits)
+	}
+
+	other := LoadBuildCache(p, "different settings")
+	parseWithCache(t, other, lua, j)
+	if hits, _ := other.Stats(); hits != 0 {
+		t.Errorf("cache with different settings hits = %v, want 0", hits)
+	}
+}
+
+func TestBuildCacheKeepsSourceMaps(t *testing.T) {
+	lua, j := cacheFixture()
+	p := filepath.Join(t.TempDir(), "cache.json")
+	build := func(c *BuildCache) map[string]*bundler.SourceMap {
+		maps := bundler.NewSourceMaps()
+		parser := &Parser{Lua: lua, XML: lua, J: j, Dir: j, Cache: c, SourceMaps: maps}
+		if _, err := parser.ParseAllObjectStates([]string{"Bag.111111", "Die.333333"}); err != nil {
+			t.Fatalf("ParseAllObjectStates(): %v", err)
+		}
+		return maps.All()
+	}
+
+	c := NewBuildCache("test")
+	want := build(c)
+	if _, ok := want["111111"]; !ok {
+		t.Fatalf("no source map for the bag's script: %v", want)
+	}
+	if err := c.Save(p); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+
+	loaded := LoadBuildCache(p, "test")
+	got := build(loaded)
+	if hits, _ := loaded.Stats(); hits != 2 {
+		t.Errorf("loaded cache hits = %v, want 2", hits)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestBuildCacheReportsAgain(t *testing.T) {
+	lua, j := cacheFixture()
+	lua.Fs["bag.ttslua"] = "-- the bag\nrequire(\"lib/helper\")"
+	j.Data["Die.333333.json"]["XmlUI"] = `<Text fontsize="12"/>`
+	p := filepath.Join(t.TempDir(), "cache.json")
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+	build := func(c *BuildCache) ([]string, string) {
+		logged.Reset()
+		warnings := []string{}
+		parser := &Parser{
+			Lua: lua, XML: lua, J: j, Dir: j, Cache: c,
+			LuaOptions: handler.LuaOptions{Minify: true},
+			XMLOptions: handler.XMLOptions{Warn: func(w handler.XMLWarning) {
+				warnings = append(warnings, w.String())
+			}},
+		}
+		if _, err := parser.ParseAllObjectStates([]string{"Bag.111111", "Die.333333"}); err != nil {
+			t.Fatalf("ParseAllObjectStates(): %v", err)
+		}
+		return warnings, logged.String()
+	}
+
+	c := NewBuildCache("test")
+	wantWarnings, coldLog := build(c)
+	if len(wantWarnings) != 1 || !strings.Contains(coldLog, "minified Lua of 111111") {
+		t.Fatalf("cold build reported %v and logged %q, want a warning and the bag minified", wantWarnings, coldLog)
+	}
+	if err := c.Save(p); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+
+	loaded := LoadBuildCache(p, "test")
+	gotWarnings, warmLog := build(loaded)
+	if hits, _ := loaded.Stats(); hits != 2 {
+		t.Errorf("loaded cache hits = %v, want 2", hits)
+	}
+	if diff := cmp.Diff(wantWarnings, gotWarnings); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff(coldLog, warmLog); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
@@ -0,0 +1,30 @@
+package objects
+
+// ObjectGUID is the GUID of an object and where it is read from.
+type ObjectGUID struct {
+	GUID string
+	// File is the json file the object is read from, or that holds it
+	File string
+	// Name is the object's Nickname, or its Name when it has none
+	Name string
+}
+
+// GUIDs lists the GUID of every object, contained object and state, in the
+// order of the objects directory.
+func (p *Parser) GUIDs() ([]ObjectGUID, error) {
+	roots, err := p.parseTree()
+	if err != nil {
+		return nil, err
+	}
+	guids := []ObjectGUID{}
+	for _, r := range roots {
+		r.walk(func(o *objConfig) {
+			name, _ := o.data["Nickname"].(string)
+			if name == "" {
+				name, _ = o.data["Name"].(string)
+			}
+			guids = append(guids, ObjectGUID{GUID: o.guid, File: o.srcFile, Name: name})
+		})
+	}
+	return guids, nil
+}
@@ -0,0 +1,207 @@
+package objects
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Placement is where the files of an object are in the objects directory.
+type Placement struct {
+	GUID string
+	// File is the object's json file
+	File string
+	// Dir is the directory of its contained objects and states, relative to
+	// the directory of File, empty when it has none
+	Dir string
+	// Scripts are the other files the object is read from: its LuaScript,
+	// XmlUI, LuaScriptState and GMNotes
+	Scripts []string
+}
+
+// Layout is every object of an objects directory that has its own json file,
+// in the order of the directory, so that reversing a mod into it again can
+// keep each object's files where they are.
+type Layout struct {
+	Placements []Placement
+}
+
+// Layout reads where the files of every object in the objects directory are,
+// without bundling any of their scripts.
+func (p *Parser) Layout() (*Layout, error) {
+	roots, err := p.parseTree()
+	if err != nil {
+		return nil, err
+	}
+	return layoutOf(roots), nil
+}
+
+// Layout is where the last PrintObjectStates wrote every object.
+func (p *Printer) Layout() *Layout {
+	return layoutOf(p.printed)
+}
+
+func layoutOf(roots []*objConfig) *Layout {
+	l := &Layout{Placements: []Placement{}}
+	var add func(o *objConfig, parentFile string)
+	add = func(o *objConfig, parentFile string) {
+		// contained objects and states kept in the json of their parent
+		// don't have files of their own
+		if o.file != parentFile {
+			l.Placements = append(l.Placements, o.placement())
+		}
+		for _, sub := range o.subObj {
+			add(sub, o.file)
+		}
+		for _, name := range sortedKeys(o.states) {
+			add(o.states[name], o.file)
+		}
+	}
+	for _, r := range roots {
+		add(r, "")
+	}
+	return l
+}
+
+func (o *objConfig) placement() Placement {
+	pl := Placement{GUID: o.guid, File: o.file, Scripts: []string{}}
+	if len(o.subObj) > 0 || len(o.states) > 0 {
+		pl.Dir = o.subObjDir
+	}
+	// parsed objects hold the paths of LuaScriptState and GMNotes apart from
+	// their data, printed ones in it
+	for _, script := range []interface{}{o.data["LuaScript_path"], o.data["XmlUI_path"], o.luascriptstatePath, o.data["LuaScriptState_path"], o.gmnotesPath, o.data["GMNotes_path"]} {
+		if s, ok := script.(string); ok && s != "" {
+			pl.Scripts = append(pl.Scripts, s)
+		}
+	}
+	return pl
+}
+
+// place gives every object of roots with the GUID of one in l the file name
+// and directory of that one. Objects sharing a GUID are matched in order.
+func (l *Layout) place(roots []*objConfig) error {
+	byGUID := map[string][]Placement{}
+	for _, pl := range l.Placements {
+		byGUID[pl.GUID] = append(byGUID[pl.GUID], pl)
+	}
+	var visit func(o *objConfig) error
+	visit = func(o *objConfig) error {
+		if pls := byGUID[o.guid]; len(pls) > 0 {
+			byGUID[o.guid] = pls[1:]
+			o.name = strings.TrimSuffix(path.Base(pls[0].File), ".json")
+			o.subObjDir = pls[0].Dir
+		}
+		children := make([]*objConfig, 0, len(o.subObj)+len(o.states))
+		for i, sub := range o.subObj {
+			if err := visit(sub); err != nil {
+				return err
+			}
+			o.subObjOrder[i] = sub.getAGoodFileName()
+			children = append(children, sub)
+		}
+		for _, name := range sortedKeys(o.states) {
+			st := o.states[name]
+			if err := visit(st); err != nil {
+				return err
+			}
+			o.stateNames[name] = st.getAGoodFileName()
+			children = append(children, st)
+		}
+		if err := checkFilenameCollisions(children); err != nil {
+			return fmt.Errorf("children of %q: %v", o.guid, err)
+		}
+		return nil
+	}
+	for _, r := range roots {
+		if err := visit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangeKind says what became of an object's files.
+type ChangeKind string
+
+// Kinds of Change.
+const (
+	Added   ChangeKind = "added"
+	Updated ChangeKind = "updated"
+	Moved   ChangeKind = "moved"
+	Removed ChangeKind = "removed"
+)
+
+// Change is what became of the json file of one object between two layouts.
+type Change struct {
+	Kind ChangeKind
+	GUID string
+	// From is the file in the old layout, empty for Added
+	From string
+	// To is the file in the new layout, empty for Removed
+	To string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("added   %s (%s)", c.To, c.GUID)
+	case Removed:
+		return fmt.Sprintf("removed %s (%s)", c.From, c.GUID)
+	case Moved:
+		return fmt.Sprintf("moved   %s -> %s (%s)", c.From, c.To, c.GUID)
+	}
+	return fmt.Sprintf("updated %s (%s)", c.To, c.GUID)
+}
+
+// Changes lists what became of the objects of l in to, matching them by GUID
+// like reversing into l does: the objects of to in order, then those of l that
+// are gone.
+func (l *Layout) Changes(to *Layout) []Change {
+	byGUID := map[string][]Placement{}
+	for _, pl := range l.Placements {
+		byGUID[pl.GUID] = append(byGUID[pl.GUID], pl)
+	}
+	changes := []Change{}
+	for _, pl := range to.Placements {
+		old := byGUID[pl.GUID]
+		if len(old) == 0 {
+			changes = append(changes, Change{Kind: Added, GUID: pl.GUID, To: pl.File})
+			continue
+		}
+		byGUID[pl.GUID] = old[1:]
+		kind := Updated
+		if old[0].File != pl.File {
+			kind = Moved
+		}
+		changes = append(changes, Change{Kind: kind, GUID: pl.GUID, From: old[0].File, To: pl.File})
+	}
+	for _, pl := range l.Placements {
+		if old := byGUID[pl.GUID]; len(old) > 0 && old[0].File == pl.File {
+			byGUID[pl.GUID] = old[1:]
+			changes = append(changes, Change{Kind: Removed, GUID: pl.GUID, From: pl.File})
+		}
+	}
+	return changes
+}
+
+// Stale lists the files of l that none of the objects of to is written to,
+// sorted.
+func (l *Layout) Stale(to *Layout) []string {
+	kept := map[string]bool{}
+	for _, pl := range to.Placements {
+		kept[pl.File] = true
+		for _, s := range pl.Scripts {
+			kept[s] = true
+		}
+	}
+	stale := map[string]bool{}
+	for _, pl := range l.Placements {
+		for _, f := range append([]string{pl.File}, pl.Scripts...) {
+			if !kept[f] {
+				stale[f] = true
+			}
+		}
+	}
+	return sortedKeys(stale)
+}
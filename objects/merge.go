@@ -0,0 +1,306 @@
+package objects
+
+import (
+	. "ModCreator/types"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// Applied is a change of theirs taken into the result of a merge.
+type Applied struct {
+	// GUID is empty for the fields of the mod itself
+	GUID string `json:"guid,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Field is empty when the whole object was added or removed
+	Field string `json:"field,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+func (a Applied) String() string {
+	return fmt.Sprintf("%s: %s", owner(a.GUID, a.Name, a.Field), a.Msg)
+}
+
+// Conflict is a field, or a whole object, that ours and theirs both changed
+// from base, differently. The merge keeps ours.
+type Conflict struct {
+	// GUID is empty for the fields of the mod itself
+	GUID string `json:"guid,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Field is empty when the conflict is about the whole object
+	Field string `json:"field,omitempty"`
+	// Base, Ours and Theirs are nil where the field or object is missing
+	Base   interface{} `json:"base"`
+	Ours   interface{} `json:"ours"`
+	Theirs interface{} `json:"theirs"`
+	Msg    string      `json:"msg"`
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: %s", owner(c.GUID, c.Name, c.Field), c.Msg)
+}
+
+func owner(guid, name, field string) string {
+	s := "mod"
+	if guid != "" {
+		s = guid
+		if name != "" {
+			s += " (" + name + ")"
+		}
+	}
+	if field != "" {
+		s += " " + field
+	}
+	return s
+}
+
+// Container is the field of Conflicts and Applied changes about which object
+// an object is contained in.
+const Container = "(container)"
+
+// ApproxFloats compares numbers with a small absolute tolerance, consistent
+// with number smoothing (positions 3dp, scale 2dp, colors 5dp), so that the
+// jitter TTS adds to them on every save isn't a change.
+var ApproxFloats = cmpopts.EquateApprox(0, 1e-4)
+
+func equal(a, b interface{}) bool {
+	return cmp.Equal(a, b, ApproxFloats)
+}
+
+// MergeFields merges the changes theirs made to the fields of base into ours:
+// a field changed only by theirs takes its value, one changed by both to
+// different values keeps that of ours and is reported by name.
+func MergeFields(base, ours, theirs J) (J, []string, []string) {
+	out := J{}
+	for k, v := range ours {
+		out[k] = v
+	}
+	applied, conflicts := []string{}, []string{}
+	keys := map[string]bool{}
+	for _, m := range []J{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for _, k := range sortedKeys(keys) {
+		b, inB := base[k]
+		o, inO := ours[k]
+		t, inT := theirs[k]
+		switch {
+		case inO == inT && equal(o, t):
+		case inB == inT && equal(b, t):
+			// only ours changed it
+		case inB == inO && equal(b, o):
+			if inT {
+				out[k] = t
+			} else {
+				delete(out, k)
+			}
+			applied = append(applied, k)
+		default:
+			conflicts = append(conflicts, k)
+		}
+	}
+	return out, applied, conflicts
+}
+
+// slot is where n is, to tell whether it moved.
+func slot(n *Node) string {
+	if n.State != "" {
+		return n.Parent + " state " + n.State
+	}
+	return n.Parent
+}
+
+// newMergeTree is the Tree of one side of a merge, with its numbers smoothed
+// like reverse does.
+func newMergeTree(objs []map[string]interface{}) (*Tree, error) {
+	// smoothing changes the data, so it works on a copy
+	cp := make([]map[string]interface{}, len(objs))
+	for i, raw := range objs {
+		var err error
+		if cp[i], err = Normalize(raw); err != nil {
+			return nil, err
+		}
+	}
+	t, err := NewTree(cp)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range t.Nodes {
+		if err := smoothFields(n.Data, n.GUID); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// MergeObjectStates merges the changes theirs made to the objects of base
+// into ours, matching objects by GUID: fields changed only by theirs, objects
+// they added or removed, moved into another container or reordered are taken
+// from theirs; where ours changed the same thing differently, ours is kept
+// and a Conflict reported.
+func MergeObjectStates(base, ours, theirs []map[string]interface{}) ([]map[string]interface{}, []Applied, []Conflict, error) {
+	trees := make([]*Tree, 3)
+	for i, objs := range [][]map[string]interface{}{base, ours, theirs} {
+		t, err := newMergeTree(objs)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("newMergeTree(): %v", err)
+		}
+		trees[i] = t
+	}
+	bt, ot, tt := trees[0], trees[1], trees[2]
+
+	keys := map[string]bool{}
+	for _, t := range trees {
+		for k := range t.Nodes {
+			keys[k] = true
+		}
+	}
+	applied, conflicts := []Applied{}, []Conflict{}
+	// result are the objects kept, with their merged data and where they go
+	result := map[string]*Node{}
+	for _, k := range sortedKeys(keys) {
+		b, o, t := bt.Nodes[k], ot.Nodes[k], tt.Nodes[k]
+		guid, name := k, ""
+		for _, n := range []*Node{o, t, b} {
+			if n != nil {
+				guid, name = n.GUID, n.Name()
+				break
+			}
+		}
+		switch {
+		case o == nil && t == nil:
+		case o == nil && b == nil:
+			result[k] = t
+			applied = append(applied, Applied{GUID: guid, Name: name, Msg: "added"})
+		case o == nil:
+			if !equal(b.Data, t.Data) {
+				conflicts = append(conflicts, Conflict{GUID: guid, Name: name, Base: b.Data, Theirs: t.Data, Msg: "removed from ours but changed in theirs; left out"})
+			}
+		case t == nil && b != nil:
+			if equal(b.Data, o.Data) && slot(b) == slot(o) {
+				applied = append(applied, Applied{GUID: guid, Name: name, Msg: "removed"})
+				continue
+			}
+			conflicts = append(conflicts, Conflict{GUID: guid, Name: name, Base: b.Data, Ours: o.Data, Msg: "removed from theirs but changed in ours; kept"})
+			result[k] = o
+		case t == nil:
+			result[k] = o
+		default:
+			bd := J{}
+			if b != nil {
+				bd = b.Data
+			}
+			data, fields, clashes := MergeFields(bd, o.Data, t.Data)
+			for _, f := range fields {
+				applied = append(applied, Applied{GUID: guid, Name: name, Field: f, Msg: "changed"})
+			}
+			for _, f := range clashes {
+				conflicts = append(conflicts, Conflict{GUID: guid, Name: name, Field: f, Base: bd[f], Ours: o.Data[f], Theirs: t.Data[f], Msg: "changed in both ours and theirs; kept ours"})
+			}
+			n := &Node{GUID: o.GUID, Data: data, Parent: o.Parent, State: o.State}
+			switch {
+			case slot(o) == slot(t):
+			case b != nil && slot(b) == slot(o):
+				n.Parent, n.State = t.Parent, t.State
+				applied = append(applied, Applied{GUID: guid, Name: name, Field: Container, Msg: "moved to " + where(t)})
+			default:
+				conflicts = append(conflicts, Conflict{GUID: guid, Name: name, Field: Container, Ours: where(o), Theirs: where(t), Msg: "moved in both ours and theirs; kept ours"})
+			}
+			result[k] = n
+		}
+	}
+
+	// contained objects are ordered like theirs when ours kept the order of
+	// base, and like ours otherwise
+	children := map[string][]string{}
+	for k, n := range result {
+		if n.State == "" {
+			children[n.Parent] = append(children[n.Parent], k)
+		}
+	}
+	for parent, kids := range children {
+		first, second := ot.Order[parent], tt.Order[parent]
+		if SameOrder(ot.Order[parent], bt.Order[parent]) && !SameOrder(tt.Order[parent], bt.Order[parent]) {
+			first, second = second, first
+		}
+		rank := map[string]int{}
+		for i, k := range append(append([]string{}, first...), second...) {
+			if _, ok := rank[k]; !ok {
+				rank[k] = i
+			}
+		}
+		sort.SliceStable(kids, func(i, j int) bool {
+			ri, iok := rank[kids[i]]
+			rj, jok := rank[kids[j]]
+			if iok != jok {
+				return iok
+			}
+			if !iok {
+				return kids[i] < kids[j]
+			}
+			return ri < rj
+		})
+	}
+
+	reached := map[string]bool{}
+	var assemble func(k string) map[string]interface{}
+	assemble = func(k string) map[string]interface{} {
+		reached[k] = true
+		out := map[string]interface{}{}
+		for f, v := range result[k].Data {
+			out[f] = v
+		}
+		subs := []interface{}{}
+		for _, c := range children[k] {
+			subs = append(subs, assemble(c))
+		}
+		if len(subs) > 0 {
+			out["ContainedObjects"] = subs
+		}
+		states := map[string]interface{}{}
+		for _, c := range sortedKeys(result) {
+			if n := result[c]; n.Parent == k && n.State != "" {
+				states[n.State] = assemble(c)
+			}
+		}
+		if len(states) > 0 {
+			out["States"] = states
+		}
+		return out
+	}
+	objs := []map[string]interface{}{}
+	for _, k := range children[""] {
+		objs = append(objs, assemble(k))
+	}
+	// objects whose container is gone, or that ended up inside each other,
+	// are kept as roots
+	for _, k := range sortedKeys(result) {
+		if reached[k] {
+			continue
+		}
+		n := result[k]
+		if _, ok := result[n.Parent]; ok {
+			conflicts = append(conflicts, Conflict{GUID: n.GUID, Name: n.Name(), Field: Container, Msg: "moved inside an object it contains; kept as a root object"})
+		} else {
+			applied = append(applied, Applied{GUID: n.GUID, Name: n.Name(), Field: Container, Msg: "its container was removed; kept as a root object"})
+		}
+		n.Parent, n.State = "", ""
+		objs = append(objs, assemble(k))
+	}
+	return objs, applied, conflicts, nil
+}
+
+// where describes the container of n.
+func where(n *Node) string {
+	switch {
+	case n.Parent == "":
+		return "the root"
+	case n.State != "":
+		return fmt.Sprintf("state %s of %s", n.State, n.Parent)
+	}
+	return n.Parent
+}
@@ -0,0 +1,83 @@
+package objects
+
+import (
+	. "ModCreator/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeFields(t *testing.T) {
+	base := J{"a": "base", "b": "base", "c": "base", "d": "base", "e": 1.0}
+	ours := J{"a": "base", "b": "ours", "c": "ours", "d": "base", "e": 1.0, "f": "ours"}
+	theirs := J{"a": "theirs", "b": "base", "c": "theirs", "e": 1.00001}
+	got, applied, conflicts := MergeFields(base, ours, theirs)
+	want := J{"a": "theirs", "b": "ours", "c": "ours", "e": 1.0, "f": "ours"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff([]string{"a", "d"}, applied); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if diff := cmp.Diff([]string{"c"}, conflicts); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestMergeObjectStates(t *testing.T) {
+	obj := func(guid, desc string, contained ...interface{}) map[string]interface{} {
+		o := map[string]interface{}{"GUID": guid, "Nickname": "n" + guid, "Description": desc}
+		if len(contained) > 0 {
+			o["ContainedObjects"] = contained
+		}
+		return o
+	}
+	base := []map[string]interface{}{
+		obj("bag", "", obj("a", ""), obj("b", ""), obj("c", "")),
+		obj("gone", ""),
+		obj("kept", ""),
+		obj("mine", ""),
+	}
+	ours := []map[string]interface{}{
+		obj("bag", "ours", obj("a", ""), obj("b", ""), obj("c", "")),
+		obj("gone", ""),
+		obj("kept", "ours"),
+		obj("new", ""),
+	}
+	theirs := []map[string]interface{}{
+		// reordered, with one of them taken out, and another added
+		obj("bag", "theirs", obj("c", "theirs"), obj("a", ""), obj("box", "")),
+		obj("b", ""),
+		obj("mine", "theirs"),
+	}
+	got, applied, conflicts, err := MergeObjectStates(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("MergeObjectStates(): %v", err)
+	}
+	want := []map[string]interface{}{
+		obj("bag", "ours", obj("c", "theirs"), obj("a", ""), obj("box", "")),
+		obj("kept", "ours"),
+		obj("new", ""),
+		obj("b", ""),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantApplied := []Applied{
+		{GUID: "b", Name: "nb", Field: Container, Msg: "moved to the root"},
+		{GUID: "box", Name: "nbox", Msg: "added"},
+		{GUID: "c", Name: "nc", Field: "Description", Msg: "changed"},
+		{GUID: "gone", Name: "ngone", Msg: "removed"},
+	}
+	if diff := cmp.Diff(wantApplied, applied); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	wantConflicts := []Conflict{
+		{GUID: "bag", Name: "nbag", Field: "Description", Base: "", Ours: "ours", Theirs: "theirs", Msg: "changed in both ours and theirs; kept ours"},
+		{GUID: "kept", Name: "nkept", Base: J{"GUID": "kept", "Nickname": "nkept", "Description": ""}, Ours: J{"GUID": "kept", "Nickname": "nkept", "Description": "ours"}, Msg: "removed from theirs but changed in ours; kept"},
+		{GUID: "mine", Name: "nmine", Base: J{"GUID": "mine", "Nickname": "nmine", "Description": ""}, Theirs: J{"GUID": "mine", "Nickname": "nmine", "Description": "theirs"}, Msg: "removed from ours but changed in theirs; left out"},
+	}
+	if diff := cmp.Diff(wantConflicts, conflicts); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
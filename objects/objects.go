@@ -1,18 +1,30 @@
 package objects
 
 import (
+	"ModCreator/bundler"
 	"ModCreator/file"
 	"ModCreator/handler"
 	. "ModCreator/types"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type objConfig struct {
-	guid               string
+	guid string
+	// srcFile is the json file the object was read from, or that holds it
+	srcFile string
+	// file is srcFile as named to the reader of the objects directory
+	file string
+	// name, if set, is the file name the object keeps from an existing
+	// objects directory, instead of one made from its Nickname
+	name               string
 	data               J
 	luascriptstatePath string
 	gmnotesPath        string
@@ -24,39 +36,101 @@ type objConfig struct {
 }
 
 func (o *objConfig) parseFromFile(filepath string, j file.JSONReader) error {
+	return o.parseFromFileIn(filepath, j, nil)
+}
+
+// parseFromFileIn parses an object file and, through pool, its contained
+// objects and states.
+func (o *objConfig) parseFromFileIn(filepath string, j file.JSONReader, pool *workPool) error {
 	d, err := j.ReadObj(filepath)
 	if err != nil {
 		return fmt.Errorf("ReadObj(%s): %v", filepath, err)
 	}
+	o.srcFile, o.file = filepath, filepath
+	if r, ok := j.(file.PathResolver); ok {
+		if full, err := r.Resolve(filepath); err == nil {
+			o.srcFile = full
+		}
+	}
 	err = o.parseFromJSON(d)
 	if err != nil {
 		return fmt.Errorf("<%s>.parseFromJSON(): %v", filepath, err)
 	}
 	if o.subObjDir != "" {
-		for _, oname := range o.subObjOrder {
+		subs := make([]*objConfig, len(o.subObjOrder))
+		err = pool.each(len(o.subObjOrder), func(i int) error {
 			subo := &objConfig{}
-			relFilename := path.Join(path.Dir(filepath), o.subObjDir, fmt.Sprintf("%s.json", oname))
+			relFilename := path.Join(path.Dir(filepath), o.subObjDir, fmt.Sprintf("%s.json", o.subObjOrder[i]))
 
-			err = subo.parseFromFile(relFilename, j)
-			if err != nil {
+			if err := subo.parseFromFileIn(relFilename, j, pool); err != nil {
 				return fmt.Errorf("parseFromFile(%s): %v", relFilename, err)
 			}
-			o.subObj = append(o.subObj, subo)
+			subs[i] = subo
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-		for stateID, oname := range o.stateNames {
+		o.subObj = append(o.subObj, subs...)
+
+		stateIDs := sortedKeys(o.stateNames)
+		states := make([]*objConfig, len(stateIDs))
+		err = pool.each(len(stateIDs), func(i int) error {
 			stateO := &objConfig{}
-			relFilename := path.Join(path.Dir(filepath), o.subObjDir, fmt.Sprintf("%s.json", oname))
+			relFilename := path.Join(path.Dir(filepath), o.subObjDir, fmt.Sprintf("%s.json", o.stateNames[stateIDs[i]]))
 
-			err = stateO.parseFromFile(relFilename, j)
-			if err != nil {
+			if err := stateO.parseFromFileIn(relFilename, j, pool); err != nil {
 				return fmt.Errorf("parseFromFile(%s): %v", relFilename, err)
 			}
-			o.states[stateID] = stateO
+			states[i] = stateO
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, stateID := range stateIDs {
+			o.states[stateID] = states[i]
 		}
 	}
 	return nil
 }
 
+// sortedKeys gives map iteration a stable order, so concurrent work over a map
+// is scheduled (and reports errors) deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// smoothFields smooths the numbers of the fields of an object that TTS jitters
+// on every save.
+func smoothFields(data J, guid string) error {
+	for _, needSmoothing := range []string{"Transform", "ColorDiffuse"} {
+		if v, ok := data[needSmoothing]; ok {
+			data[needSmoothing] = Smooth(v)
+		}
+	}
+	if v, ok := data["AltLookAngle"]; ok {
+		vv, err := SmoothAngle(v)
+		if err != nil {
+			return fmt.Errorf("SmoothAngle(<%s>): %v", "AltLookAngle", err)
+		}
+		data["AltLookAngle"] = vv
+	}
+	if sp, ok := data["AttachedSnapPoints"]; ok {
+		sm, err := SmoothSnapPoints(sp)
+		if err != nil {
+			return fmt.Errorf("SmoothSnapPoints(<%s>): %v", guid, err)
+		}
+		data["AttachedSnapPoints"] = sm
+	}
+	return nil
+}
+
 func (o *objConfig) parseFromJSON(data map[string]interface{}) error {
 	o.data = data
 	dguid, ok := o.data["GUID"]
@@ -79,24 +153,8 @@ func (o *objConfig) parseFromJSON(data map[string]interface{}) error {
 	file.TryParseIntoStrArray(&o.data, "ContainedObjects_order", &o.subObjOrder)
 	file.TryParseIntoStrMap(&o.data, "States_path", &o.stateNames)
 
-	for _, needSmoothing := range []string{"Transform", "ColorDiffuse"} {
-		if v, ok := o.data[needSmoothing]; ok {
-			o.data[needSmoothing] = Smooth(v)
-		}
-	}
-	if v, ok := o.data["AltLookAngle"]; ok {
-		vv, err := SmoothAngle(v)
-		if err != nil {
-			return fmt.Errorf("SmoothAngle(<%s>): %v", "AltLookAngle", err)
-		}
-		o.data["AltLookAngle"] = vv
-	}
-	if sp, ok := o.data["AttachedSnapPoints"]; ok {
-		sm, err := SmoothSnapPoints(sp)
-		if err != nil {
-			return fmt.Errorf("SmoothSnapPoints(<%s>): %v", o.guid, err)
-		}
-		o.data["AttachedSnapPoints"] = sm
+	if err := smoothFields(o.data, o.guid); err != nil {
+		return err
 	}
 
 	if states, ok := o.data["States"]; ok {
@@ -109,7 +167,7 @@ func (o *objConfig) parseFromJSON(data map[string]interface{}) error {
 			if !ok {
 				return fmt.Errorf("type mismatch in State %s : %v", stateName, stateData)
 			}
-			stateO := &objConfig{}
+			stateO := &objConfig{srcFile: o.srcFile, file: o.file}
 			err := stateO.parseFromJSON(stateObj)
 			if err != nil {
 				return fmt.Errorf("parseFromJSON(%v): %v", stateObj, err)
@@ -130,7 +188,7 @@ func (o *objConfig) parseFromJSON(data map[string]interface{}) error {
 			if !ok {
 				return fmt.Errorf("type mismatch in ContainedObjects; want map[string]any got %T", rawSubO)
 			}
-			so := objConfig{}
+			so := objConfig{srcFile: o.srcFile, file: o.file}
 			if err := so.parseFromJSON(subO); err != nil {
 				return fmt.Errorf("parsing sub object of %s : %v", o.guid, err)
 			}
@@ -155,14 +213,81 @@ func (o *objConfig) parseFromJSON(data map[string]interface{}) error {
 }
 
 func (o *objConfig) print(l, x file.TextReader) (J, error) {
+	return o.printIn(l, x, printOpts{})
+}
+
+// printOpts are the settings shared by every object printed in one pass.
+type printOpts struct {
+	// nil means print sequentially
+	pool *workPool
+	lua  handler.LuaOptions
+	xml  handler.XMLOptions
+	// if set, receives the source map of every LuaScript
+	maps *bundler.SourceMaps
+	// if set, records what printing reported
+	log *printLog
+}
+
+// printLog is what printing a root object reported, its XmlUI warnings and
+// minified scripts, kept so that it can be reported again when the root is
+// reused from the cache.
+type printLog struct {
+	mu       sync.Mutex
+	warnings []handler.XMLWarning
+	minified []handler.Minified
+}
+
+func (pl *printLog) warn(ws []handler.XMLWarning) {
+	if pl == nil {
+		return
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.warnings = append(pl.warnings, ws...)
+}
+
+func (pl *printLog) minify(owner string, act handler.HandleAction) {
+	m, ok := handler.MinifiedBy(owner, act)
+	if pl == nil || !ok {
+		return
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.minified = append(pl.minified, m)
+}
+
+// printIn prints the object, printing its contained objects and states
+// through opts.pool.
+func (o *objConfig) printIn(l, x file.TextReader, opts printOpts) (J, error) {
 	out := o.data
+	pool := opts.pool
 
 	lh := handler.NewLuaHandler()
 	lh.Reader = l
+	lh.Lua = opts.lua
+	lh.SourceMap = opts.maps != nil
 	act, err := lh.WhileReadingFromFile(o.data)
 	if err != nil {
+		var se *handler.ScriptError
+		if errors.As(err, &se) && se.Inline {
+			se.File = o.srcFile
+		}
+		var ce *bundler.CycleError
+		if errors.As(err, &ce) {
+			ce.Owner = o.guid
+		}
 		return nil, fmt.Errorf("WhileReadingFromFile(): %v", err)
 	}
+	if act.SourceMap != nil {
+		for i := range act.SourceMap.Segments {
+			if act.SourceMap.Segments[i].Inline {
+				act.SourceMap.Segments[i].File = o.srcFile
+			}
+		}
+		opts.maps.Add(o.guid, act.SourceMap)
+	}
+	handler.LogMinified(o.guid, act)
+	opts.log.minify(o.guid, act)
 	if !act.Noop {
 		delete(out, "LuaScript")
 		delete(out, "LuaScript_path")
@@ -170,10 +295,22 @@ func (o *objConfig) print(l, x file.TextReader) (J, error) {
 	}
 	xh := handler.NewXMLHandler()
 	xh.Reader = x
+	xh.XML = opts.xml
 	act, err = xh.WhileReadingFromFile(o.data)
 	if err != nil {
+		var ce *bundler.CycleError
+		if errors.As(err, &ce) {
+			ce.Owner = o.guid
+		}
 		return nil, fmt.Errorf("WhileReadingFromFile(): %v", err)
 	}
+	for i := range act.Warnings {
+		if act.Warnings[i].Inline {
+			act.Warnings[i].File = o.srcFile
+		}
+	}
+	opts.xml.Report(act.Warnings)
+	opts.log.warn(act.Warnings)
 	if !act.Noop {
 		delete(out, "XmlUI")
 		delete(out, "XmlUI_path")
@@ -195,25 +332,38 @@ func (o *objConfig) print(l, x file.TextReader) (J, error) {
 		out["LuaScriptState"] = encoded
 	}
 
-	subs := []J{}
-	for _, sub := range o.subObj {
-		printed, err := sub.print(l, x)
+	subs := make([]J, len(o.subObj))
+	err = pool.each(len(o.subObj), func(i int) error {
+		printed, err := o.subObj[i].printIn(l, x, opts)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		subs = append(subs, printed)
+		subs[i] = printed
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	if len(subs) > 0 {
 		out["ContainedObjects"] = subs
 	}
 
-	s := map[string]J{}
-	for name, state := range o.states {
-		printed, err := state.print(l, x)
+	stateNames := sortedKeys(o.states)
+	printedStates := make([]J, len(stateNames))
+	err = pool.each(len(stateNames), func(i int) error {
+		printed, err := o.states[stateNames[i]].printIn(l, x, opts)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		s[name] = printed
+		printedStates[i] = printed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := map[string]J{}
+	for i, name := range stateNames {
+		s[name] = printedStates[i]
 	}
 	if len(s) > 0 {
 		out["States"] = s
@@ -228,6 +378,7 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 	lh := handler.NewLuaHandler()
 	lh.DefaultWriter = p.Lua
 	lh.SrcWriter = p.LuaSrc
+	lh.InlineLimit = p.InlineLimit
 
 	maybeNeededFname := path.Join(filepath, o.getAGoodFileName()+".ttslua")
 	act, err := lh.WhileWritingToFile(o.data, maybeNeededFname)
@@ -243,6 +394,7 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 	xh := handler.NewXMLHandler()
 	xh.DefaultWriter = p.XML
 	xh.SrcWriter = p.XMLSrc
+	xh.InlineLimit = p.InlineLimit
 	maybeNeededFname = path.Join(filepath, o.getAGoodFileName()+".xml")
 	act, err = xh.WhileWritingToFile(o.data, maybeNeededFname)
 	if err != nil {
@@ -256,7 +408,7 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 
 	if rawscript, ok := o.data["LuaScriptState"]; ok {
 		if script, ok := rawscript.(string); ok {
-			if len(script) > 80 {
+			if len(script) > p.inlineLimit() {
 				createdFile := path.Join(filepath, o.getAGoodFileName()+".luascriptstate")
 				out["LuaScriptState_path"] = createdFile
 
@@ -280,7 +432,7 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 	}
 	if rawscript, ok := o.data["GMNotes"]; ok {
 		if script, ok := rawscript.(string); ok {
-			if len(script) > 80 {
+			if len(script) > p.inlineLimit() {
 				createdFile := path.Join(filepath, o.getAGoodFileName()+".gmnotes")
 				o.data["GMNotes_path"] = createdFile
 				if err := p.Lua.EncodeToFile(script, createdFile); err != nil {
@@ -293,20 +445,28 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 
 	// recurse if need be
 	if len(o.subObj) > 0 || len(o.states) > 0 {
-		subdirName, err := p.Dir.CreateDir(filepath, o.getAGoodFileName())
+		// a directory kept from an existing objects directory is reused
+		suggestion := o.subObjDir
+		if suggestion == "" {
+			suggestion = o.getAGoodFileName()
+		}
+		subdirName, err := p.Dir.CreateDir(filepath, suggestion)
 		if err != nil {
-			return fmt.Errorf("<%v>.CreateDir(%s, %s) : %v", o.guid, filepath, o.getAGoodFileName(), err)
+			return fmt.Errorf("<%v>.CreateDir(%s, %s) : %v", o.guid, filepath, suggestion, err)
 		}
 		out["ContainedObjects_path"] = subdirName
 		o.subObjDir = subdirName
 	}
 
 	if len(o.subObj) > 0 {
-		for _, subo := range o.subObj {
-			err = subo.printToFile(path.Join(filepath, o.subObjDir), p)
-			if err != nil {
+		err = p.workers().each(len(o.subObj), func(i int) error {
+			if err := o.subObj[i].printToFile(path.Join(filepath, o.subObjDir), p); err != nil {
 				return fmt.Errorf("printing file %s: %v", path.Join(filepath, o.subObjDir), err)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 		if len(o.subObj) != len(o.subObjOrder) {
 			return fmt.Errorf("subobj order not getting filled in on %s", o.getAGoodFileName())
@@ -315,11 +475,15 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 	}
 
 	if len(o.states) > 0 {
-		for _, state := range o.states {
-			err = state.printToFile(path.Join(filepath, o.subObjDir), p)
-			if err != nil {
+		stateNames := sortedKeys(o.states)
+		err = p.workers().each(len(stateNames), func(i int) error {
+			if err := o.states[stateNames[i]].printToFile(path.Join(filepath, o.subObjDir), p); err != nil {
 				return fmt.Errorf("printing file %s: %v", path.Join(filepath, o.subObjDir), err)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 		if len(o.stateNames) != len(o.states) {
 			return fmt.Errorf("sub state mismatch for %s", o.getAGoodFileName())
@@ -329,10 +493,14 @@ func (o *objConfig) printToFile(filepath string, p *Printer) error {
 
 	// print self
 	fname := path.Join(filepath, o.getAGoodFileName()+".json")
+	o.file = fname
 	return p.J.WriteObj(out, fname)
 }
 
 func (o *objConfig) getAGoodFileName() string {
+	if o.name != "" {
+		return o.name
+	}
 	// This allows any letter or number from any language, plus _, -, and !
 	reg := regexp.MustCompile(`[^\p{L}\p{N}_!-]+`)
 
@@ -385,27 +553,111 @@ type db struct {
 
 	j   file.JSONReader
 	dir file.DirExplorer
+
+	// Only used when building with a cache: l and x validate cache entries,
+	// cached holds root objects reused from a previous build, and pending
+	// remembers how to record a freshly parsed root once it has printed.
+	cache   *BuildCache
+	l, x    file.TextReader
+	cached  map[string]J
+	pending map[string]pendingEntry
+
+	// nil means parse and print sequentially
+	pool *workPool
+	lua  handler.LuaOptions
+	xml  handler.XMLOptions
+	maps *bundler.SourceMaps
+}
+
+// pendingEntry is a root object that missed the cache and will be stored in it
+// after printing.
+type pendingEntry struct {
+	file string
+	rec  *depRecorder
 }
 
 func (d *db) print(l file.TextReader, x file.TextReader, order []string) (ObjArray, error) {
-	var oa ObjArray
-	if len(order) != len(d.root) {
-		return nil, fmt.Errorf("expected order (%v) and db.root (%v) to have same length", len(order), len(d.root))
+	if len(order) != len(d.root)+len(d.cached) {
+		return nil, fmt.Errorf("expected order (%v) and db.root (%v) to have same length", len(order), len(d.root)+len(d.cached))
 	}
 	for _, nextGUID := range order {
-		if _, ok := d.root[nextGUID]; !ok {
+		_, isCached := d.cached[nextGUID]
+		if _, ok := d.root[nextGUID]; !ok && !isCached {
 			return nil, fmt.Errorf("order expected %s, not found in db <%v>", nextGUID, d.root)
 		}
-		printed, err := d.root[nextGUID].print(l, x)
+	}
+
+	oa := make(ObjArray, len(order))
+	err := d.pool.each(len(order), func(i int) error {
+		nextGUID := order[i]
+		if out, ok := d.cached[nextGUID]; ok {
+			oa[i] = out
+			return nil
+		}
+		ol, ox := l, x
+		pe, recording := d.pending[nextGUID]
+		if recording {
+			ol, ox = pe.rec.text(luaDep, l), pe.rec.text(xmlDep, x)
+		}
+		opts := printOpts{pool: d.pool, lua: d.lua, xml: d.xml}
+		if recording {
+			opts.log = &printLog{}
+		}
+		if d.maps != nil {
+			// kept per root so that they can be cached along with it
+			opts.maps = bundler.NewSourceMaps()
+		}
+		printed, err := d.root[nextGUID].printIn(ol, ox, opts)
 		if err != nil {
-			return ObjArray{}, fmt.Errorf("obj (%s) did not print : %v", nextGUID, err)
+			return fmt.Errorf("obj (%s) did not print : %v", nextGUID, err)
+		}
+		var maps map[string]*bundler.SourceMap
+		if opts.maps != nil {
+			d.maps.Merge(opts.maps)
+			maps = opts.maps.All()
+		}
+		if recording {
+			if err := d.cache.store(pe.file, nextGUID, pe.rec, printed, maps, opts.log); err != nil {
+				return fmt.Errorf("caching obj (%s) : %v", nextGUID, err)
+			}
 		}
-		oa = append(oa, printed)
+		oa[i] = printed
+		return nil
+	})
+	if err != nil {
+		return ObjArray{}, err
+	}
+	if len(oa) == 0 {
+		// keep returning nil for an empty mod, as callers substitute their own
+		// empty array
+		return nil, nil
 	}
-
 	return oa, nil
 }
 
+// Parser holds the readers used to assemble object states, because parameter
+// lists are rough.
+type Parser struct {
+	Lua file.TextReader
+	XML file.TextReader
+	J   file.JSONReader
+	Dir file.DirExplorer
+
+	// If set: root objects whose files are unchanged since the previous build
+	// are reused from the cache instead of being parsed and bundled again.
+	Cache *BuildCache
+	// Jobs is how many objects may be parsed and printed concurrently; values
+	// below 2 keep everything sequential.
+	Jobs int
+	// LuaOptions apply to every object's LuaScript
+	LuaOptions handler.LuaOptions
+	// XMLOptions apply to every object's XmlUI
+	XMLOptions handler.XMLOptions
+	// If set: receives the source map of every object's LuaScript, keyed by
+	// GUID
+	SourceMaps *bundler.SourceMaps
+}
+
 // ParseAllObjectStates looks at a folder and creates a json map from it.
 // It assumes that folder names under the 'objects' directory are valid guids
 // of existing Objects.
@@ -417,16 +669,64 @@ func (d *db) print(l file.TextReader, x file.TextReader, order []string) (ObjArr
 //
 //	--baz.json (guid=999) << this is a child of bar.json
 func ParseAllObjectStates(l file.TextReader, x file.TextReader, j file.JSONReader, dir file.DirExplorer, order []string) ([]map[string]interface{}, error) {
+	p := &Parser{Lua: l, XML: x, J: j, Dir: dir}
+	return p.ParseAllObjectStates(order)
+}
+
+// ParseAllObjectStates behaves like the package level ParseAllObjectStates,
+// using the readers and options held by p.
+func (p *Parser) ParseAllObjectStates(order []string) ([]map[string]interface{}, error) {
 	d := db{
-		j:    j,
-		dir:  dir,
-		root: map[string]*objConfig{},
+		j:       p.J,
+		dir:     p.Dir,
+		root:    map[string]*objConfig{},
+		cache:   p.Cache,
+		l:       p.Lua,
+		x:       p.XML,
+		cached:  map[string]J{},
+		pending: map[string]pendingEntry{},
+		pool:    newWorkPool(p.Jobs),
+		lua:     p.LuaOptions,
+		xml:     p.XMLOptions,
+		maps:    p.SourceMaps,
 	}
 	err := d.parseFromFolder("")
 	if err != nil {
 		return []map[string]interface{}{}, fmt.Errorf("parseFolder(%s): %v", "<root>", err)
 	}
-	return d.print(l, x, order)
+	return d.print(p.Lua, p.XML, order)
+}
+
+// parseTree parses every root object under the objects directory, without
+// bundling any of their scripts.
+func (p *Parser) parseTree() ([]*objConfig, error) {
+	d := db{
+		j:       p.J,
+		dir:     p.Dir,
+		root:    map[string]*objConfig{},
+		cached:  map[string]J{},
+		pending: map[string]pendingEntry{},
+		pool:    newWorkPool(p.Jobs),
+	}
+	if err := d.parseFromFolder(""); err != nil {
+		return nil, fmt.Errorf("parseFolder(%s): %v", "<root>", err)
+	}
+	roots := []*objConfig{}
+	for _, name := range sortedKeys(d.root) {
+		roots = append(roots, d.root[name])
+	}
+	return roots, nil
+}
+
+// walk calls fn with o and every object contained in it or one of its states.
+func (o *objConfig) walk(fn func(*objConfig)) {
+	fn(o)
+	for _, sub := range o.subObj {
+		sub.walk(fn)
+	}
+	for _, name := range sortedKeys(o.states) {
+		o.states[name].walk(fn)
+	}
 }
 
 func (d *db) parseFromFolder(relpath string) error {
@@ -435,19 +735,66 @@ func (d *db) parseFromFolder(relpath string) error {
 		return fmt.Errorf("ListFilesAndFolders(%s) : %v", relpath, err)
 	}
 
+	jsonFiles := []string{}
 	for _, file := range filenames {
-		if !strings.HasSuffix(file, ".json") {
-			// expect luascriptstate, gmnotes, and ttslua files to be stored alongside
-			continue
+		// expect luascriptstate, gmnotes, and ttslua files to be stored alongside
+		if strings.HasSuffix(file, ".json") {
+			jsonFiles = append(jsonFiles, file)
+		}
+	}
+
+	// each root is parsed into its own slot and merged afterwards, so the db
+	// maps are only ever written from this goroutine
+	type parsed struct {
+		o      *objConfig
+		rec    *depRecorder
+		name   string
+		cached J
+	}
+	results := make([]parsed, len(jsonFiles))
+	err = d.pool.each(len(jsonFiles), func(i int) error {
+		file := jsonFiles[i]
+		j := d.j
+		var rec *depRecorder
+		if d.cache != nil {
+			if out, e, ok := d.cache.lookup(file, d.l, d.x, d.j); ok {
+				if d.maps != nil {
+					for owner, m := range e.Maps {
+						d.maps.Add(owner, m)
+					}
+				}
+				// reported again, as a build reports them every time
+				for _, m := range e.Minified {
+					log.Print(m)
+				}
+				d.xml.Report(e.Warnings)
+				results[i] = parsed{name: e.Name, cached: out}
+				return nil
+			}
+			rec = newDepRecorder()
+			j = rec.json(d.j)
 		}
 		var o objConfig
-		err := o.parseFromFile(file, d.j)
-		if err != nil {
+		if err := o.parseFromFileIn(file, j, d.pool); err != nil {
 			return fmt.Errorf("parseFromFile(%s): %v", file, err)
 		}
-		d.root[o.getAGoodFileName()] = &o
+		results[i] = parsed{o: &o, rec: rec, name: o.getAGoodFileName()}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	for i, r := range results {
+		if r.cached != nil {
+			d.cached[r.name] = r.cached
+			continue
+		}
+		d.root[r.name] = r.o
+		if r.rec != nil {
+			d.pending[r.name] = pendingEntry{file: jsonFiles[i], rec: r.rec}
+		}
+	}
 	return nil
 }
 
@@ -459,6 +806,35 @@ type Printer struct {
 	XMLSrc file.TextWriter
 	J      file.JSONWriter
 	Dir    file.DirCreator
+
+	// Jobs is how many objects may be written concurrently; values below 2
+	// keep everything sequential.
+	Jobs int
+	// InlineLimit overrides handler.DefaultInlineLimit when non-zero
+	InlineLimit int
+	// If set: objects keep the file names and directories of the objects with
+	// the same GUID in it
+	Keep *Layout
+
+	// printed are the root objects of the last PrintObjectStates
+	printed []*objConfig
+
+	poolOnce sync.Once
+	pool     *workPool
+}
+
+func (p *Printer) inlineLimit() int {
+	if p.InlineLimit != 0 {
+		return p.InlineLimit
+	}
+	return handler.DefaultInlineLimit
+}
+
+func (p *Printer) workers() *workPool {
+	p.poolOnce.Do(func() {
+		p.pool = newWorkPool(p.Jobs)
+	})
+	return p.pool
 }
 
 // PrintObjectStates takes a list of json objects and prints them in the
@@ -480,11 +856,25 @@ func (p *Printer) PrintObjectStates(root string, objs []map[string]interface{})
 		return nil, fmt.Errorf("root objects: %v", err)
 	}
 
+	// roots are ordered by the names made from their Nickname whatever the
+	// files they are kept in, as that is how ParseAllObjectStates knows them
 	for _, oc := range ocs {
 		order = append(order, oc.getAGoodFileName())
-		if err := oc.printToFile(root, p); err != nil {
+	}
+	if p.Keep != nil {
+		if err := p.Keep.place(ocs); err != nil {
 			return nil, err
 		}
+		if err := checkFilenameCollisions(ocs); err != nil {
+			return nil, fmt.Errorf("root objects: %v", err)
+		}
+	}
+	err := p.workers().each(len(ocs), func(i int) error {
+		return ocs[i].printToFile(root, p)
+	})
+	if err != nil {
+		return nil, err
 	}
+	p.printed = ocs
 	return order, nil
 }
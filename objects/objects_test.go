@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
 
+	"ModCreator/handler"
 	"ModCreator/tests"
 	"ModCreator/types"
 
@@ -691,3 +693,69 @@ func TestParseFromFile(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintReportsScriptFile(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Data["Box.123.json"] = types.J{
+		"GUID":             "123",
+		"ContainedObjects": []interface{}{map[string]interface{}{"GUID": "456", "LuaScript": "if x then"}},
+	}
+	o := objConfig{}
+	if err := o.parseFromFile("Box.123.json", ff); err != nil {
+		t.Fatalf("parseFromFile(): %v", err)
+	}
+	_, err := o.print(ff, ff)
+	if err == nil {
+		t.Fatalf("print(): wanted a syntax error, got none")
+	}
+	// the contained object is kept inline, so its parent's file holds it
+	if want := "Box.123.json (inline script):1:10: 'end' expected near '<eof>'"; !strings.Contains(err.Error(), want) {
+		t.Errorf("print() error %q does not contain %q", err, want)
+	}
+
+	p := &Parser{Lua: ff, XML: ff, J: ff, Dir: ff, LuaOptions: handler.LuaOptions{SkipCheck: true}}
+	ff.Data = map[string]types.J{"Box.123.json": {"GUID": "123", "LuaScript": "if x then"}}
+	if _, err := p.ParseAllObjectStates([]string{"123"}); err != nil {
+		t.Errorf("ParseAllObjectStates() with SkipCheck: %v", err)
+	}
+}
+
+func TestPrintReportsRequireCycleOwner(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Fs["a.ttslua"] = "require('b')"
+	ff.Fs["b.ttslua"] = "require('a')"
+	ff.Data["Box.123.json"] = types.J{
+		"GUID":             "123",
+		"ContainedObjects": []interface{}{map[string]interface{}{"GUID": "456", "LuaScript": "require('a')"}},
+	}
+	o := objConfig{}
+	if err := o.parseFromFile("Box.123.json", ff); err != nil {
+		t.Fatalf("parseFromFile(): %v", err)
+	}
+	_, err := o.print(ff, ff)
+	want := "script of 456: circular require: a.ttslua -> b.ttslua -> a.ttslua"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("print() error %v does not contain %q", err, want)
+	}
+}
+
+func TestPrintReportsXMLWarnings(t *testing.T) {
+	ff := tests.NewFF()
+	ff.Data["Box.123.json"] = types.J{
+		"GUID":  "123",
+		"XmlUI": "<Panel>\n  <Text fontsize=\"20\"/>\n</Panel>",
+	}
+	o := objConfig{}
+	if err := o.parseFromFile("Box.123.json", ff); err != nil {
+		t.Fatalf("parseFromFile(): %v", err)
+	}
+	got := []string{}
+	opts := printOpts{xml: handler.XMLOptions{Warn: func(w handler.XMLWarning) { got = append(got, w.String()) }}}
+	if _, err := o.printIn(ff, ff, opts); err != nil {
+		t.Fatalf("printIn(): %v", err)
+	}
+	want := []string{"Box.123.json (inline XmlUI):2: <Text> has no attribute fontsize, did you mean fontSize?"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
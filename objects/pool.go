@@ -0,0 +1,59 @@
+package objects
+
+import "sync"
+
+// workPool bounds how many goroutines parse or print objects at once. Work that
+// can't get a slot runs inline on the calling goroutine, so nested use (an
+// object printing its contained objects while holding a slot) can never
+// deadlock waiting on its own parent.
+type workPool struct {
+	sem chan struct{}
+}
+
+// newWorkPool returns a pool allowing jobs concurrent workers, or nil (meaning
+// strictly sequential) when jobs is less than 2.
+func newWorkPool(jobs int) *workPool {
+	if jobs < 2 {
+		return nil
+	}
+	// the calling goroutine is always a worker, so it doesn't need a slot
+	return &workPool{sem: make(chan struct{}, jobs-1)}
+}
+
+// each calls fn for every index in [0, n). Callers write results into slots of
+// a pre-sized slice so output order never depends on scheduling. The error
+// returned is the one from the lowest failing index, matching what a sequential
+// run would report.
+func (p *workPool) each(n int, fn func(i int) error) error {
+	if p == nil || n < 2 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case p.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-p.sem }()
+				errs[i] = fn(i)
+			}(i)
+		default:
+			errs[i] = fn(i)
+		}
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
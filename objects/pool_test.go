@@ -0,0 +1,146 @@
+package objects
+
+import (
+	"ModCreator/tests"
+	"ModCreator/types"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWorkPoolEach(t *testing.T) {
+	for _, jobs := range []int{0, 1, 2, 8} {
+		t.Run(fmt.Sprint(jobs), func(t *testing.T) {
+			p := newWorkPool(jobs)
+			got := make([]int, 50)
+			err := p.each(len(got), func(i int) error {
+				got[i] = i * i
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("each(): %v", err)
+			}
+			for i, v := range got {
+				if v != i*i {
+					t.Fatalf("slot %d = %d, want %d", i, v, i*i)
+				}
+			}
+		})
+	}
+}
+
+// TestWorkPoolFirstError expects the error of the lowest failing index, so a
+// parallel run reports the same failure a sequential run would.
+func TestWorkPoolFirstError(t *testing.T) {
+	p := newWorkPool(4)
+	err := p.each(20, func(i int) error {
+		if i == 7 || i == 15 {
+			return fmt.Errorf("fail %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "fail 7" {
+		t.Errorf("each() = %v, want fail 7", err)
+	}
+}
+
+// TestWorkPoolNested would deadlock if nested work waited for a free slot.
+func TestWorkPoolNested(t *testing.T) {
+	p := newWorkPool(2)
+	var count int32
+	err := p.each(10, func(i int) error {
+		return p.each(10, func(j int) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("each(): %v", err)
+	}
+	if count != 100 {
+		t.Errorf("ran %d tasks, want 100", count)
+	}
+}
+
+// TestParallelMatchesSequential builds a tree of bags and states with and
+// without concurrency and expects identical ordered output.
+func TestParallelMatchesSequential(t *testing.T) {
+	j := tests.NewFF()
+	order := []string{}
+	for b := 0; b < 10; b++ {
+		bag := fmt.Sprintf("Bag%d.b%05d", b, b)
+		order = append(order, bag)
+		cards := []string{}
+		for c := 0; c < 10; c++ {
+			card := fmt.Sprintf("Card%d.c%02d%03d", c, b, c)
+			cards = append(cards, card)
+			j.Data[bag+"/"+card+".json"] = types.J{
+				"GUID":     fmt.Sprintf("c%02d%03d", b, c),
+				"Nickname": fmt.Sprintf("Card%d", c),
+			}
+		}
+		j.Data[bag+".json"] = types.J{
+			"GUID":                   fmt.Sprintf("b%05d", b),
+			"Nickname":               fmt.Sprintf("Bag%d", b),
+			"ContainedObjects_path":  bag,
+			"ContainedObjects_order": cards,
+			"States_path":            map[string]string{"2": cards[0]},
+		}
+	}
+
+	build := func(jobs int) string {
+		p := &Parser{Lua: j, XML: j, J: j, Dir: j, Jobs: jobs}
+		got, err := p.ParseAllObjectStates(order)
+		if err != nil {
+			t.Fatalf("ParseAllObjectStates(jobs=%d): %v", jobs, err)
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("Marshal(): %v", err)
+		}
+		return string(b)
+	}
+	want := build(1)
+	if got := build(8); got != want {
+		t.Errorf("parallel output differs:\n%v", cmp.Diff(want, got))
+	}
+}
+
+func TestPrintObjectStatesParallel(t *testing.T) {
+	objs := []map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		objs = append(objs, map[string]interface{}{
+			"GUID":     fmt.Sprintf("%06d", i),
+			"Nickname": fmt.Sprintf("Obj%d", i),
+			"ContainedObjects": []interface{}{
+				map[string]interface{}{"GUID": fmt.Sprintf("c%05d", i)},
+			},
+		})
+	}
+	write := func(jobs int) (*tests.FakeFiles, []string) {
+		ff := tests.NewFF()
+		p := &Printer{Lua: ff, LuaSrc: ff, XML: ff, XMLSrc: ff, J: ff, Dir: ff, Jobs: jobs}
+		// PrintObjectStates consumes its input, so give each run its own copy
+		b, _ := json.Marshal(objs)
+		var in []map[string]interface{}
+		if err := json.Unmarshal(b, &in); err != nil {
+			t.Fatalf("Unmarshal(): %v", err)
+		}
+		order, err := p.PrintObjectStates("", in)
+		if err != nil {
+			t.Fatalf("PrintObjectStates(jobs=%d): %v", jobs, err)
+		}
+		return ff, order
+	}
+	wantFF, wantOrder := write(1)
+	gotFF, gotOrder := write(8)
+	if diff := cmp.Diff(wantOrder, gotOrder); diff != "" {
+		t.Errorf("order differs:\n%v", diff)
+	}
+	if diff := cmp.Diff(wantFF.Data, gotFF.Data); diff != "" {
+		t.Errorf("written files differ:\n%v", diff)
+	}
+}
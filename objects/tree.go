@@ -0,0 +1,133 @@
+package objects
+
+import (
+	. "ModCreator/types"
+	"fmt"
+	"sort"
+)
+
+// Node is an object of a mod, without its contained objects and states, and
+// where it is in the mod.
+type Node struct {
+	GUID string
+	// Data are the fields of the object but ContainedObjects and States
+	Data J
+	// Parent is the key of the object containing it, empty for a root
+	Parent string
+	// State is the state it is of its parent, empty for a contained object
+	State string
+}
+
+// Name is the Nickname of the object, or its Name when it has none.
+func (n *Node) Name() string {
+	for _, k := range []string{"Nickname", "Name"} {
+		if s, ok := n.Data[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Tree is every object of a mod keyed by GUID; the second and later objects
+// with a GUID are keyed GUID#2 and so on, so that objects of two mods are
+// matched by key.
+type Tree struct {
+	Nodes map[string]*Node
+	// Keys are those of Nodes in the order of the mod
+	Keys []string
+	// Order are the keys of the roots, under "", and of the contained objects
+	// of each object, in order
+	Order map[string][]string
+}
+
+// NewTree takes the objects of a mod apart into a Tree. The Data of its nodes
+// share their values with objs.
+func NewTree(objs []map[string]interface{}) (*Tree, error) {
+	t := &Tree{Nodes: map[string]*Node{}, Order: map[string][]string{}}
+	seen := map[string]int{}
+	var add func(o map[string]interface{}, parent, state string) (string, error)
+	add = func(o map[string]interface{}, parent, state string) (string, error) {
+		guid, ok := o["GUID"].(string)
+		if !ok {
+			return "", fmt.Errorf("some object doesn't have a string for a Guid: %v", o["GUID"])
+		}
+		key := guid
+		if seen[guid]++; seen[guid] > 1 {
+			key = fmt.Sprintf("%s#%d", guid, seen[guid])
+		}
+		data := J{}
+		for k, v := range o {
+			if k != "ContainedObjects" && k != "States" {
+				data[k] = v
+			}
+		}
+		t.Nodes[key] = &Node{GUID: guid, Data: data, Parent: parent, State: state}
+		t.Keys = append(t.Keys, key)
+		if subs, ok := o["ContainedObjects"]; ok {
+			arr, err := ConvertToObjArray(subs)
+			if err != nil {
+				return "", fmt.Errorf("ContainedObjects of %s: %v", guid, err)
+			}
+			for _, sub := range arr {
+				k, err := add(sub, key, "")
+				if err != nil {
+					return "", err
+				}
+				t.Order[key] = append(t.Order[key], k)
+			}
+		}
+		if states, ok := o["States"].(map[string]interface{}); ok {
+			ids := make([]string, 0, len(states))
+			for id := range states {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				st, ok := states[id].(map[string]interface{})
+				if !ok {
+					return "", fmt.Errorf("state %s of %s is not a json object", id, guid)
+				}
+				if _, err := add(st, key, id); err != nil {
+					return "", err
+				}
+			}
+		}
+		return key, nil
+	}
+	for _, o := range objs {
+		k, err := add(o, "", "")
+		if err != nil {
+			return nil, err
+		}
+		t.Order[""] = append(t.Order[""], k)
+	}
+	return t, nil
+}
+
+// SameOrder tells whether the keys a and b have in common are in the same
+// order in both.
+func SameOrder(a, b []string) bool {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, k := range a {
+		inA[k] = true
+	}
+	for _, k := range b {
+		inB[k] = true
+	}
+	i, j := 0, 0
+	for {
+		for i < len(a) && !inB[a[i]] {
+			i++
+		}
+		for j < len(b) && !inA[b[j]] {
+			j++
+		}
+		if i == len(a) || j == len(b) {
+			return i == len(a) && j == len(b)
+		}
+		if a[i] != b[j] {
+			return false
+		}
+		i, j = i+1, j+1
+	}
+}
@@ -0,0 +1,57 @@
+package objects
+
+import (
+	. "ModCreator/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewTree(t *testing.T) {
+	objs := []map[string]interface{}{
+		{"GUID": "a", "Nickname": "bag", "ContainedObjects": []interface{}{
+			map[string]interface{}{"GUID": "b"},
+			map[string]interface{}{"GUID": "b", "Name": "Card"},
+		}},
+		{"GUID": "c", "States": map[string]interface{}{
+			"2": map[string]interface{}{"GUID": "d"},
+		}},
+	}
+	got, err := NewTree(objs)
+	if err != nil {
+		t.Fatalf("NewTree(): %v", err)
+	}
+	want := &Tree{
+		Nodes: map[string]*Node{
+			"a":   {GUID: "a", Data: J{"GUID": "a", "Nickname": "bag"}},
+			"b":   {GUID: "b", Data: J{"GUID": "b"}, Parent: "a"},
+			"b#2": {GUID: "b", Data: J{"GUID": "b", "Name": "Card"}, Parent: "a"},
+			"c":   {GUID: "c", Data: J{"GUID": "c"}},
+			"d":   {GUID: "d", Data: J{"GUID": "d"}, Parent: "c", State: "2"},
+		},
+		Keys:  []string{"a", "b", "b#2", "c", "d"},
+		Order: map[string][]string{"": {"a", "c"}, "a": {"b", "b#2"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	if n := got.Nodes["b#2"].Name(); n != "Card" {
+		t.Errorf("want name Card, got %q", n)
+	}
+}
+
+func TestSameOrder(t *testing.T) {
+	for _, tc := range []struct {
+		a, b []string
+		want bool
+	}{
+		{a: []string{"x", "y", "z"}, b: []string{"x", "z"}, want: true},
+		{a: []string{"x", "new", "y"}, b: []string{"x", "y", "other"}, want: true},
+		{a: []string{"x", "y"}, b: []string{"y", "x"}, want: false},
+		{a: nil, b: []string{"x"}, want: true},
+	} {
+		if got := SameOrder(tc.a, tc.b); got != tc.want {
+			t.Errorf("SameOrder(%v, %v): want %v, got %v", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
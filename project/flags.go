@@ -0,0 +1,15 @@
+package project
+
+import "strings"
+
+// StringList is a flag that may be repeated, collecting every value in order.
+type StringList []string
+
+func (s *StringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *StringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
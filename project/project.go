@@ -0,0 +1,232 @@
+// Package project describes the layout of a mod directory and how the tool is
+// configured for it. The defaults match the layout reverse has always written;
+// an optional ttsmm.json at the root of the mod directory can change them so
+// that the same settings don't have to be repeated on every invocation.
+package project
+
+import (
+	"ModCreator/file"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Filename is the name of the optional project file in a mod directory.
+const Filename = "ttsmm.json"
+
+// Project holds the settings read from a project file. Every field is optional;
+// command line flags override whatever is set here.
+type Project struct {
+	// Dirs are the source directories, relative to the mod directory.
+	Dirs Dirs `json:"dirs"`
+	// BonusDirs are extra directories whose src/ and xml/ subdirectories are
+	// searched for require and Include, in order, after the mod's own.
+	BonusDirs []string `json:"bonusDirs,omitempty"`
+	// LibPaths are extra directories searched directly for both require and
+	// Include, in order, after the bonus directories.
+	LibPaths []string `json:"libPaths,omitempty"`
+	// Output is where build writes the mod json.
+	Output string `json:"output,omitempty"`
+	// SavedObject wraps built output in the boiler plate TTS expects of a
+	// saved object.
+	SavedObject bool `json:"savedObject,omitempty"`
+	// SharedModules are patterns, as for path.Match, of the Lua modules
+	// bundled once into Global's script for every script requiring them.
+	SharedModules []string `json:"sharedModules,omitempty"`
+	// MinifyLua strips comments and redundant whitespace from every module of
+	// a bundled script.
+	MinifyLua bool `json:"minifyLua,omitempty"`
+	// AllowRequireCycles accepts Lua modules requiring each other in a circle
+	// when one of the requires is inside a function.
+	AllowRequireCycles bool `json:"allowRequireCycles,omitempty"`
+	// Jobs is how many objects to process concurrently; 0 means one per CPU.
+	Jobs int `json:"jobs,omitempty"`
+	// AssetMirror is a local copy of TTS's Mods directory, holding the
+	// downloaded Images, Models and so on that verify-assets looks for.
+	AssetMirror string `json:"assetMirror,omitempty"`
+	// Thresholds decide when reverse moves a value into its own file.
+	Thresholds Thresholds `json:"thresholds"`
+}
+
+// Dirs names the source directories of a mod.
+type Dirs struct {
+	Lua         string `json:"lua,omitempty"`
+	XML         string `json:"xml,omitempty"`
+	Objects     string `json:"objects,omitempty"`
+	ModSettings string `json:"modsettings,omitempty"`
+}
+
+// Thresholds are the sizes above which reverse writes a value to its own file
+// instead of keeping it inline. Zero means the built in default.
+type Thresholds struct {
+	// Script applies to LuaScript, XmlUI, LuaScriptState and GMNotes.
+	Script int `json:"script,omitempty"`
+	// ModSettingsObject applies to top level json objects such as Grid.
+	ModSettingsObject int `json:"modsettingsObject,omitempty"`
+	// ModSettingsArray applies to top level json arrays such as SnapPoints.
+	ModSettingsArray int `json:"modsettingsArray,omitempty"`
+}
+
+// Default returns the settings used when a mod directory has no project file.
+func Default() *Project {
+	return &Project{
+		Dirs: Dirs{
+			Lua:         "src",
+			XML:         "xml",
+			Objects:     "objects",
+			ModSettings: "modsettings",
+		},
+	}
+}
+
+// Load reads the project file in moddir, filling in defaults for anything it
+// leaves unset. A missing project file is not an error. Relative paths in the
+// file are resolved against moddir.
+func Load(moddir string) (*Project, error) {
+	p := Default()
+	b, err := os.ReadFile(filepath.Join(moddir, Filename))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", Filename, err)
+	}
+
+	var fromFile Project
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// a misspelled key would otherwise be silently ignored
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fromFile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filepath.Join(moddir, Filename), err)
+	}
+	p.merge(&fromFile, moddir)
+	return p, nil
+}
+
+func (p *Project) merge(o *Project, moddir string) {
+	setStr := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	setStr(&p.Dirs.Lua, o.Dirs.Lua)
+	setStr(&p.Dirs.XML, o.Dirs.XML)
+	setStr(&p.Dirs.Objects, o.Dirs.Objects)
+	setStr(&p.Dirs.ModSettings, o.Dirs.ModSettings)
+	for _, d := range o.BonusDirs {
+		p.BonusDirs = append(p.BonusDirs, resolve(moddir, d))
+	}
+	for _, d := range o.LibPaths {
+		p.LibPaths = append(p.LibPaths, resolve(moddir, d))
+	}
+	if o.Output != "" {
+		p.Output = resolve(moddir, o.Output)
+	}
+	p.SavedObject = p.SavedObject || o.SavedObject
+	p.MinifyLua = p.MinifyLua || o.MinifyLua
+	p.AllowRequireCycles = p.AllowRequireCycles || o.AllowRequireCycles
+	p.SharedModules = append(p.SharedModules, o.SharedModules...)
+	if o.Jobs != 0 {
+		p.Jobs = o.Jobs
+	}
+	if o.AssetMirror != "" {
+		p.AssetMirror = resolve(moddir, o.AssetMirror)
+	}
+	p.Thresholds = o.Thresholds
+}
+
+// AddSources adds the directories given on the command line to the search
+// paths for require and Include: bonusDir, if set, replaces BonusDirs, and
+// libPaths are searched before LibPaths.
+func (p *Project) AddSources(bonusDir string, libPaths []string) {
+	if bonusDir != "" {
+		p.BonusDirs = []string{bonusDir}
+	}
+	p.LibPaths = append(append([]string{}, libPaths...), p.LibPaths...)
+}
+
+func resolve(base, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(base, p)
+}
+
+// SubDirs lists every source directory, relative to the mod directory.
+func (p *Project) SubDirs() []string {
+	return []string{p.Dirs.Lua, p.Dirs.ModSettings, p.Dirs.Objects, p.Dirs.XML}
+}
+
+// Ops are the readers and writers for one mod directory.
+type Ops struct {
+	Lua, XML       *file.TextOps
+	LuaSrc, XMLSrc *file.TextOps
+	ModSettings    *file.JSONOps
+	Objs           *file.JSONOps
+	ObjDir         *file.DirOps
+	Root           *file.JSONOps
+}
+
+// LuaPaths is the Lua search path for require, in precedence order: the mod's
+// Lua and objects directories, each bonus directory's src/, then each library
+// path.
+func (p *Project) LuaPaths(moddir string) []string {
+	paths := []string{
+		filepath.Join(moddir, p.Dirs.Lua),
+		filepath.Join(moddir, p.Dirs.Objects),
+	}
+	return append(paths, p.sharedPaths("src")...)
+}
+
+// XMLPaths is the XML search path for Include, in precedence order: the mod's
+// XML and objects directories, each bonus directory's xml/, then each library
+// path.
+func (p *Project) XMLPaths(moddir string) []string {
+	paths := []string{
+		filepath.Join(moddir, p.Dirs.XML),
+		filepath.Join(moddir, p.Dirs.Objects),
+	}
+	return append(paths, p.sharedPaths("xml")...)
+}
+
+// sharedPaths are the search paths outside the mod directory, with bonusSub
+// naming the subdirectory used within each bonus directory.
+func (p *Project) sharedPaths(bonusSub string) []string {
+	paths := []string{}
+	for _, b := range p.BonusDirs {
+		paths = append(paths, filepath.Join(b, bonusSub))
+	}
+	return append(paths, p.LibPaths...)
+}
+
+// Ops creates the readers and writers for a full mod in moddir.
+func (p *Project) Ops(moddir string) Ops {
+	objects := filepath.Join(moddir, p.Dirs.Objects)
+	return Ops{
+		Lua:         file.NewTextOpsMulti(p.LuaPaths(moddir), objects),
+		XML:         file.NewTextOpsMulti(p.XMLPaths(moddir), objects),
+		LuaSrc:      file.NewTextOps(filepath.Join(moddir, p.Dirs.Lua)),
+		XMLSrc:      file.NewTextOps(filepath.Join(moddir, p.Dirs.XML)),
+		ModSettings: file.NewJSONOps(filepath.Join(moddir, p.Dirs.ModSettings)),
+		Objs:        file.NewJSONOps(objects),
+		ObjDir:      file.NewDirOps(objects),
+		Root:        file.NewJSONOps(moddir),
+	}
+}
+
+// ObjectOps creates the readers and writers for building or reversing the
+// single object in objin, writing scripts next to objout. The mod's own source
+// directories are still searched for require and Include.
+func (p *Project) ObjectOps(moddir, objin, objout string) Ops {
+	ops := p.Ops(moddir)
+	ops.Objs = file.NewJSONOps(filepath.Dir(objin))
+	ops.ObjDir = file.NewDirOps(filepath.Dir(objin))
+
+	luaPaths := append([]string{filepath.Join(moddir, p.Dirs.Lua)}, p.sharedPaths("src")...)
+	xmlPaths := append([]string{filepath.Join(moddir, p.Dirs.XML)}, p.sharedPaths("xml")...)
+	ops.Lua = file.NewTextOpsMulti(append(luaPaths, filepath.Dir(objin)), filepath.Dir(objout))
+	ops.XML = file.NewTextOpsMulti(append(xmlPaths, filepath.Dir(objin)), filepath.Dir(objout))
+	return ops
+}
@@ -0,0 +1,99 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadMissingIsDefault(t *testing.T) {
+	got, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if diff := cmp.Diff(Default(), got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	moddir := t.TempDir()
+	content := `{
+  "dirs": {"lua": "lua", "objects": "things"},
+  "bonusDirs": ["../shared", "/abs/lib"],
+  "libPaths": ["libs/a", "/abs/b"],
+  "output": "build/mod.json",
+  "savedObject": true,
+  "minifyLua": true,
+  "sharedModules": ["lib/*"],
+  "allowRequireCycles": true,
+  "jobs": 3,
+  "assetMirror": "../mirror/Mods",
+  "thresholds": {"script": 200}
+}`
+	if err := os.WriteFile(filepath.Join(moddir, Filename), []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+
+	want := &Project{
+		Dirs: Dirs{
+			Lua:         "lua",
+			XML:         "xml",
+			Objects:     "things",
+			ModSettings: "modsettings",
+		},
+		BonusDirs:          []string{filepath.Join(moddir, "../shared"), "/abs/lib"},
+		LibPaths:           []string{filepath.Join(moddir, "libs/a"), "/abs/b"},
+		Output:             filepath.Join(moddir, "build/mod.json"),
+		SavedObject:        true,
+		MinifyLua:          true,
+		SharedModules:      []string{"lib/*"},
+		AllowRequireCycles: true,
+		Jobs:               3,
+		AssetMirror:        filepath.Join(moddir, "../mirror/Mods"),
+		Thresholds:         Thresholds{Script: 200},
+	}
+	got, err := Load(moddir)
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+
+	wantLua := []string{
+		filepath.Join(moddir, "lua"),
+		filepath.Join(moddir, "things"),
+		filepath.Join(moddir, "../shared", "src"),
+		filepath.Join("/abs/lib", "src"),
+		filepath.Join(moddir, "libs/a"),
+		"/abs/b",
+	}
+	if diff := cmp.Diff(wantLua, got.LuaPaths(moddir)); diff != "" {
+		t.Errorf("LuaPaths() want != got:\n%v\n", diff)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	moddir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(moddir, Filename), []byte(`{"bonusdir": "x"}`), 0644); err != nil {
+		t.Fatalf("WriteFile(): %v", err)
+	}
+	if _, err := Load(moddir); err == nil {
+		t.Errorf("Load() with a misspelled key: wanted error, got nil")
+	}
+}
+
+func TestAddSources(t *testing.T) {
+	p := &Project{BonusDirs: []string{"bonus"}, LibPaths: []string{"lib"}}
+	p.AddSources("", []string{"cli"})
+	if diff := cmp.Diff(&Project{BonusDirs: []string{"bonus"}, LibPaths: []string{"cli", "lib"}}, p); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+	p.AddSources("other", nil)
+	if diff := cmp.Diff([]string{"other"}, p.BonusDirs); diff != "" {
+		t.Errorf("want != got:\n%v\n", diff)
+	}
+}
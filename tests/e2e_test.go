@@ -4,6 +4,7 @@ import (
 	"ModCreator/bundler"
 	"ModCreator/file"
 	"ModCreator/mod"
+	"ModCreator/objects"
 	"path/filepath"
 	"testing"
 
@@ -135,7 +136,7 @@ func TestAllReverseThenBuild(t *testing.T) {
 			// positions to 3dp, scale to 2dp, and colors to 5dp, so an absolute
 			// tolerance of 1e-4 comfortably absorbs rounding noise while still
 			// catching any real numeric corruption in the round trip.
-			if diff := cmp.Diff(want, got, cmpopts.IgnoreMapEntries(ignoreUnpredictable), cmpopts.EquateApprox(0, 1e-4)); diff != "" {
+			if diff := cmp.Diff(want, got, cmpopts.IgnoreMapEntries(ignoreUnpredictable), objects.ApproxFloats); diff != "" {
 				t.Errorf("want != got:\n%v\n", diff)
 			}
 		})
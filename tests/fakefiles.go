@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"sync"
 )
 
-// FakeFiles allows for mocking of the file system to use in tests
+// FakeFiles allows for mocking of the file system to use in tests. Its methods
+// are safe for concurrent use; the maps themselves are not, so tests should only
+// touch them directly while nothing else is running.
 type FakeFiles struct {
 	Fs   map[string]string
 	Data map[string]types.J
+
+	mu sync.Mutex
 }
 
 // NewFF helps you not forget to initialize maps
@@ -24,6 +29,8 @@ func NewFF() *FakeFiles {
 
 // EncodeFromFile satisfies LuaReader
 func (f *FakeFiles) EncodeFromFile(s string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if _, ok := f.Fs[s]; !ok {
 		return "", fmt.Errorf("fake file <%s> not found", s)
 	}
@@ -32,6 +39,8 @@ func (f *FakeFiles) EncodeFromFile(s string) (string, error) {
 
 // ReadObj satisfies JSONReader
 func (f *FakeFiles) ReadObj(s string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if _, ok := f.Data[s]; !ok {
 		// Match the real JSONOps.ReadObj, which returns a non-nil empty map
 		// alongside the error so callers that ignore the error don't panic on
@@ -52,6 +61,8 @@ func (f *FakeFiles) ReadObj(s string) (map[string]interface{}, error) {
 
 // ReadObjArray satisfies JSONReader
 func (f *FakeFiles) ReadObjArray(s string) ([]map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	wrapper, ok := f.Data[s]
 	if !ok {
 		return nil, fmt.Errorf("fake file <%s> not found", s)
@@ -69,12 +80,16 @@ func (f *FakeFiles) ReadObjArray(s string) ([]map[string]interface{}, error) {
 
 // WriteObj satisfies JSONWriter
 func (f *FakeFiles) WriteObj(data map[string]interface{}, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.Data[path] = data
 	return nil
 }
 
 // WriteObjArray satisfies JSONWriter
 func (f *FakeFiles) WriteObjArray(data []map[string]interface{}, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.Data[path] = types.J{
 		"testarray": data,
 	}
@@ -83,6 +98,8 @@ func (f *FakeFiles) WriteObjArray(data []map[string]interface{}, path string) er
 
 // WriteSavedObj satisfies JSONWriter and mimics the behavior of the real WriteSavedObj.
 func (f *FakeFiles) WriteSavedObj(data map[string]interface{}, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	savedObject := map[string]interface{}{
 		"SaveName":       "",
 		"Date":           "",
@@ -109,23 +126,40 @@ func (f *FakeFiles) WriteSavedObj(data map[string]interface{}, path string) erro
 
 // EncodeToFile satisfies LuaWriter
 func (f *FakeFiles) EncodeToFile(script, file string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.Fs[file] = script
 	return nil
 }
 
 // CreateDir satisfies DirCreator
 func (f *FakeFiles) CreateDir(a, b string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	// return the "chosen" directory name for next folder
 	return b, nil
 }
 
 // Clear satisfies DirCreator
 func (f *FakeFiles) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return nil
+}
+
+// Remove satisfies FileRemover
+func (f *FakeFiles) Remove(relpath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Data, relpath)
+	delete(f.Fs, relpath)
 	return nil
 }
 
 // ListFilesAndFolders satisfies DirExplorer
 func (f *FakeFiles) ListFilesAndFolders(relpath string) ([]string, []string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	// ignore non json files. i don't think they Matter
 	files := []string{}
 	folders := []string{}
@@ -148,6 +182,8 @@ func (f *FakeFiles) ListFilesAndFolders(relpath string) ([]string, []string, err
 
 // DebugFileNames lets you log the file structure
 func (f *FakeFiles) DebugFileNames(log func(s string, args ...interface{})) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	log("txt files:\n")
 	for fn := range f.Fs {
 		log("\t%s\n", fn)
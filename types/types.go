@@ -1,6 +1,9 @@
 package types
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // J is json
 type J map[string]interface{}
@@ -33,3 +36,18 @@ func ConvertToObjArray(v interface{}) ([]map[string]interface{}, error) {
 	}
 	return arr, nil
 }
+
+// Normalize gives m the types it would have if read from a file, such as
+// map[string]interface{} in place of J, by a json round trip. The result is a
+// deep copy of m.
+func Normalize(m map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal(): %v", err)
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(): %v", err)
+	}
+	return out, nil
+}
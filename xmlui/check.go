@@ -0,0 +1,317 @@
+package xmlui
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problem is something in XmlUI that TTS won't understand.
+type Problem struct {
+	// Offset is where the problem is in the checked XML, from 0, and Line the
+	// line it is on, from 1.
+	Offset, Line int
+	Msg          string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.Line, p.Msg)
+}
+
+// Check reports every element of src that isn't in the schema, and every
+// attribute that its element doesn't take or whose value isn't of the type
+// it should be. XML that can't be read is reported as a single problem, after
+// any found before it.
+func Check(src string) []Problem {
+	c := &checker{src: src, line: 1}
+	d := xml.NewDecoder(strings.NewReader(src))
+	d.Entity = xml.HTMLEntity
+	for {
+		at := int(d.InputOffset())
+		tok, err := d.Token()
+		if err != nil {
+			if se, ok := err.(*xml.SyntaxError); ok {
+				c.problems = append(c.problems, Problem{Offset: c.lineStart(se.Line), Line: se.Line, Msg: se.Msg})
+			} else if err != io.EOF {
+				c.add(at, err.Error())
+			}
+			return c.problems
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			c.element(start, at, int(d.InputOffset()))
+		}
+	}
+}
+
+type checker struct {
+	src      string
+	problems []Problem
+	// line is the line of offset at
+	at, line int
+}
+
+func (c *checker) add(offset int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Offset: offset, Line: c.lineOf(offset), Msg: fmt.Sprintf(format, args...)})
+}
+
+// lineOf counts lines on from where it last stopped, starting over for an
+// offset before that.
+func (c *checker) lineOf(offset int) int {
+	if offset < c.at {
+		c.at, c.line = 0, 1
+	}
+	c.line += strings.Count(c.src[c.at:offset], "\n")
+	c.at = offset
+	return c.line
+}
+
+func (c *checker) lineStart(line int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		next := strings.IndexByte(c.src[offset:], '\n')
+		if next < 0 {
+			break
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// element checks the element spanning src[start:end] against the schema.
+func (c *checker) element(e xml.StartElement, start, end int) {
+	name := e.Name.Local
+	el, ok := elements[name]
+	if !ok {
+		msg := fmt.Sprintf("unknown element <%s>", name)
+		if s := suggest(name, elementNames()); s != "" {
+			msg += fmt.Sprintf(", did you mean <%s>?", s)
+		}
+		c.add(start, "%s", msg)
+		return
+	}
+	tag := c.src[start:end]
+	for _, a := range e.Attr {
+		if a.Name.Space != "" || a.Name.Local == "xmlns" {
+			continue
+		}
+		at := start + attrOffset(tag, a.Name.Local)
+		t, ok := el[a.Name.Local]
+		if !ok {
+			msg := fmt.Sprintf("<%s> has no attribute %s", name, a.Name.Local)
+			if s := suggest(a.Name.Local, el.names()); s != "" {
+				msg += fmt.Sprintf(", did you mean %s?", s)
+			}
+			c.add(at, "%s", msg)
+			continue
+		}
+		kind, arg := t, ""
+		if i := strings.IndexByte(t, ':'); i >= 0 {
+			kind, arg = t[:i], t[i+1:]
+		}
+		vt := valueTypes[kind]
+		if !vt.valid(a.Value, arg) {
+			c.add(at, "<%s> %s=%q is not %s", name, a.Name.Local, a.Value, vt.describe(arg))
+		}
+	}
+}
+
+// attrOffset finds where attribute name is set in tag, or 0.
+func attrOffset(tag, name string) int {
+	for from := 0; ; {
+		i := strings.Index(tag[from:], name)
+		if i < 0 {
+			return 0
+		}
+		i += from
+		after := strings.TrimLeft(tag[i+len(name):], " \t\r\n")
+		if i > 0 && strings.ContainsRune(" \t\r\n", rune(tag[i-1])) && strings.HasPrefix(after, "=") {
+			return i
+		}
+		from = i + len(name)
+	}
+}
+
+func (el element) names() []string {
+	names := make([]string, 0, len(el))
+	for n := range el {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func elementNames() []string {
+	names := make([]string, 0, len(elements))
+	for n := range elements {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggest picks what name was probably meant to be out of names: one that
+// differs only by case, or else the closest within two edits.
+func suggest(name string, names []string) string {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+	}
+	best, bestDist := "", 3
+	for _, n := range names {
+		d := distance(strings.ToLower(name), strings.ToLower(n))
+		if d < bestDist && d < len(name) {
+			best, bestDist = n, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// valueType is a type of attribute value in the schema. Some, like enum, are
+// written with an argument after a colon.
+type valueType struct {
+	desc  string
+	valid func(v, arg string) bool
+}
+
+func (t valueType) describe(arg string) string {
+	if t.desc == "" {
+		return "one of " + strings.Join(strings.Split(arg, ","), ", ")
+	}
+	return t.desc
+}
+
+var valueTypes = map[string]valueType{
+	"string": {desc: "a string", valid: func(string, string) bool { return true }},
+	"bool": {desc: "true or false", valid: func(v, _ string) bool {
+		return strings.EqualFold(v, "true") || strings.EqualFold(v, "false")
+	}},
+	"int": {desc: "a whole number", valid: func(v, _ string) bool {
+		_, err := strconv.Atoi(strings.TrimSpace(v))
+		return err == nil
+	}},
+	"float": {desc: "a number", valid: func(v, _ string) bool { return isFloat(v) }},
+	"size": {desc: "a number or percentage", valid: func(v, _ string) bool {
+		return isFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"))
+	}},
+	"floats": {desc: "numbers separated by spaces", valid: func(v, _ string) bool {
+		return areFloats(strings.Fields(v), -1)
+	}},
+	"vector2": {desc: "two numbers", valid: func(v, _ string) bool { return areFloats(vectorFields(v), 2) }},
+	// z is 0 when left out
+	"vector3": {desc: "two or three numbers", valid: func(v, _ string) bool {
+		f := vectorFields(v)
+		return areFloats(f, 2) || areFloats(f, 3)
+	}},
+	"padding": {desc: "four numbers: left right top bottom", valid: func(v, _ string) bool {
+		return areFloats(strings.Fields(v), 4)
+	}},
+	"color": {desc: "a color", valid: func(v, _ string) bool { return isColor(v) }},
+	"colors": {desc: "up to four colors separated by |", valid: func(v, _ string) bool {
+		cs := strings.Split(v, "|")
+		if len(cs) > 4 {
+			return false
+		}
+		for _, c := range cs {
+			if !isColor(c) {
+				return false
+			}
+		}
+		return true
+	}},
+	"enum": {valid: func(v, arg string) bool {
+		for _, o := range strings.Split(arg, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), o) {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+func isFloat(v string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	return err == nil
+}
+
+// areFloats reports whether every field is a number, and that there are n of
+// them unless n is negative.
+func areFloats(fields []string, n int) bool {
+	if len(fields) == 0 || (n >= 0 && len(fields) != n) {
+		return false
+	}
+	for _, f := range fields {
+		if !isFloat(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func vectorFields(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+}
+
+// namedColors are the colors TTS knows by name, including those of the
+// players' seats.
+var namedColors = map[string]bool{
+	"black": true, "blue": true, "brown": true, "clear": true, "cyan": true,
+	"gray": true, "green": true, "grey": true, "magenta": true, "orange": true,
+	"pink": true, "purple": true, "red": true, "teal": true, "white": true,
+	"yellow": true,
+}
+
+// isColor accepts #RGB, #RGBA, #RRGGBB and #RRGGBBAA, rgb(r,g,b) and
+// rgba(r,g,b,a) with components from 0 to 1, and the named colors.
+func isColor(v string) bool {
+	v = strings.TrimSpace(v)
+	lower := strings.ToLower(v)
+	switch {
+	case strings.HasPrefix(v, "#"):
+		hex := v[1:]
+		if n := len(hex); n != 3 && n != 4 && n != 6 && n != 8 {
+			return false
+		}
+		_, err := strconv.ParseUint(hex, 16, 64)
+		return err == nil
+	case strings.HasPrefix(lower, "rgba(") && strings.HasSuffix(lower, ")"):
+		return areFloats(strings.Split(v[len("rgba("):len(v)-1], ","), 4)
+	case strings.HasPrefix(lower, "rgb(") && strings.HasSuffix(lower, ")"):
+		return areFloats(strings.Split(v[len("rgb("):len(v)-1], ","), 3)
+	}
+	return namedColors[lower]
+}
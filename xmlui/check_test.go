@@ -0,0 +1,117 @@
+package xmlui
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []Problem
+	}{
+		{
+			name: "valid",
+			input: `<Defaults><Text class="title" fontSize="20"/></Defaults>
+<Panel id="p" width="50%" height="80" color="#FF000080" rectAlignment="upperleft" active="False">
+  <Text fontStyle="Bold" color="rgba(1,1,1,0.5)" position="0 10 -5">Hi &amp; bye</Text>
+  <Button colors="White|#CCC|Grey|rgb(0.2,0.2,0.2)" padding="1 2 3 4" onClick="clicked"/>
+</Panel>`,
+			want: nil,
+		},
+		{
+			// the XmlUI of an ordinary mod, attributes as the TTS UI reference
+			// gives them
+			name: "typical mod",
+			input: `<Defaults>
+  <Button class="menu" fontSize="18" textColor="#FFFFFF" colors="#333333|#555555|#222222|#33333380"/>
+  <Text class="label" fontStyle="Bold" alignment="MiddleLeft"/>
+</Defaults>
+<Panel id="setup" width="420" height="360" rectAlignment="MiddleCenter" color="#000000CC" allowDragging="true" returnToOriginalPositionWhenReleased="false" showAnimation="FadeIn" hideAnimation="FadeOut" visibility="Black|White">
+  <VerticalLayout padding="10 10 10 10" spacing="6" childForceExpandHeight="false" childAlignment="UpperCenter">
+    <Text class="label" fontSize="24" resizeTextForBestFit="true" horizontalOverflow="Overflow">Game setup</Text>
+    <HorizontalLayout preferredHeight="40" spacing="4">
+      <Text class="label">Players</Text>
+      <Dropdown id="players" onValueChanged="playersChanged" itemHeight="30" textColor="#FFFFFF">
+        <Option>2</Option>
+        <Option selected="true">3</Option>
+        <Option>4</Option>
+      </Dropdown>
+    </HorizontalLayout>
+    <InputField id="seed" placeholder="Random seed" characterValidation="Integer" characterLimit="8" textAlignment="MiddleCenter" onEndEdit="seedEntered" preferredHeight="40"/>
+    <Toggle id="expansion" isOn="true" toggleWidth="24" toggleHeight="24" toggleSelectedColor="#44AA44" onValueChanged="expansionToggled">Use the expansion</Toggle>
+    <ToggleGroup allowSwitchOff="false">
+      <HorizontalLayout>
+        <ToggleButton isOn="true" textAlignment="MiddleCenter" onValueChanged="easy">Easy</ToggleButton>
+        <ToggleButton onValueChanged="hard">Hard</ToggleButton>
+      </HorizontalLayout>
+    </ToggleGroup>
+    <Slider id="speed" minValue="1" maxValue="10" value="5" wholeNumbers="true" fillColor="#44AA44" onValueChanged="speedChanged"/>
+    <ProgressBar percentage="40" showPercentageText="true" textAlignment="MiddleRight"/>
+    <VerticalScrollView preferredHeight="80" scrollSensitivity="30" verticalScrollbarVisibility="AutoHide">
+      <GridLayout cellSize="60 60" spacing="4 4" constraint="FixedColumnCount" constraintCount="4">
+        <Image image="token" preserveAspect="true" tooltip="A token" tooltipPosition="Above"/>
+      </GridLayout>
+    </VerticalScrollView>
+    <Button class="menu" textAlignment="MiddleLeft" icon="start" iconWidth="32" iconAlignment="Left" onClick="Global/startGame" tooltip="Start the game">Start</Button>
+  </VerticalLayout>
+</Panel>`,
+			want: nil,
+		},
+		{
+			name:  "misspelled attribute",
+			input: "<Panel>\n  <Text\n    text=\"hi\"\n    fontsize=\"20\"/>\n</Panel>",
+			want: []Problem{
+				{Offset: 34, Line: 4, Msg: "<Text> has no attribute fontsize, did you mean fontSize?"},
+			},
+		},
+		{
+			name:  "unknown elements and attributes",
+			input: "<Txet/>\n<Widget/>\n<Image bogus=\"1\"/>",
+			want: []Problem{
+				{Offset: 0, Line: 1, Msg: "unknown element <Txet>, did you mean <Text>?"},
+				{Offset: 8, Line: 2, Msg: "unknown element <Widget>"},
+				{Offset: 25, Line: 3, Msg: "<Image> has no attribute bogus"},
+			},
+		},
+		{
+			name:  "bad values",
+			input: `<Text fontSize="big" color="#12" alignment="Middle" position="1"/>`,
+			want: []Problem{
+				{Offset: 6, Line: 1, Msg: `<Text> fontSize="big" is not a whole number`},
+				{Offset: 21, Line: 1, Msg: `<Text> color="#12" is not a color`},
+				{Offset: 33, Line: 1, Msg: `<Text> alignment="Middle" is not one of UpperLeft, UpperCenter, UpperRight, MiddleLeft, MiddleCenter, MiddleRight, LowerLeft, LowerCenter, LowerRight`},
+				{Offset: 52, Line: 1, Msg: `<Text> position="1" is not two or three numbers`},
+			},
+		},
+		{
+			name:  "unreadable",
+			input: "<Panel>\n<Text></Panel>",
+			want: []Problem{
+				{Offset: 8, Line: 2, Msg: "element <Text> closed by </Panel>"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Check(tc.input)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("want != got:\n%v\n", diff)
+			}
+		})
+	}
+}
+
+func TestSchemaLoads(t *testing.T) {
+	els, err := loadSchema(schemaJSON)
+	if err != nil {
+		t.Fatalf("loadSchema(): %v", err)
+	}
+	if got := els["Button"]["fontSize"]; got != "int" {
+		t.Errorf("want Button to take the text attributes, got fontSize %q", got)
+	}
+	if _, err := loadSchema([]byte(`{"elements": {"Text": {"groups": ["nope"]}}}`)); err == nil {
+		t.Errorf("want an error for an unknown group")
+	}
+}
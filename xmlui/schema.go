@@ -0,0 +1,69 @@
+// Package xmlui checks XmlUI against the elements and attributes that Tabletop
+// Simulator understands, which it otherwise ignores without a word.
+package xmlui
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// schema.json lists every element TTS draws, with the type of each attribute
+// it takes. "common" attributes apply to every element, and "groups" are
+// attributes that several elements share, like those of text.
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+type rawSchema struct {
+	Common   map[string]string            `json:"common"`
+	Groups   map[string]map[string]string `json:"groups"`
+	Elements map[string]struct {
+		Groups     []string          `json:"groups"`
+		Attributes map[string]string `json:"attributes"`
+	} `json:"elements"`
+}
+
+// element is the attributes one element takes, by name, to their type.
+type element map[string]string
+
+var elements = mustLoadSchema(schemaJSON)
+
+func mustLoadSchema(b []byte) map[string]element {
+	els, err := loadSchema(b)
+	if err != nil {
+		panic(fmt.Sprintf("xmlui: %v", err))
+	}
+	return els
+}
+
+func loadSchema(b []byte) (map[string]element, error) {
+	var raw rawSchema
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("reading schema: %v", err)
+	}
+	els := map[string]element{}
+	for name, e := range raw.Elements {
+		el := element{}
+		sources := []map[string]string{raw.Common}
+		for _, g := range e.Groups {
+			attrs, ok := raw.Groups[g]
+			if !ok {
+				return nil, fmt.Errorf("element %s uses unknown group %q", name, g)
+			}
+			sources = append(sources, attrs)
+		}
+		sources = append(sources, e.Attributes)
+		for _, attrs := range sources {
+			for a, t := range attrs {
+				if _, ok := valueTypes[strings.SplitN(t, ":", 2)[0]]; !ok {
+					return nil, fmt.Errorf("attribute %s of %s has unknown type %q", a, name, t)
+				}
+				el[a] = t
+			}
+		}
+		els[name] = el
+	}
+	return els, nil
+}